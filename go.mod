@@ -4,8 +4,9 @@ go 1.12
 
 require (
 	github.com/gin-gonic/gin v1.6.3
-	github.com/huobirdcenter/huobi_golang v0.0.0-20200522081408-948c7624a96e
+	github.com/gorilla/websocket v1.4.2
 	github.com/pierrec/lz4 v2.0.5+incompatible // indirect
+	github.com/shopspring/decimal v1.2.0
 	github.com/spf13/viper v1.7.0
 	github.com/y905699146/binance v0.0.0-20200603212520-de2b54814dfd
 	go.uber.org/zap v1.15.0
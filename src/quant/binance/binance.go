@@ -9,32 +9,119 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 	"tinyquant/src/logger"
 	"tinyquant/src/mod"
+	"tinyquant/src/quant"
 	"tinyquant/src/util"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
 type Binance struct {
+	timeOffset int64 // 服务器时间-本地时间(ns)，原子操作，需保持在首位以保证64位对齐
+	recvWindow int64 // 签名请求的有效时间窗口(ms)
 	accessKey  string
 	secretKey  string
 	baseUrl    string
 	httpClient *http.Client
+	testMode   bool // 测试下单模式，订单只校验不会真正撮合，默认开启
+	symbols    *SymbolRegistry
+	timeSync   *timeSyncer
+	limiter    *RateLimiter
+	// 按请求类型的重试策略，未设置时使用 defaultRetryPolicies
+	retryPolicies map[RequestKind]util.RetryPolicy
+	avgPrices     *avgPriceCache
 }
 
-func NewBinance() *Binance {
-	return &Binance{}
+var _ quant.Exchange = (*Binance)(nil)
+
+func NewBinance(accessKey, secretKey string) *Binance {
+	return &Binance{
+		recvWindow: defaultRecvWindow,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		limiter:    defaultRateLimiter,
+		testMode:   true,
+		avgPrices:  newAvgPriceCache(),
+	}
+}
+
+func (b *Binance) String() string {
+	return "binance"
+}
+
+// toSymbol 转换为binance的交易对名称，如 BTCUSDT
+func toSymbol(pair quant.CurrencyPair) string {
+	return pair.ToSymbol("")
+}
+
+// currencyPair 将binance的交易对名称转换为通用交易对，优先使用交易规则缓存
+func (b *Binance) currencyPair(symbol string) quant.CurrencyPair {
+	if b.symbols != nil {
+		if pair, ok := b.symbols.CurrencyPair(symbol); ok {
+			return pair
+		}
+	}
+	pair, _ := quant.ParseCurrencyPair(symbol)
+	return pair
+}
+
+// GetCurrencyPairs 获取所有交易对，已设置交易规则缓存时直接使用缓存
+func (b *Binance) GetCurrencyPairs(ctx context.Context) ([]quant.CurrencyPair, error) {
+	if b.symbols != nil && !b.symbols.UpdateTime().IsZero() {
+		return b.symbols.CurrencyPairs(), nil
+	}
+	info, err := b.GetExchangeInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]quant.CurrencyPair, 0, len(info.Symbols))
+	for i := range info.Symbols {
+		pairs = append(pairs, info.Symbols[i].CurrencyPair())
+	}
+	return pairs, nil
+}
+
+// SetRecvWindow 设置签名请求的有效时间窗口，单位:ms，最大 60000
+func (b *Binance) SetRecvWindow(recvWindow int64) *Binance {
+	b.recvWindow = recvWindow
+	return b
+}
+
+// SetSymbolRegistry 设置交易规则缓存，下单前按交易规则校验并修正价格与数量
+func (b *Binance) SetSymbolRegistry(symbols *SymbolRegistry) *Binance {
+	b.symbols = symbols
+	return b
+}
+
+// SetBaseURL 设置REST地址，为空时使用 util.BaseURL
+func (b *Binance) SetBaseURL(baseURL string) *Binance {
+	b.baseUrl = strings.TrimSuffix(baseURL, "/")
+	return b
+}
+
+// SetHttpClient 替换http client，为nil时使用 util 的默认client
+func (b *Binance) SetHttpClient(client *http.Client) *Binance {
+	b.httpClient = client
+	return b
+}
+
+// SetTestMode 切换测试下单(/api/v3/order/test)与实盘下单，默认为测试下单，实盘需要显式调用 SetTestMode(false)
+func (b *Binance) SetTestMode(testMode bool) *Binance {
+	b.testMode = testMode
+	return b
 }
 
 /*
 	SHA256生成签名，SECRETKEY为密钥，body为参数
 */
 func (b *Binance) ParamsSigned(postForm *url.Values) error {
-	postForm.Set("recvWindow", "60000")
-	tonce := strconv.FormatInt(util.GetCurrentUnixNano(), 10)[0:13]
-	postForm.Set("timestamp", tonce)
+	postForm.Del("signature")
+	postForm.Set("recvWindow", strconv.FormatInt(b.recvWindow, 10))
+	postForm.Set("timestamp", strconv.FormatInt(b.ServerTimestamp(), 10))
 	postMsg := postForm.Encode()
 	mac := hmac.New(sha256.New, []byte(b.secretKey))
 	_, err := mac.Write([]byte(postMsg))
@@ -47,18 +134,29 @@ func (b *Binance) ParamsSigned(postForm *url.Values) error {
 }
 
 /*
-	获取服务端与本地的时间差
+	获取服务端与本地的时间差(ns)，按请求往返时间的一半补偿网络延迟
 */
 
 func (b *Binance) LocolTimeSubServerTime(ctx context.Context) int64 {
-	serverTime, err := b.GetServiceTime(ctx)
+	offset, _, err := b.sampleTimeOffset(ctx)
 	if err != nil {
 		logger.Logger.Error("get server time failed ", zap.Error(err))
 		return 0
 	}
-	st := time.Unix(serverTime/1000, 0)
-	nt := time.Now()
-	return st.Sub(nt).Nanoseconds()
+	return offset
+}
+
+func (b *Binance) sampleTimeOffset(ctx context.Context) (int64, time.Duration, error) {
+	start := time.Now()
+	serverTime, err := b.GetServiceTime(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	rtt := time.Since(start)
+	// 假设服务器在请求往返的中点生成时间戳
+	local := start.Add(rtt / 2)
+	st := time.Unix(0, serverTime*int64(time.Millisecond))
+	return st.Sub(local).Nanoseconds(), rtt, nil
 }
 
 type Ping struct {
@@ -70,15 +168,12 @@ func (b *Binance) Ping(ctx context.Context) (*Ping, error) {
 		Method: "GET",
 		URL:    util.PingURL,
 	}
-	data, err := util.HttpRequest(ctx, r)
+	err := b.request(ctx, r, nil)
 	if err != nil {
 		logger.Logger.Error("Binance Service Ping Failed", zap.Error(err))
 		return nil, err
 	}
-	if _, ok := data["code"]; ok {
-		return nil, err
-	}
-	return nil, nil
+	return &Ping{}, nil
 }
 
 type SeviceTime struct {
@@ -90,88 +185,497 @@ func (b *Binance) GetServiceTime(ctx context.Context) (int64, error) {
 		Method: "GET",
 		URL:    util.ServiceTimeURL,
 	}
-	data, err := util.HttpRequest(ctx, r)
+	p := new(SeviceTime)
+	err := b.request(ctx, r, p)
 	if err != nil {
-		logger.Logger.Error("Binance Service Ping Failed", zap.Error(err))
+		logger.Logger.Error("Binance Service Get Server Time Failed", zap.Error(err))
 		return 0, err
 	}
-	if _, ok := data["code"]; ok {
-		return time.Now().Unix(), err
-	}
-	p := new(SeviceTime)
-	p.ServerTime = util.ToInt64(data["serverTime"])
-
 	return p.ServerTime, nil
 }
 
-type Order struct {
-	Symbol     string
-	OrderID    int
-	OrderType  int //0:default,1:maker,2:fok,3:ioc
-	Side       TradeSide
-	AvgPrice   float64
-	Type       string // limit / market
-	Fee        float64
-	Price      float64
-	DealAmount float64
-	Amount     float64
-	Status     TradeStatus
-	OrderTime  int
-}
+type Order = quant.Order
 
 /*
-	SYMBOL : 交易对
-	orderSide : 买 / 卖
-	orderType : 订单类型
+	下单
+	req.Pair : 交易对
+	req.Side : BUY / SELL，BUY_MARKET / SELL_MARKET 视为市价单
+	req.Type : quant.ORDER_TYPE_*，为空时默认限价单
+	req.QuoteAmount : 市价单按计价货币金额下单(quoteOrderQty)
+	req.StopPrice / req.IcebergAmount / req.TimeInForce / req.ResponseType : 见 quant.OrderRequest
+	req.ClientOrderID : 为空时由 req.ResolveClientOrderID 生成并写回
+	指定了 ClientOrderID 或 Intent 时先按客户端订单号查询，订单已存在则直接返回，不会重复下单
 */
-func (b *Binance) PlaceOrder(ctx context.Context, amount, price string, symbol, orderType, orderSide string) (*Order, error) {
+func (b *Binance) PlaceOrder(ctx context.Context, req *quant.OrderRequest) (*Order, error) {
+	symbol, price, amount := toSymbol(req.Pair), req.Price, req.Amount
+	orderSide, orderType := "BUY", req.Type
+	switch req.Side {
+	case BUY:
+	case SELL:
+		orderSide = "SELL"
+	case BUY_MARKET:
+		orderType = quant.ORDER_TYPE_MARKET
+	case SELL_MARKET:
+		orderSide, orderType = "SELL", quant.ORDER_TYPE_MARKET
+	default:
+		return nil, fmt.Errorf("invalid order side %v", req.Side)
+	}
+	if orderType == "" {
+		orderType = quant.ORDER_TYPE_LIMIT
+	}
+	stopPrice, iceberg, quoteAmount := req.StopPrice, req.IcebergAmount, req.QuoteAmount
+	if err := checkOrderRequest(orderType, req); err != nil {
+		return nil, err
+	}
+	if b.symbols != nil {
+		ts, ok := b.symbols.Symbol(symbol)
+		if !ok {
+			return nil, fmt.Errorf("unknown symbol %s", symbol)
+		}
+		// PERCENT_PRICE 与市价单的 MIN_NOTIONAL 需要近5分钟平均价
+		avgPrice := decimal.Zero
+		if !quoteAmount.IsPositive() && ts.needsAvgPrice(orderType) {
+			var err error
+			if avgPrice, err = b.cachedAvgPrice(ctx, symbol); err != nil {
+				logger.Logger.Warn("get avg price failed, skip PERCENT_PRICE check ", zap.String("symbol", symbol), zap.Error(err))
+			}
+		}
+		var err error
+		if quoteAmount.IsPositive() {
+			err = ts.ValidateQuoteOrderQty(quoteAmount)
+		} else {
+			price, amount, err = ts.ValidateOrder(orderType, price, amount, avgPrice)
+		}
+		if err == nil && stopPrice.IsPositive() {
+			stopPrice, err = ts.ValidateStopPrice(stopPrice)
+		}
+		if err == nil && iceberg.IsPositive() {
+			iceberg, err = ts.ValidateIceberg(amount, iceberg)
+		}
+		if err != nil {
+			logger.Logger.Warn("Binance Service Place Order rejected", zap.Error(err))
+			return nil, err
+		}
+	}
+	clientOrderID, known, err := req.ResolveClientOrderID()
+	if err != nil {
+		logger.Logger.Error("Binance Service Place Order Failed", zap.Error(err))
+		return nil, err
+	}
+	if known && !b.testMode {
+		placed, err := b.queryOrderByClientID(ctx, symbol, clientOrderID)
+		if err == nil {
+			logger.Logger.Info("binance order already placed ", zap.String("clientOrderId", clientOrderID))
+			order := placed.toOrder()
+			order.Pair = req.Pair
+			return order, nil
+		}
+		if !IsOrderNotFound(err) {
+			logger.Logger.Error("Binance Service Place Order Failed", zap.Error(err))
+			return nil, err
+		}
+	}
 	r := &mod.ReqParam{
 		Method: "POST",
-		URL:    "/api/v3/order/test",
+		URL:    util.OrderURL,
+	}
+	if b.testMode {
+		r.URL = util.TestOrderURL
 	}
 	r.SetParam("symbol", symbol)
 	r.SetParam("side", orderSide)
 	r.SetParam("type", orderType)
-	r.SetParam("newOrderRespType", "ACK")
-	r.SetParam("quantity", amount)
-	switch orderType {
-	case "LIMIT":
-		r.SetParam("timeInForce", "GTC")
-		r.SetParam("price", price)
-	case "MARKET":
-		r.SetParam("newOrderRespType", "RESULT")
-	}
-	b.ParamsSigned(&r.Query)
-	r.Header.Set("X-MBX-APIKEY", b.accessKey)
-	data, err := util.HttpRequest(ctx, r)
+	r.SetParam("newClientOrderId", clientOrderID)
+	if quoteAmount.IsPositive() {
+		r.SetParam("quoteOrderQty", quoteAmount.String())
+	} else {
+		r.SetParam("quantity", amount.String())
+	}
+	if hasLimitPrice(orderType) {
+		r.SetParam("price", price.String())
+	}
+	if stopPrice.IsPositive() {
+		r.SetParam("stopPrice", stopPrice.String())
+	}
+	if iceberg.IsPositive() {
+		r.SetParam("icebergQty", iceberg.String())
+	}
+	// LIMIT_MAKER 不接受 timeInForce，冰山单必须为GTC
+	if hasLimitPrice(orderType) && orderType != quant.ORDER_TYPE_LIMIT_MAKER {
+		timeInForce := req.TimeInForce
+		if timeInForce == "" {
+			timeInForce = quant.TIME_IN_FORCE_GTC
+		}
+		r.SetParam("timeInForce", timeInForce)
+	}
+	respType := req.ResponseType
+	if respType == "" {
+		// 市价单立即成交，默认返回成交结果
+		respType = ORDER_RESP_TYPE_ACK
+		if !hasLimitPrice(orderType) {
+			respType = ORDER_RESP_TYPE_RESULT
+		}
+	}
+	r.SetParam("newOrderRespType", respType)
+	resp := new(orderResponse)
+	err = b.placeOrder(ctx, r, symbol, resp)
 	if err != nil {
-		logger.Logger.Error("Binance Service Ping Failed", zap.Error(err))
+		logger.Logger.Error("Binance Service Place Order Failed", zap.Error(err))
 		return nil, err
 	}
-	orderID := util.ToInt(data["orderId"])
-	if orderID < 0 {
+	if resp.OrderID < 0 {
 		return nil, fmt.Errorf("orderid error")
 	}
-	dealAmount := util.ToFloat64(data["executedQty"])
-	cummulativeQuoteQty := util.ToFloat64(data["cummulativeQuoteQty"])
-	avgPrice := 0.0
-	if cummulativeQuoteQty > 0 && dealAmount > 0 {
-		avgPrice = cummulativeQuoteQty / dealAmount
+	// ACK 响应只包含订单号，其余字段使用请求参数
+	if resp.Symbol == "" {
+		resp.Symbol = symbol
+	}
+	if resp.Side == "" {
+		resp.Side = orderSide
+	}
+	if resp.Type == "" {
+		resp.Type = orderType
+	}
+	if resp.Price.IsZero() {
+		resp.Price = price
+	}
+	if resp.OrigQty.IsZero() && !quoteAmount.IsPositive() {
+		resp.OrigQty = amount
+	}
+	order := resp.toOrder()
+	order.Pair = req.Pair
+	return order, nil
+}
+
+// checkOrderRequest 按订单类型检查必需与不允许的参数，不依赖交易规则
+func checkOrderRequest(orderType string, req *quant.OrderRequest) error {
+	stop := false
+	switch orderType {
+	case quant.ORDER_TYPE_LIMIT, quant.ORDER_TYPE_MARKET, quant.ORDER_TYPE_LIMIT_MAKER:
+	case quant.ORDER_TYPE_STOP_LOSS, quant.ORDER_TYPE_STOP_LOSS_LIMIT, quant.ORDER_TYPE_TAKE_PROFIT, quant.ORDER_TYPE_TAKE_PROFIT_LIMIT:
+		stop = true
+	default:
+		return fmt.Errorf("unsupported order type %s", orderType)
+	}
+	limit := hasLimitPrice(orderType)
+	switch {
+	case stop && !req.StopPrice.IsPositive():
+		return fmt.Errorf("%s order requires stopPrice", orderType)
+	case !stop && !req.StopPrice.IsZero():
+		return fmt.Errorf("stopPrice not allowed for %s order", orderType)
+	case !req.QuoteAmount.IsZero() && orderType != quant.ORDER_TYPE_MARKET:
+		return fmt.Errorf("quoteOrderQty only allowed for MARKET order")
+	case req.QuoteAmount.IsZero() && !req.Amount.IsPositive():
+		return fmt.Errorf("invalid quantity %s", req.Amount)
+	case req.QuoteAmount.IsNegative():
+		return fmt.Errorf("invalid quoteOrderQty %s", req.QuoteAmount)
+	case limit && !req.Price.IsPositive():
+		return fmt.Errorf("%s order requires price", orderType)
+	case !req.IcebergAmount.IsZero() && !limit:
+		return fmt.Errorf("icebergQty not allowed for %s order", orderType)
+	case !req.IcebergAmount.IsZero() && req.TimeInForce != "" && req.TimeInForce != quant.TIME_IN_FORCE_GTC:
+		return fmt.Errorf("iceberg order requires timeInForce GTC")
+	}
+	switch req.TimeInForce {
+	case "":
+	case quant.TIME_IN_FORCE_GTC, quant.TIME_IN_FORCE_IOC, quant.TIME_IN_FORCE_FOK:
+		if !limit || orderType == quant.ORDER_TYPE_LIMIT_MAKER {
+			return fmt.Errorf("timeInForce not allowed for %s order", orderType)
+		}
+	default:
+		return fmt.Errorf("invalid timeInForce %s", req.TimeInForce)
+	}
+	switch req.ResponseType {
+	case "", ORDER_RESP_TYPE_ACK, ORDER_RESP_TYPE_RESULT, ORDER_RESP_TYPE_FULL:
+	default:
+		return fmt.Errorf("invalid newOrderRespType %s", req.ResponseType)
+	}
+	return nil
+}
+
+/*
+	查询订单
+	pair(必需) : 交易对
+	orderID(必需) : 订单号
+*/
+func (b *Binance) GetOrder(ctx context.Context, pair quant.CurrencyPair, orderID string) (*Order, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.OrderURL,
+	}
+	r.SetParam(util.SymbolKey, toSymbol(pair))
+	r.SetParam("orderId", orderID)
+	resp := new(orderResponse)
+	err := b.signedRequest(ctx, r, resp)
+	if err != nil {
+		logger.Logger.Error("Binance Service Get Order Failed", zap.Error(err))
+		return nil, err
+	}
+	order := resp.toOrder()
+	order.Pair = pair
+	return order, nil
+}
+
+/*
+	撤销订单
+	pair(必需) : 交易对
+	orderID(必需) : 订单号
+*/
+func (b *Binance) CancelOrder(ctx context.Context, pair quant.CurrencyPair, orderID string) (*Order, error) {
+	r := &mod.ReqParam{
+		Method: "DELETE",
+		URL:    util.OrderURL,
+	}
+	r.SetParam(util.SymbolKey, toSymbol(pair))
+	r.SetParam("orderId", orderID)
+	resp := new(orderResponse)
+	err := b.signedRequest(ctx, r, resp)
+	if err != nil {
+		logger.Logger.Error("Binance Service Cancel Order Failed", zap.Error(err))
+		return nil, err
+	}
+	order := resp.toOrder()
+	order.Pair = pair
+	return order, nil
+}
+
+/*
+	按客户端订单号查询订单
+	pair(必需) : 交易对
+	clientOrderID(必需) : 下单时的 newClientOrderId
+*/
+func (b *Binance) GetOrderByClientID(ctx context.Context, pair quant.CurrencyPair, clientOrderID string) (*Order, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.OrderURL,
+	}
+	r.SetParam(util.SymbolKey, toSymbol(pair))
+	r.SetParam("origClientOrderId", clientOrderID)
+	resp := new(orderResponse)
+	err := b.signedRequest(ctx, r, resp)
+	if err != nil {
+		logger.Logger.Error("Binance Service Get Order Failed", zap.Error(err))
+		return nil, err
+	}
+	order := resp.toOrder()
+	order.Pair = pair
+	return order, nil
+}
+
+/*
+	按客户端订单号撤销订单
+	pair(必需) : 交易对
+	clientOrderID(必需) : 下单时的 newClientOrderId
+*/
+func (b *Binance) CancelOrderByClientID(ctx context.Context, pair quant.CurrencyPair, clientOrderID string) (*Order, error) {
+	r := &mod.ReqParam{
+		Method: "DELETE",
+		URL:    util.OrderURL,
+	}
+	r.SetParam(util.SymbolKey, toSymbol(pair))
+	r.SetParam("origClientOrderId", clientOrderID)
+	resp := new(orderResponse)
+	err := b.signedRequest(ctx, r, resp)
+	if err != nil {
+		logger.Logger.Error("Binance Service Cancel Order Failed", zap.Error(err))
+		return nil, err
+	}
+	order := resp.toOrder()
+	order.Pair = pair
+	return order, nil
+}
+
+/*
+	撤销交易对的所有挂单
+	pair(必需) : 交易对
+*/
+func (b *Binance) CancelAllOpenOrders(ctx context.Context, pair quant.CurrencyPair) ([]*Order, error) {
+	r := &mod.ReqParam{
+		Method: "DELETE",
+		URL:    util.OpenOrdersURL,
+	}
+	r.SetParam(util.SymbolKey, toSymbol(pair))
+	return b.getOrders(ctx, r)
+}
+
+/*
+	查询当前挂单
+	pair : 交易对，为空时返回所有交易对的挂单
+*/
+func (b *Binance) GetOpenOrders(ctx context.Context, pair quant.CurrencyPair) ([]*Order, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.OpenOrdersURL,
+	}
+	if !pair.IsZero() {
+		r.SetParam(util.SymbolKey, toSymbol(pair))
+	}
+	return b.getOrders(ctx, r)
+}
+
+/*
+	查询所有订单(包括历史订单)
+	pair(必需) : 交易对
+	startTime : 开始时间(ms)
+	endTime : 结束时间(ms)
+*/
+func (b *Binance) GetAllOrders(ctx context.Context, pair quant.CurrencyPair, startTime, endTime int64) ([]*Order, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.AllOrdersURL,
+	}
+	r.SetParam(util.SymbolKey, toSymbol(pair))
+	if startTime != 0 {
+		r.SetParam("startTime", startTime)
+	}
+	if endTime != 0 {
+		r.SetParam("endTime", endTime)
+	}
+	return b.getOrders(ctx, r)
+}
+
+func (b *Binance) getOrders(ctx context.Context, r *mod.ReqParam) ([]*Order, error) {
+	var resp []*orderResponse
+	err := b.signedRequest(ctx, r, &resp)
+	if err != nil {
+		logger.Logger.Error("Binance Service Get Orders Failed", zap.String("url", r.URL), zap.Error(err))
+		return nil, err
+	}
+	orders := make([]*Order, 0, len(resp))
+	for _, o := range resp {
+		order := o.toOrder()
+		order.Pair = b.currencyPair(o.Symbol)
+		orders = append(orders, order)
+	}
+	return orders, nil
+}
+
+// signedRequest 发送签名请求，GET请求按 RequestQuery 策略重试，每次重试重新签名
+func (b *Binance) signedRequest(ctx context.Context, r *mod.ReqParam, out interface{}) error {
+	return b.withRetry(ctx, r, func() error {
+		return b.signedRequestOnce(ctx, r, out)
+	})
+}
+
+// signedRequestOnce 为需要签名的接口附加 apikey、timestamp 与 signature
+func (b *Binance) signedRequestOnce(ctx context.Context, r *mod.ReqParam, out interface{}) error {
+	if r.Query == nil {
+		r.Query = url.Values{}
+	}
+	if err := b.ParamsSigned(&r.Query); err != nil {
+		return err
+	}
+	r.APIKEY = b.accessKey
+	err := b.doRequest(ctx, r, out)
+	if !IsTimestampOutOfWindow(err) {
+		return err
+	}
+	// 时间戳超出 recvWindow 说明请求未被处理，校准时间后重新签名重试一次
+	logger.Logger.Warn("timestamp outside of recvWindow, resync server time ", zap.String("url", r.URL))
+	if syncErr := b.SyncTime(ctx); syncErr != nil {
+		return err
+	}
+	if err := b.ParamsSigned(&r.Query); err != nil {
+		return err
+	}
+	return b.doRequest(ctx, r, out)
+}
+
+// orderResponse 下单、查询、撤单接口返回的订单信息
+type orderResponse struct {
+	Symbol              string          `json:"symbol"`
+	OrderID             int             `json:"orderId"`
+	ClientOrderID       string          `json:"clientOrderId"`
+	OrigClientOrderID   string          `json:"origClientOrderId"` // 撤单响应中原订单的客户端订单号
+	Price               decimal.Decimal `json:"price"`
+	OrigQty             decimal.Decimal `json:"origQty"`
+	ExecutedQty         decimal.Decimal `json:"executedQty"`
+	CummulativeQuoteQty decimal.Decimal `json:"cummulativeQuoteQty"`
+	Status              string          `json:"status"`
+	TimeInForce         string          `json:"timeInForce"`
+	Type                string          `json:"type"`
+	Side                string          `json:"side"`
+	Time                int             `json:"time"`
+	TransactTime        int             `json:"transactTime"`
+	Fills               []fillResponse  `json:"fills"` // 仅 FULL 响应
+}
+
+type fillResponse struct {
+	TradeID         int64           `json:"tradeId"`
+	Price           decimal.Decimal `json:"price"`
+	Qty             decimal.Decimal `json:"qty"`
+	Commission      decimal.Decimal `json:"commission"`
+	CommissionAsset string          `json:"commissionAsset"`
+}
+
+func (o *orderResponse) toOrder() *Order {
+	avgPrice := decimal.Zero
+	if o.CummulativeQuoteQty.IsPositive() && o.ExecutedQty.IsPositive() {
+		avgPrice = o.CummulativeQuoteQty.Div(o.ExecutedQty)
 	}
 	side := BUY
-	if orderSide == "SELL" {
+	if o.Side == "SELL" {
 		side = SELL
 	}
+	orderType := 0
+	switch {
+	case o.Type == "LIMIT_MAKER":
+		orderType = 1
+	case o.TimeInForce == "FOK":
+		orderType = 2
+	case o.TimeInForce == "IOC":
+		orderType = 3
+	}
+	// 手续费资产不同时无法相加，Fee 只在所有成交使用同一资产时汇总
+	var fills []quant.Fill
+	fee, sameAsset := decimal.Zero, true
+	for _, f := range o.Fills {
+		fills = append(fills, quant.Fill{
+			TradeID:         f.TradeID,
+			Price:           f.Price,
+			Amount:          f.Qty,
+			Commission:      f.Commission,
+			CommissionAsset: f.CommissionAsset,
+		})
+		fee = fee.Add(f.Commission)
+		sameAsset = sameAsset && f.CommissionAsset == o.Fills[0].CommissionAsset
+	}
+	if !sameAsset {
+		fee = decimal.Zero
+	}
+	// 撤单响应的 clientOrderId 是撤单请求自身的订单号
+	clientOrderID := o.ClientOrderID
+	if o.OrigClientOrderID != "" {
+		clientOrderID = o.OrigClientOrderID
+	}
+	orderTime := o.Time
+	if orderTime == 0 {
+		orderTime = o.TransactTime
+	}
 	return &Order{
-		Symbol:     symbol,
-		OrderID:    orderID,
-		Price:      util.ToFloat64(price),
-		DealAmount: dealAmount,
-		Amount:     util.ToFloat64(amount),
-		Side:       TradeSide(side),
+		Symbol:     o.Symbol,
+		OrderID:    o.OrderID,
+		OrderID2:   strconv.Itoa(o.OrderID),
+		OrderType:  orderType,
+		Side:       side,
 		AvgPrice:   avgPrice,
-		Status:     ORDER_NEW,
-		OrderTime:  util.ToInt(data["transactTime"]),
-	}, nil
+		Type:       o.Type,
+		Fee:        fee,
+		Price:      o.Price,
+		DealAmount: o.ExecutedQty,
+		Amount:     o.OrigQty,
+		Status:     toTradeStatus(o.Status),
+		OrderTime:  orderTime,
 
+		ClientOrderID: clientOrderID,
+		Fills:         fills,
+	}
+}
+
+// toTradeStatus 将binance订单状态转换为 TradeStatus，ACK响应没有状态时视为新建订单
+func toTradeStatus(status string) TradeStatus {
+	if ts, ok := _INERNAL_ORDER_STATUS_CONVERTER[status]; ok {
+		return ts
+	}
+	return ORDER_NEW
 }
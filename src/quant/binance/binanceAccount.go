@@ -0,0 +1,164 @@
+package binance
+
+import (
+	"context"
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// Balance 单个资产的余额
+type Balance = quant.Balance
+
+// Account 账户信息
+type Account struct {
+	MakerCommission  int64      `json:"makerCommission"` //单位:万分之一
+	TakerCommission  int64      `json:"takerCommission"`
+	BuyerCommission  int64      `json:"buyerCommission"`
+	SellerCommission int64      `json:"sellerCommission"`
+	CanTrade         bool       `json:"canTrade"`
+	CanWithdraw      bool       `json:"canWithdraw"`
+	CanDeposit       bool       `json:"canDeposit"`
+	UpdateTime       int64      `json:"updateTime"`
+	AccountType      string     `json:"accountType"`
+	Balances         []*Balance `json:"balances"`
+	Permissions      []string   `json:"permissions"`
+}
+
+// MakerCommissionRate 挂单手续费率，如 0.001
+func (a *Account) MakerCommissionRate() decimal.Decimal {
+	return decimal.New(a.MakerCommission, -4)
+}
+
+// TakerCommissionRate 吃单手续费率
+func (a *Account) TakerCommissionRate() decimal.Decimal {
+	return decimal.New(a.TakerCommission, -4)
+}
+
+// Balance 获取单个资产的余额，不存在时返回nil
+func (a *Account) Balance(asset string) *Balance {
+	for _, balance := range a.Balances {
+		if balance.Asset == asset {
+			return balance
+		}
+	}
+	return nil
+}
+
+/*
+	获取账户信息
+*/
+func (b *Binance) GetAccount(ctx context.Context) (*Account, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    ACCOUNT_URI,
+	}
+	account := new(Account)
+	err := b.signedRequest(ctx, r, account)
+	if err != nil {
+		Logger.Error("Binance Service Get Account Failed", zap.Error(err))
+		return nil, err
+	}
+	return account, nil
+}
+
+// GetBalances 获取账户中所有资产的余额
+func (b *Binance) GetBalances(ctx context.Context) ([]*Balance, error) {
+	account, err := b.GetAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return account.Balances, nil
+}
+
+// MyTrade 账户成交记录
+type MyTrade struct {
+	Symbol          string          `json:"symbol"`
+	ID              int64           `json:"id"`
+	OrderID         int             `json:"orderId"`
+	OrderListID     int64           `json:"orderListId"`
+	Price           decimal.Decimal `json:"price"`
+	Qty             decimal.Decimal `json:"qty"`
+	QuoteQty        decimal.Decimal `json:"quoteQty"`
+	Commission      decimal.Decimal `json:"commission"`      //手续费
+	CommissionAsset string          `json:"commissionAsset"` //手续费资产
+	Time            int64           `json:"time"`
+	IsBuyer         bool            `json:"isBuyer"`
+	IsMaker         bool            `json:"isMaker"`
+	IsBestMatch     bool            `json:"isBestMatch"`
+}
+
+// 单次请求最多返回的成交数量
+const maxMyTradesLimit = 1000
+
+/*
+	获取账户成交历史，按成交id升序
+	symbol(必需) : 交易对
+	fromId : 从该成交id开始返回，指定fromId时忽略startTime和endTime
+	startTime : 开始时间(ms)
+	endTime : 结束时间(ms)
+	limit : 返回的成交总数，超过1000时按 fromId 自动翻页; 为0时一直翻页到没有更多成交
+	未指定fromId和startTime时，limit不超过1000返回最近的limit笔成交，否则从第一笔成交开始翻页
+*/
+func (b *Binance) GetMyTrades(ctx context.Context, symbol string, fromID, startTime, endTime int64, limit int) ([]*MyTrade, error) {
+	if fromID != 0 {
+		startTime, endTime = 0, 0
+	}
+	// 没有起点时服务端只返回最近的成交，无法向后翻页，需要翻页时从 fromId=0 开始
+	byID := fromID != 0 || startTime == 0 && (limit <= 0 || limit > maxMyTradesLimit)
+	var trades []*MyTrade
+	for limit <= 0 || len(trades) < limit {
+		batch := maxMyTradesLimit
+		if limit > 0 && limit-len(trades) < batch {
+			batch = limit - len(trades)
+		}
+		page, err := b.getMyTradesPage(ctx, symbol, byID, fromID, startTime, endTime, batch)
+		if err != nil {
+			return nil, err
+		}
+		// 按 fromId 翻页时不能同时指定时间，超出 endTime 的成交在本地过滤
+		full := len(page) == batch
+		for i, trade := range page {
+			if endTime != 0 && trade.Time > endTime {
+				page, full = page[:i], false
+				break
+			}
+		}
+		trades = append(trades, page...)
+		if !full {
+			break
+		}
+		fromID, byID = page[len(page)-1].ID+1, true
+	}
+	return trades, nil
+}
+
+func (b *Binance) getMyTradesPage(ctx context.Context, symbol string, byID bool, fromID, startTime, endTime int64, limit int) ([]*MyTrade, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.MyTradesURL,
+	}
+	r.SetParam(util.SymbolKey, symbol)
+	r.SetParam(util.LimitKey, limit)
+	if byID {
+		r.SetParam(util.FromIDKey, fromID)
+	} else {
+		if startTime != 0 {
+			r.SetParam("startTime", startTime)
+		}
+		if endTime != 0 {
+			r.SetParam("endTime", endTime)
+		}
+	}
+	var trades []*MyTrade
+	err := b.signedRequest(ctx, r, &trades)
+	if err != nil {
+		Logger.Error("Binance Service Get My Trades Failed", zap.Error(err))
+		return nil, err
+	}
+	return trades, nil
+}
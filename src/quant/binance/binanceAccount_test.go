@@ -0,0 +1,168 @@
+package binance_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestGetAccount(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/account" || r.Header.Get("X-MBX-APIKEY") != "ak" || !validSignature(r.URL.Query(), "sk") {
+			t.Errorf("unexpected account request %s %v", r.URL.Path, r.URL.Query())
+		}
+		w.Write([]byte(`{"makerCommission":10,"takerCommission":15,"buyerCommission":0,"sellerCommission":0,
+			"canTrade":true,"canWithdraw":false,"canDeposit":true,"updateTime":1600000000000,"accountType":"SPOT",
+			"balances":[{"asset":"BTC","free":"0.5","locked":"0.1"},{"asset":"USDT","free":"1000","locked":"0"}],
+			"permissions":["SPOT"]}`))
+	}))
+	defer srv.Close()
+	account, err := newTestBinance(srv).GetAccount(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !account.CanTrade || account.CanWithdraw || len(account.Permissions) != 1 || account.Permissions[0] != "SPOT" {
+		t.Fatalf("unexpected account %+v", account)
+	}
+	if account.MakerCommissionRate().String() != "0.001" || account.TakerCommissionRate().String() != "0.0015" {
+		t.Fatalf("unexpected commission rates %s %s", account.MakerCommissionRate(), account.TakerCommissionRate())
+	}
+	btc := account.Balance("BTC")
+	if btc == nil || btc.Free.String() != "0.5" || btc.Locked.String() != "0.1" || account.Balance("ETH") != nil {
+		t.Fatalf("unexpected balances %+v", account.Balances)
+	}
+}
+
+type myTradesRequest struct {
+	fromID, startTime, endTime int64
+	limit                      int
+}
+
+// newMyTradesServer 模拟 /api/v3/myTrades，成交id为 1..total，成交时间为 id*1000
+func newMyTradesServer(t *testing.T, total int64, requests *[]myTradesRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !validSignature(query, "sk") || query.Get("symbol") != "BTCUSDT" {
+			t.Errorf("unexpected myTrades request %v", query)
+		}
+		req := myTradesRequest{}
+		req.fromID, _ = strconv.ParseInt(query.Get("fromId"), 10, 64)
+		req.startTime, _ = strconv.ParseInt(query.Get("startTime"), 10, 64)
+		req.endTime, _ = strconv.ParseInt(query.Get("endTime"), 10, 64)
+		req.limit, _ = strconv.Atoi(query.Get("limit"))
+		*requests = append(*requests, req)
+
+		first, last := int64(1), total
+		switch {
+		case query.Get("fromId") != "":
+			if req.fromID > first {
+				first = req.fromID
+			}
+		case req.startTime != 0 || req.endTime != 0:
+			if req.startTime != 0 {
+				first = (req.startTime + 999) / 1000
+			}
+			if req.endTime != 0 && req.endTime/1000 < last {
+				last = req.endTime / 1000
+			}
+		default:
+			first = last - int64(req.limit) + 1
+		}
+		if last-first+1 > int64(req.limit) {
+			last = first + int64(req.limit) - 1
+		}
+		trades := []map[string]interface{}{}
+		for id := first; id <= last; id++ {
+			trades = append(trades, map[string]interface{}{"symbol": "BTCUSDT", "id": id, "orderId": id / 10,
+				"price": "100", "qty": "1", "quoteQty": "100", "commission": "0.1", "commissionAsset": "USDT",
+				"time": id * 1000, "isBuyer": id%2 == 0, "isMaker": true, "isBestMatch": true})
+		}
+		json.NewEncoder(w).Encode(trades)
+	}))
+}
+
+func TestGetMyTradesPaging(t *testing.T) {
+	cases := []struct {
+		name                       string
+		fromID, startTime, endTime int64
+		limit                      int
+		requests                   []myTradesRequest
+		firstID                    int64
+		count                      int
+	}{
+		{
+			name: "all from id", fromID: 1,
+			requests: []myTradesRequest{{1, 0, 0, 1000}, {1001, 0, 0, 1000}, {2001, 0, 0, 1000}},
+			firstID:  1, count: 2500,
+		},
+		{
+			name: "limit", fromID: 1, limit: 1200,
+			requests: []myTradesRequest{{1, 0, 0, 1000}, {1001, 0, 0, 200}},
+			firstID:  1, count: 1200,
+		},
+		{
+			// 第一页按时间查询，之后按 fromId 翻页并在本地截止到 endTime
+			name: "time range", startTime: 101000, endTime: 1600000,
+			requests: []myTradesRequest{{0, 101000, 1600000, 1000}, {1101, 0, 0, 1000}},
+			firstID:  101, count: 1500,
+		},
+		{
+			// 没有起点时从第一笔成交开始翻页
+			name:     "all without start",
+			requests: []myTradesRequest{{0, 0, 0, 1000}, {1001, 0, 0, 1000}, {2001, 0, 0, 1000}},
+			firstID:  1, count: 2500,
+		},
+		{
+			name: "latest", limit: 10,
+			requests: []myTradesRequest{{0, 0, 0, 10}},
+			firstID:  2491, count: 10,
+		},
+		{
+			name: "fromId ignores time", fromID: 2400, startTime: 1000, endTime: 2000,
+			requests: []myTradesRequest{{2400, 0, 0, 1000}},
+			firstID:  2400, count: 101,
+		},
+	}
+	for _, c := range cases {
+		var requests []myTradesRequest
+		srv := newMyTradesServer(t, 2500, &requests)
+		trades, err := newTestBinance(srv).GetMyTrades(context.Background(), "BTCUSDT", c.fromID, c.startTime, c.endTime, c.limit)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(requests) != len(c.requests) {
+			t.Fatalf("%s: requests %+v, want %+v", c.name, requests, c.requests)
+		}
+		for i := range requests {
+			if requests[i] != c.requests[i] {
+				t.Fatalf("%s: request %d %+v, want %+v", c.name, i, requests[i], c.requests[i])
+			}
+		}
+		if len(trades) != c.count {
+			t.Fatalf("%s: got %d trades, want %d", c.name, len(trades), c.count)
+		}
+		for i, trade := range trades {
+			if trade.ID != c.firstID+int64(i) {
+				t.Fatalf("%s: trade %d has id %d, want %d", c.name, i, trade.ID, c.firstID+int64(i))
+			}
+		}
+	}
+
+	var requests []myTradesRequest
+	srv := newMyTradesServer(t, 1, &requests)
+	defer srv.Close()
+	trades, err := newTestBinance(srv).GetMyTrades(context.Background(), "BTCUSDT", 1, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trade := trades[0]
+	if !trade.Commission.Equal(decimal.RequireFromString("0.1")) || trade.CommissionAsset != "USDT" || !trade.IsMaker || trade.IsBuyer {
+		t.Fatalf("unexpected trade %+v", trade)
+	}
+}
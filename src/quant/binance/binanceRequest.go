@@ -2,11 +2,17 @@ package binance
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 	. "tinyquant/src/logger"
 	"tinyquant/src/mod"
+	"tinyquant/src/quant"
 	"tinyquant/src/util"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
@@ -20,14 +26,45 @@ type DepthMessage struct {
 
 // Bid define bid info with price and quantity
 type Bid struct {
-	Price    float64 //价格
-	Quantity float64 //挂单量
+	Price    decimal.Decimal //价格
+	Quantity decimal.Decimal //挂单量
+}
+
+// UnmarshalJSON 解析 ["价格","数量"] 形式的挂单
+func (b *Bid) UnmarshalJSON(data []byte) error {
+	price, quantity, err := parsePriceLevel(data)
+	if err != nil {
+		return err
+	}
+	b.Price, b.Quantity = price, quantity
+	return nil
 }
 
 // Ask define ask info with price and quantity
 type Ask struct {
-	Price    float64
-	Quantity float64
+	Price    decimal.Decimal
+	Quantity decimal.Decimal
+}
+
+// UnmarshalJSON 解析 ["价格","数量"] 形式的挂单
+func (a *Ask) UnmarshalJSON(data []byte) error {
+	price, quantity, err := parsePriceLevel(data)
+	if err != nil {
+		return err
+	}
+	a.Price, a.Quantity = price, quantity
+	return nil
+}
+
+func parsePriceLevel(data []byte) (decimal.Decimal, decimal.Decimal, error) {
+	var level []decimal.Decimal
+	if err := json.Unmarshal(data, &level); err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+	if len(level) < 2 {
+		return decimal.Zero, decimal.Zero, fmt.Errorf("invalid price level %s", string(data))
+	}
+	return level[0], level[1], nil
 }
 
 /*
@@ -45,44 +82,37 @@ func (b *Binance) GetDepthMessage(ctx context.Context, symbol string, limit int3
 		r.SetParam(util.LimitKey, limit)
 	}
 	depthMsg := &DepthMessage{}
-	data, err := util.HttpRequest(ctx, r)
+	err := b.request(ctx, r, depthMsg)
 	if err != nil {
 		Logger.Error("Binance Service Get Depth Failed", zap.Error(err))
 		return nil, err
 	}
-	if _, ok := data["code"]; ok {
+	depthMsg.Time = time.Now()
+	return depthMsg, nil
+}
+
+// GetDepth 获取前size档深度并转换为通用的 Depth
+func (b *Binance) GetDepth(ctx context.Context, pair quant.CurrencyPair, size int) (*Depth, error) {
+	symbol := toSymbol(pair)
+	depthMsg, err := b.GetDepthMessage(ctx, symbol, int32(size))
+	if err != nil {
 		return nil, err
 	}
-
-	depthMsg.LastUpdateID = util.ToInt64(data["lastUpdateId"])
-	for _, bid := range data["bids"].([]interface{}) {
-		_bid := bid.([]interface{})
-		quantity := util.ToFloat64(_bid[1])
-		price := util.ToFloat64(_bid[0])
-		b := &Bid{
-			Quantity: quantity,
-			Price:    price,
-		}
-		depthMsg.Bids = append(depthMsg.Bids, b)
-	}
-	for _, ask := range data["asks"].([]interface{}) {
-		_ask := ask.([]interface{})
-		quantity := util.ToFloat64(_ask[1])
-		price := util.ToFloat64(_ask[0])
-		a := &Ask{
-			Quantity: quantity,
-			Price:    price,
-		}
-		depthMsg.Asks = append(depthMsg.Asks, a)
+	depth := &Depth{Pair: pair, Symbol: symbol, UTime: depthMsg.Time}
+	for _, bid := range depthMsg.Bids {
+		depth.BidList = append(depth.BidList, DepthRecord{Price: bid.Price, Amount: bid.Quantity})
 	}
-
-	return depthMsg, nil
+	for _, ask := range depthMsg.Asks {
+		depth.AskList = append(depth.AskList, DepthRecord{Price: ask.Price, Amount: ask.Quantity})
+	}
+	return depth, nil
 }
 
 type LatestTrades struct {
 	ID           int64  `json:"id"`
 	Price        string `json:"price"`
 	Qty          string `json:"qty"`
+	QuoteQty     string `json:"quoteQty"`
 	Time         int64  `json:"time"`
 	IsBuyerMaker bool   `json:"isBuyerMaker"`
 	IsBestMatch  bool   `json:"isBestMatch"`
@@ -105,16 +135,12 @@ func (b *Binance) GetLatestTrade(ctx context.Context, symbol string, limit int32
 		r.SetParam(util.LimitKey, limit)
 	}
 
-	data, err := util.HttpRequest(ctx, r)
+	p := new(LatestTradesList)
+	err := b.request(ctx, r, p)
 	if err != nil {
 		Logger.Error("Binance Service Get Latest trade Failed", zap.Error(err))
 		return nil, err
 	}
-	if _, ok := data["code"]; ok {
-		return nil, err
-	}
-	p := new(LatestTradesList)
-
 	return p, nil
 }
 
@@ -133,13 +159,13 @@ type HistoryTradesList []*HistoryTrades
 /*
 	Get Hostory Trades
 	symbol(必需) : 品种
-	limit :  默认 100; 最大 1000. 可选值:[5, 10, 20, 50, 100, 500, 1000, 5000]
+	limit :  默认 500; 最大 1000.
 	fromId : 从哪一条成交id开始返回. 缺省返回最近的成交记录。
 */
 func (b *Binance) GetHostoryTrades(ctx context.Context, symbol string, limit int32, fromID int64) (*HistoryTradesList, error) {
 	r := &mod.ReqParam{
 		Method: "GET",
-		URL:    util.DepthURL,
+		URL:    util.HistoryTrades,
 		APIKEY: b.accessKey,
 	}
 	r.SetParam(util.SymbolKey, symbol)
@@ -150,27 +176,24 @@ func (b *Binance) GetHostoryTrades(ctx context.Context, symbol string, limit int
 		r.SetParam(util.FromIDKey, fromID)
 	}
 
-	data, err := util.HttpRequest(ctx, r)
+	p := new(HistoryTradesList)
+	err := b.request(ctx, r, p)
 	if err != nil {
 		Logger.Error("Binance Service Get Hostory Trades Failed", zap.Error(err))
 		return nil, err
 	}
-	if _, ok := data["code"]; ok {
-		return nil, err
-	}
-	p := new(HistoryTradesList)
-
 	return p, nil
 }
 
 type LatestTradesA struct {
-	A int64  `json:"a"`
-	P string `json:"p"`
-	Q string `json:"q"`
-	F int64  `json:"f"`
-	L int64  `json:"l"`
-	T int64  `json:"t"`
-	M bool   `json:"m"`
+	A int64  `json:"a"` // 归集成交ID
+	P string `json:"p"` // 成交价
+	Q string `json:"q"` // 成交量
+	F int64  `json:"f"` // 被归集的首个成交ID
+	L int64  `json:"l"` // 被归集的末个成交ID
+	T int64  `json:"T"` // 成交时间
+	M bool   `json:"m"` // 买方是否为挂单方
+	B bool   `json:"M"` // 是否为最优撮合
 }
 
 type LatestTradesAList []*LatestTradesA
@@ -189,7 +212,7 @@ type LatestTradesAList []*LatestTradesA
 func (b *Binance) GetLatestTradeA(ctx context.Context, symbol string, fromId int64, startTime int64, endTime int64, limit int32) (*LatestTradesAList, error) {
 	r := &mod.ReqParam{
 		Method: "GET",
-		URL:    util.LatestTrades,
+		URL:    util.LatestTradesA,
 	}
 	r.SetParam(util.SymbolKey, symbol)
 	if limit != 0 {
@@ -204,46 +227,311 @@ func (b *Binance) GetLatestTradeA(ctx context.Context, symbol string, fromId int
 	if endTime != 0 {
 		r.SetParam("endTime", endTime)
 	}
-	data, err := util.HttpRequest(ctx, r)
+	p := new(LatestTradesAList)
+	err := b.request(ctx, r, p)
 	if err != nil {
 		Logger.Error("Binance Service Get Latest trade a Failed", zap.Error(err))
 		return nil, err
 	}
-	if _, ok := data["code"]; ok {
-		return nil, err
-	}
-	p := new(LatestTradesAList)
-
 	return p, nil
 }
 
+// 单次请求最多返回的k线数量
+const maxKlineLimit = 1000
+
 /*
 	获取k线数据
-	symbol(必需) : 品种
-	interval(必需) : 时间间隔
-	startTime :开始时间
-	endTime : 结束时间
-	limit : 默认 500; 最大 1000.
+	pair(必需) : 交易对
+	period(必需) : 时间间隔 KLINE_PERIOD_*
+	startTime :开始时间(ms)
+	endTime : 结束时间(ms)
+	limit : 返回的k线总数，超过1000时自动分页; 为0时若同时指定了startTime和endTime则返回区间内全部k线，否则默认 500
 */
+func (b *Binance) GetKlines(ctx context.Context, pair quant.CurrencyPair, period int, startTime, endTime int64, limit int) ([]*Kline, error) {
+	interval, ok := _INERNAL_KLINE_PERIOD_CONVERTER[period]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kline period %d", period)
+	}
+	unlimited := limit <= 0 && startTime != 0 && endTime != 0
+	if limit <= 0 {
+		limit = 500
+	}
+
+	var klines []*Kline
+	for unlimited || len(klines) < limit {
+		batch := maxKlineLimit
+		if !unlimited && limit-len(klines) < batch {
+			batch = limit - len(klines)
+		}
+		// 指定了开始时间则向后翻页，否则从结束时间向前翻页
+		page, err := b.getKlinePage(ctx, pair, interval, startTime, endTime, batch)
+		if err != nil {
+			return nil, err
+		}
+		if startTime != 0 {
+			klines = append(klines, page...)
+		} else {
+			klines = append(page, klines...)
+		}
+		if len(page) < batch {
+			break
+		}
+		if startTime != 0 {
+			startTime = page[len(page)-1].CloseTime + 1
+			if endTime != 0 && startTime > endTime {
+				break
+			}
+		} else {
+			endTime = page[0].OpenTime - 1
+		}
+	}
+	return klines, nil
+}
+
+func (b *Binance) getKlinePage(ctx context.Context, pair quant.CurrencyPair, interval string, startTime, endTime int64, limit int) ([]*Kline, error) {
+	symbol := toSymbol(pair)
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.KlinesURL,
+	}
+	r.SetParam(util.SymbolKey, symbol)
+	r.SetParam("interval", interval)
+	r.SetParam(util.LimitKey, limit)
+	if startTime != 0 {
+		r.SetParam("startTime", startTime)
+	}
+	if endTime != 0 {
+		r.SetParam("endTime", endTime)
+	}
+	var rows [][]interface{}
+	err := b.request(ctx, r, &rows)
+	if err != nil {
+		Logger.Error("Binance Service Get Klines Failed", zap.Error(err))
+		return nil, err
+	}
+
+	klines := make([]*Kline, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 11 {
+			return nil, fmt.Errorf("invalid kline row %v", row)
+		}
+		p := &fieldParser{source: "klines"}
+		openTime := p.int64("openTime", row[0])
+		klines = append(klines, &Kline{
+			Pair:             pair,
+			Symbol:           symbol,
+			Timestamp:        openTime / 1000,
+			OpenTime:         openTime,
+			Open:             p.decimal("open", row[1]),
+			High:             p.decimal("high", row[2]),
+			Low:              p.decimal("low", row[3]),
+			Close:            p.decimal("close", row[4]),
+			Vol:              p.decimal("volume", row[5]),
+			CloseTime:        p.int64("closeTime", row[6]),
+			QuoteVol:         p.decimal("quoteVolume", row[7]),
+			TradeCount:       p.int64("trades", row[8]),
+			TakerBuyVol:      p.decimal("takerBuyVolume", row[9]),
+			TakerBuyQuoteVol: p.decimal("takerBuyQuoteVolume", row[10]),
+		})
+		if err := p.err(); err != nil {
+			return nil, err
+		}
+	}
+	return klines, nil
+}
+
+type AvgPrice struct {
+	Mins  int             `json:"mins"` // 统计时长(分钟)
+	Price decimal.Decimal `json:"price"`
+}
 
 /*
 	获取平均价格
 	symbol(必需) : 品种
 */
+func (b *Binance) GetAvgPrice(ctx context.Context, symbol string) (*AvgPrice, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.AvgPriceURL,
+	}
+	r.SetParam(util.SymbolKey, symbol)
+	p := new(AvgPrice)
+	err := b.request(ctx, r, p)
+	if err != nil {
+		Logger.Error("Binance Service Get Avg Price Failed", zap.Error(err))
+		return nil, err
+	}
+	return p, nil
+}
+
+const avgPriceCacheTTL = 30 * time.Second
+
+// avgPriceCache 按交易对缓存平均价，下单校验时避免每次都请求 /avgPrice
+type avgPriceCache struct {
+	mu     sync.Mutex
+	prices map[string]avgPriceEntry
+}
+
+type avgPriceEntry struct {
+	price      decimal.Decimal
+	updateTime time.Time
+}
+
+func newAvgPriceCache() *avgPriceCache {
+	return &avgPriceCache{prices: make(map[string]avgPriceEntry)}
+}
+
+// cachedAvgPrice 返回缓存的平均价，超过 avgPriceCacheTTL 后重新获取
+func (b *Binance) cachedAvgPrice(ctx context.Context, symbol string) (decimal.Decimal, error) {
+	cache := b.avgPrices
+	if cache == nil {
+		p, err := b.GetAvgPrice(ctx, symbol)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		return p.Price, nil
+	}
+	cache.mu.Lock()
+	entry, ok := cache.prices[symbol]
+	cache.mu.Unlock()
+	if ok && time.Since(entry.updateTime) < avgPriceCacheTTL {
+		return entry.price, nil
+	}
+	p, err := b.GetAvgPrice(ctx, symbol)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	cache.mu.Lock()
+	cache.prices[symbol] = avgPriceEntry{price: p.Price, updateTime: time.Now()}
+	cache.mu.Unlock()
+	return p.Price, nil
+}
+
+type Ticker24hr struct {
+	Symbol             string          `json:"symbol"`
+	PriceChange        decimal.Decimal `json:"priceChange"`
+	PriceChangePercent decimal.Decimal `json:"priceChangePercent"`
+	WeightedAvgPrice   decimal.Decimal `json:"weightedAvgPrice"`
+	PrevClosePrice     decimal.Decimal `json:"prevClosePrice"`
+	LastPrice          decimal.Decimal `json:"lastPrice"`
+	LastQty            decimal.Decimal `json:"lastQty"`
+	BidPrice           decimal.Decimal `json:"bidPrice"`
+	BidQty             decimal.Decimal `json:"bidQty"`
+	AskPrice           decimal.Decimal `json:"askPrice"`
+	AskQty             decimal.Decimal `json:"askQty"`
+	OpenPrice          decimal.Decimal `json:"openPrice"`
+	HighPrice          decimal.Decimal `json:"highPrice"`
+	LowPrice           decimal.Decimal `json:"lowPrice"`
+	Volume             decimal.Decimal `json:"volume"`
+	QuoteVolume        decimal.Decimal `json:"quoteVolume"`
+	OpenTime           int64           `json:"openTime"`
+	CloseTime          int64           `json:"closeTime"`
+	FirstID            int64           `json:"firstId"`
+	LastID             int64           `json:"lastId"`
+	Count              int64           `json:"count"`
+}
+
+// ToTicker 转换为通用的 Ticker
+func (t *Ticker24hr) ToTicker() *Ticker {
+	return &Ticker{
+		Symbol: t.Symbol,
+		Last:   t.LastPrice,
+		Buy:    t.BidPrice,
+		Sell:   t.AskPrice,
+		High:   t.HighPrice,
+		Low:    t.LowPrice,
+		Vol:    t.Volume,
+		Date:   uint64(t.CloseTime),
+	}
+}
 
 /*
 	获取24小时内价格变化
-	symbol : 品种
+	symbol : 品种，为空时返回全部交易对
 */
+func (b *Binance) Get24hrTicker(ctx context.Context, symbol string) ([]*Ticker24hr, error) {
+	var tickers []*Ticker24hr
+	err := b.getTickers(ctx, util.Ticker24hrURL, symbol, &tickers)
+	if err != nil {
+		Logger.Error("Binance Service Get 24hr Ticker Failed", zap.Error(err))
+		return nil, err
+	}
+	return tickers, nil
+}
+
+// GetTicker 获取单个交易对的24小时行情
+func (b *Binance) GetTicker(ctx context.Context, pair quant.CurrencyPair) (*Ticker, error) {
+	if pair.IsZero() {
+		return nil, errors.New("currency pair is required")
+	}
+	tickers, err := b.Get24hrTicker(ctx, toSymbol(pair))
+	if err != nil {
+		return nil, err
+	}
+	if len(tickers) == 0 {
+		return nil, fmt.Errorf("no ticker for %s", pair)
+	}
+	ticker := tickers[0].ToTicker()
+	ticker.Pair = pair
+	return ticker, nil
+}
+
+type PriceTicker struct {
+	Symbol string          `json:"symbol"`
+	Price  decimal.Decimal `json:"price"`
+}
 
 /*
 	获取交易对最新价格
-	symbol : 品种
+	symbol : 品种，为空时返回全部交易对
 */
+func (b *Binance) GetPriceTicker(ctx context.Context, symbol string) ([]*PriceTicker, error) {
+	var tickers []*PriceTicker
+	err := b.getTickers(ctx, util.TickerPriceURL, symbol, &tickers)
+	if err != nil {
+		Logger.Error("Binance Service Get Price Ticker Failed", zap.Error(err))
+		return nil, err
+	}
+	return tickers, nil
+}
+
+type BookTicker struct {
+	Symbol   string          `json:"symbol"`
+	BidPrice decimal.Decimal `json:"bidPrice"`
+	BidQty   decimal.Decimal `json:"bidQty"`
+	AskPrice decimal.Decimal `json:"askPrice"`
+	AskQty   decimal.Decimal `json:"askQty"`
+}
 
 /*
 	获取当前最优挂单（最高买单，最低卖单）
-	symbol : 品种
+	symbol : 品种，为空时返回全部交易对
 */
+func (b *Binance) GetBookTicker(ctx context.Context, symbol string) ([]*BookTicker, error) {
+	var tickers []*BookTicker
+	err := b.getTickers(ctx, util.BookTickerURL, symbol, &tickers)
+	if err != nil {
+		Logger.Error("Binance Service Get Book Ticker Failed", zap.Error(err))
+		return nil, err
+	}
+	return tickers, nil
+}
+
+// getTickers 指定symbol时接口返回单个对象，否则返回数组，统一解析为数组
+func (b *Binance) getTickers(ctx context.Context, url, symbol string, out interface{}) error {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    url,
+	}
+	if symbol == "" {
+		return b.request(ctx, r, out)
+	}
+	r.SetParam(util.SymbolKey, symbol)
+	var raw json.RawMessage
+	if err := b.request(ctx, r, &raw); err != nil {
+		return err
+	}
+	return json.Unmarshal(append(append([]byte{'['}, raw...), ']'), out)
+}
 
 /////////////////////////////*********websocket行情推送**********//////////////////////////////////////
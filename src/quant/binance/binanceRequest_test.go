@@ -0,0 +1,224 @@
+package binance_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+)
+
+type klinePageRequest struct {
+	startTime, endTime int64
+	limit              int
+}
+
+// newKlineServer 模拟 /api/v3/klines，提供从 firstOpen 开始的 total 根1分钟k线
+func newKlineServer(firstOpen int64, total int, pages *[]klinePageRequest) *httptest.Server {
+	const minute = int64(60000)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		startTime, _ := strconv.ParseInt(query.Get("startTime"), 10, 64)
+		endTime, _ := strconv.ParseInt(query.Get("endTime"), 10, 64)
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		*pages = append(*pages, klinePageRequest{startTime, endTime, limit})
+
+		first, last := 0, total-1
+		if startTime != 0 && startTime > firstOpen {
+			first = int((startTime - firstOpen + minute - 1) / minute)
+		}
+		if endTime != 0 && int((endTime-firstOpen)/minute) < last {
+			last = int((endTime - firstOpen) / minute)
+		}
+		// 只指定了结束时间时返回最近的 limit 根
+		if startTime == 0 && last-first+1 > limit {
+			first = last - limit + 1
+		}
+		if last-first+1 > limit {
+			last = first + limit - 1
+		}
+		rows := [][]interface{}{}
+		for i := first; i <= last; i++ {
+			open := firstOpen + int64(i)*minute
+			rows = append(rows, []interface{}{open, "1", "2", "0.5", "1.5", "10", open + minute - 1, "15", 3, "4", "6", "0"})
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+}
+
+// checkKlines k线按时间升序且连续，没有重复
+func checkKlines(t *testing.T, klines []*binance.Kline, firstOpen int64, count int) {
+	t.Helper()
+	if len(klines) != count {
+		t.Fatalf("got %d klines, want %d", len(klines), count)
+	}
+	for i, k := range klines {
+		if want := firstOpen + int64(i)*60000; k.OpenTime != want {
+			t.Fatalf("kline %d opens at %d, want %d", i, k.OpenTime, want)
+		}
+	}
+}
+
+func TestGetKlinesPaging(t *testing.T) {
+	const (
+		firstOpen = int64(1600000000000)
+		total     = 2500
+		minute    = int64(60000)
+	)
+	ctx := context.Background()
+	lastClose := firstOpen + total*minute - 1
+
+	cases := []struct {
+		name               string
+		startTime, endTime int64
+		limit              int
+		pages              []klinePageRequest
+		klineFirst         int64
+		count              int
+	}{
+		{
+			name: "forward", startTime: firstOpen, limit: 2300,
+			pages: []klinePageRequest{
+				{firstOpen, 0, 1000},
+				{firstOpen + 1000*minute, 0, 1000},
+				{firstOpen + 2000*minute, 0, 300},
+			},
+			klineFirst: firstOpen, count: 2300,
+		},
+		{
+			name: "range", startTime: firstOpen + 100*minute, endTime: lastClose,
+			pages: []klinePageRequest{
+				{firstOpen + 100*minute, lastClose, 1000},
+				{firstOpen + 1100*minute, lastClose, 1000},
+				{firstOpen + 2100*minute, lastClose, 1000},
+			},
+			klineFirst: firstOpen + 100*minute, count: 2400,
+		},
+		{
+			name: "backward", endTime: lastClose, limit: 1500,
+			pages: []klinePageRequest{
+				{0, lastClose, 1000},
+				{0, firstOpen + 1500*minute - 1, 500},
+			},
+			klineFirst: firstOpen + 1000*minute, count: 1500,
+		},
+	}
+	for _, c := range cases {
+		var pages []klinePageRequest
+		srv := newKlineServer(firstOpen, total, &pages)
+		klines, err := newTestBinance(srv).GetKlines(ctx, quant.BTC_USDT, quant.KLINE_PERIOD_1MIN, c.startTime, c.endTime, c.limit)
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(pages) != len(c.pages) {
+			t.Fatalf("%s: requested pages %+v, want %+v", c.name, pages, c.pages)
+		}
+		for i := range pages {
+			if pages[i] != c.pages[i] {
+				t.Fatalf("%s: page %d requested %+v, want %+v", c.name, i, pages[i], c.pages[i])
+			}
+		}
+		checkKlines(t, klines, c.klineFirst, c.count)
+	}
+}
+
+// tickerFixtures 各行情接口按交易对返回的数据
+var tickerFixtures = map[string]map[string]string{
+	"/api/v3/ticker/24hr": {
+		"BTCUSDT": `{"symbol":"BTCUSDT","priceChange":"100","priceChangePercent":"1.1","lastPrice":"9100","bidPrice":"9099",
+			"askPrice":"9101","openPrice":"9000","highPrice":"9200","lowPrice":"8900","volume":"1234.5","closeTime":1600000000000,"count":10}`,
+		"ETHUSDT": `{"symbol":"ETHUSDT","lastPrice":"350","bidPrice":"349.9","askPrice":"350.1","closeTime":1600000000000}`,
+	},
+	"/api/v3/ticker/price": {
+		"BTCUSDT": `{"symbol":"BTCUSDT","price":"9100"}`,
+		"ETHUSDT": `{"symbol":"ETHUSDT","price":"350"}`,
+	},
+	"/api/v3/ticker/bookTicker": {
+		"BTCUSDT": `{"symbol":"BTCUSDT","bidPrice":"9099","bidQty":"1.5","askPrice":"9101","askQty":"0.5"}`,
+		"ETHUSDT": `{"symbol":"ETHUSDT","bidPrice":"349.9","bidQty":"10","askPrice":"350.1","askQty":"20"}`,
+	},
+}
+
+// newTickerServer 指定 symbol 时返回单个对象，否则返回全部交易对的数组
+func newTickerServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbol := r.URL.Query().Get("symbol")
+		if r.URL.Path == "/api/v3/avgPrice" {
+			if symbol != "BTCUSDT" {
+				t.Errorf("unexpected avgPrice symbol %q", symbol)
+			}
+			w.Write([]byte(`{"mins":5,"price":"9095.5"}`))
+			return
+		}
+		fixtures, ok := tickerFixtures[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if symbol != "" {
+			w.Write([]byte(fixtures[symbol]))
+			return
+		}
+		w.Write([]byte("[" + fixtures["BTCUSDT"] + "," + fixtures["ETHUSDT"] + "]"))
+	}))
+}
+
+func TestGetTickers(t *testing.T) {
+	srv := newTickerServer(t)
+	defer srv.Close()
+	b := newTestBinance(srv)
+	ctx := context.Background()
+
+	for _, symbol := range []string{"BTCUSDT", ""} {
+		want := 1
+		if symbol == "" {
+			want = 2
+		}
+		tickers, err := b.Get24hrTicker(ctx, symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tickers) != want || tickers[0].Symbol != "BTCUSDT" || tickers[0].LastPrice.String() != "9100" ||
+			tickers[0].PriceChangePercent.String() != "1.1" || tickers[0].Count != 10 {
+			t.Fatalf("symbol %q: unexpected 24hr tickers %+v", symbol, tickers)
+		}
+		prices, err := b.GetPriceTicker(ctx, symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prices) != want || prices[0].Symbol != "BTCUSDT" || prices[0].Price.String() != "9100" {
+			t.Fatalf("symbol %q: unexpected price tickers %+v", symbol, prices)
+		}
+		books, err := b.GetBookTicker(ctx, symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(books) != want || books[0].BidPrice.String() != "9099" || books[0].AskQty.String() != "0.5" {
+			t.Fatalf("symbol %q: unexpected book tickers %+v", symbol, books)
+		}
+		if want == 2 && (tickers[1].Symbol != "ETHUSDT" || prices[1].Price.String() != "350" || books[1].BidQty.String() != "10") {
+			t.Fatalf("unexpected second tickers %+v %+v %+v", tickers[1], prices[1], books[1])
+		}
+	}
+
+	ticker, err := b.GetTicker(ctx, quant.BTC_USDT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ticker.Pair.Equal(quant.BTC_USDT) || ticker.Last.String() != "9100" || ticker.Buy.String() != "9099" ||
+		ticker.Sell.String() != "9101" || ticker.Vol.String() != "1234.5" || ticker.Date != 1600000000000 {
+		t.Fatalf("unexpected ticker %+v", ticker)
+	}
+
+	avg, err := b.GetAvgPrice(ctx, "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if avg.Mins != 5 || avg.Price.String() != "9095.5" {
+		t.Fatalf("unexpected avg price %+v", avg)
+	}
+}
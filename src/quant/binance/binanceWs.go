@@ -1,78 +1,107 @@
 package binance
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
 	"tinyquant/src/util"
 
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 )
 
 type BinanceWs struct {
-	baseURL        string
-	proxyUrl       string
-	tickerCallback func(*Ticker)
-	depthCallback  func(*Depth)
-	tradeCallback  func(*Trade)
-	klineCallback  func(*Kline, int)
-	wsConns        []*util.WsConn
+	baseURL            string
+	proxyUrl           string
+	tickerCallback     func(*Ticker)
+	depthCallback      func(*Depth)
+	tradeCallback      func(*Trade)
+	klineCallback      func(*Kline, int)
+	aggTradeCallback   func(*Trade)
+	bookTickerCallback func(*BookTicker)
+
+	executionReportCallback func(*ExecutionReport)
+	accountPositionCallback func(*AccountPosition)
+	balanceUpdateCallback   func(*BalanceUpdate)
+	listenKeyKeepAlive      time.Duration
+	listenKeyRetry          time.Duration
+
+	wsConns   []*util.WsConn
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	ctx       context.Context // 后台REST请求使用，Close 时取消
+	cancel    context.CancelFunc
+
+	// 组合流
+	streamMu          sync.Mutex
+	handlers          map[string]func([]byte) error
+	streamConns       []*streamConn
+	maxStreamsPerConn int
+	requestID         int64
+	flushOnce         sync.Once
 }
 
+var _ quant.Stream = (*BinanceWs)(nil)
+
 func NewBinanceWS(baseURL, ProxyURL string) *BinanceWs {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &BinanceWs{
-		baseURL:  baseURL,
-		proxyUrl: ProxyURL,
+		baseURL:            baseURL,
+		proxyUrl:           ProxyURL,
+		closeCh:            make(chan struct{}),
+		ctx:                ctx,
+		cancel:             cancel,
+		maxStreamsPerConn:  defaultMaxStreamsPerConn,
+		listenKeyKeepAlive: listenKeyKeepAliveInterval,
+		listenKeyRetry:     listenKeyRetryInterval,
 	}
 }
 
-type Ticker struct {
-	symbol string  `json:"omitempty"`
-	Last   float64 `json:"last,string"`
-	Buy    float64 `json:"buy,string"`
-	Sell   float64 `json:"sell,string"`
-	High   float64 `json:"high,string"`
-	Low    float64 `json:"low,string"`
-	Vol    float64 `json:"vol,string"`
-	Date   uint64  `json:"date"` // 单位:ms
+// ErrCallbackNotSet 订阅前未注册对应的回调
+var ErrCallbackNotSet = errors.New("callback not set, register it before subscribing")
+
+func (bw *BinanceWs) SetTickerCallback(callback func(*Ticker)) {
+	bw.tickerCallback = callback
 }
 
-type Trade struct {
-	Tid    int64     `json:"tid"`
-	Type   TradeSide `json:"type"`
-	Amount float64   `json:"amount,string"`
-	Price  float64   `json:"price,string"`
-	Date   int64     `json:"date_ms"`
-	symbol string    `json:"omitempty"`
+func (bw *BinanceWs) SetDepthCallback(callback func(*Depth)) {
+	bw.depthCallback = callback
 }
 
-type Depth struct {
-	//ContractType string //for future
-	symbol  string
-	UTime   time.Time
-	AskList DepthRecords // Descending order
-	BidList DepthRecords // Descending order
+func (bw *BinanceWs) SetTradeCallback(callback func(*Trade)) {
+	bw.tradeCallback = callback
 }
 
-type DepthRecord struct {
-	Price  float64
-	Amount float64
+func (bw *BinanceWs) SetAggTradeCallback(callback func(*Trade)) {
+	bw.aggTradeCallback = callback
 }
 
-type DepthRecords []DepthRecord
+// SetKlineCallback 回调参数为k线及其周期 KLINE_PERIOD_*
+func (bw *BinanceWs) SetKlineCallback(callback func(*Kline, int)) {
+	bw.klineCallback = callback
+}
 
-type Kline struct {
-	symbol    string
-	Timestamp int64
-	Open      float64
-	Close     float64
-	High      float64
-	Low       float64
-	Vol       float64
+func (bw *BinanceWs) SetBookTickerCallback(callback func(*BookTicker)) {
+	bw.bookTickerCallback = callback
 }
 
+// 行情数据结构在各交易所间共用
+type (
+	Ticker       = quant.Ticker
+	Trade        = quant.Trade
+	Depth        = quant.Depth
+	DepthRecord  = quant.DepthRecord
+	DepthRecords = quant.DepthRecords
+	Kline        = quant.Kline
+)
+
 var _INERNAL_KLINE_PERIOD_REVERTER = map[string]int{
 	"1m":  KLINE_PERIOD_1MIN,
 	"3m":  KLINE_PERIOD_3MIN,
@@ -113,9 +142,13 @@ var _INERNAL_KLINE_PERIOD_CONVERTER = map[int]string{
 /*
 	订阅深度信息
 */
-func (bw *BinanceWs) SubscribeDepth(symbol string, size int) error {
+func (bw *BinanceWs) SubscribeDepth(pair quant.CurrencyPair, size int) error {
+	if bw.depthCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
 
-	endpoint := fmt.Sprintf("%s/%s@depth%d@100ms", bw.baseURL, symbol, size)
+	stream := fmt.Sprintf("%s@depth%d@100ms", strings.ToLower(symbol), size)
 
 	handle := func(msg []byte) error {
 		rawDepth := struct {
@@ -129,92 +162,369 @@ func (bw *BinanceWs) SubscribeDepth(symbol string, size int) error {
 			Logger.Error("json unmarshal error for ", zap.Error(err))
 			return err
 		}
-		depth := bw.parseDepthData(rawDepth.Bids, rawDepth.Asks)
-		depth.symbol = symbol
+		depth, err := bw.parseDepthData(rawDepth.Bids, rawDepth.Asks)
+		if err != nil {
+			return err
+		}
+		depth.Pair, depth.Symbol = pair, symbol
 		depth.UTime = time.Now()
 		bw.depthCallback(depth)
 		return nil
 	}
-	err := util.NewWsConn(endpoint, util.ProxyURL, handle).NewWebsocket()
-	if err != nil {
-		Logger.Error("[ws] SubscribeDepth failed ", zap.Error(err))
-	}
+	return bw.subscribe(stream, handle)
+}
 
+func (bw *BinanceWs) connect(endpoint string, handle func([]byte) error) error {
+	conn := util.NewWsConn(endpoint, bw.proxyUrl, handle)
+	if err := conn.NewWebsocket(); err != nil {
+		Logger.Error("[ws] subscribe failed ", zap.String("endpoint", endpoint), zap.Error(err))
+		return err
+	}
+	bw.wsConns = append(bw.wsConns, conn)
 	return nil
 }
 
-func (bw *BinanceWs) parseDepthData(bids, asks [][]interface{}) *Depth {
-	depth := new(Depth)
-	for _, v := range bids {
-		depth.BidList = append(depth.BidList, DepthRecord{util.ToFloat64(v[0]), util.ToFloat64(v[1])})
+// Close 关闭所有订阅连接
+func (bw *BinanceWs) Close() {
+	bw.closeOnce.Do(func() {
+		close(bw.closeCh)
+		bw.cancel()
+	})
+	for _, conn := range bw.wsConns {
+		conn.Close()
+	}
+	bw.wsConns = nil
+	bw.streamMu.Lock()
+	for _, sc := range bw.streamConns {
+		sc.conn.Close()
 	}
+	bw.streamConns = nil
+	bw.handlers = nil
+	bw.streamMu.Unlock()
+}
 
-	for _, v := range asks {
-		depth.AskList = append(depth.AskList, DepthRecord{util.ToFloat64(v[0]), util.ToFloat64(v[1])})
+func (bw *BinanceWs) isClosed() bool {
+	select {
+	case <-bw.closeCh:
+		return true
+	default:
+		return false
 	}
-	return depth
 }
 
-func (bw *BinanceWs) SubscribeKline(symbol string, period int) error {
-	periodS, isOk := _INERNAL_KLINE_PERIOD_CONVERTER[period]
-	if isOk != true {
-		periodS = "M1"
+func (bw *BinanceWs) parseDepthData(bids, asks [][]interface{}) (*Depth, error) {
+	p := &fieldParser{source: "depth"}
+	depth := new(Depth)
+	depth.BidList = p.depthRecords("bids", bids)
+	depth.AskList = p.depthRecords("asks", asks)
+	return depth, p.err()
+}
+
+func (bw *BinanceWs) SubscribeKline(pair quant.CurrencyPair, period int) error {
+	if bw.klineCallback == nil {
+		return ErrCallbackNotSet
 	}
-	endpoint := fmt.Sprintf("%s/%s@kline_%s", bw.baseURL, symbol, periodS)
+	symbol := toSymbol(pair)
+	periodS, ok := _INERNAL_KLINE_PERIOD_CONVERTER[period]
+	if !ok {
+		return fmt.Errorf("unsupported kline period %d", period)
+	}
+	stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), periodS)
 
 	handle := func(msg []byte) error {
-		datamap := make(map[string]interface{})
-		err := json.Unmarshal(msg, &datamap)
+		datamap, msgType, err := parseEvent(msg)
 		if err != nil {
-			fmt.Println("json unmarshal error for ", string(msg))
 			return err
 		}
 
-		msgType, isOk := datamap["e"].(string)
-		if !isOk {
-			return errors.New("no message type")
-		}
-
 		switch msgType {
 		case "kline":
-			k := datamap["k"].(map[string]interface{})
-			period := _INERNAL_KLINE_PERIOD_REVERTER[k["i"].(string)]
-			kline := bw.parseKlineData(k)
-			kline.symbol = symbol
+			k, isOk := datamap["k"].(map[string]interface{})
+			if !isOk {
+				return (&fieldParser{source: "kline"}).fail("k", fmt.Errorf("unexpected %T", datamap["k"])).err()
+			}
+			interval, _ := k["i"].(string)
+			period := _INERNAL_KLINE_PERIOD_REVERTER[interval]
+			kline, err := bw.parseKlineData(k)
+			if err != nil {
+				return err
+			}
+			kline.Pair, kline.Symbol = pair, symbol
 			bw.klineCallback(kline, period)
 			return nil
 		default:
 			return errors.New("unknown message " + msgType)
 		}
 	}
-	err := util.NewWsConn(endpoint, util.ProxyURL, handle).NewWebsocket()
+	return bw.subscribe(stream, handle)
+}
+
+/*
+	订阅24小时行情
+*/
+func (bw *BinanceWs) SubscribeTicker(pair quant.CurrencyPair) error {
+	if bw.tickerCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
+	stream := fmt.Sprintf("%s@ticker", strings.ToLower(symbol))
+	handle := func(msg []byte) error {
+		datamap, msgType, err := parseEvent(msg)
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case "24hrTicker":
+			ticker, err := bw.parseTickerData(datamap)
+			if err != nil {
+				return err
+			}
+			ticker.Pair, ticker.Symbol = pair, symbol
+			bw.tickerCallback(ticker)
+			return nil
+		default:
+			return errors.New("unknown message " + msgType)
+		}
+	}
+	return bw.subscribe(stream, handle)
+}
+
+/*
+	订阅逐笔成交
+*/
+func (bw *BinanceWs) SubscribeTrade(pair quant.CurrencyPair) error {
+	if bw.tradeCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
+	stream := fmt.Sprintf("%s@trade", strings.ToLower(symbol))
+	handle := func(msg []byte) error {
+		datamap, msgType, err := parseEvent(msg)
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case "trade":
+			trade, err := bw.parseTradeData(datamap, "t")
+			if err != nil {
+				return err
+			}
+			trade.Pair, trade.Symbol = pair, symbol
+			bw.tradeCallback(trade)
+			return nil
+		default:
+			return errors.New("unknown message " + msgType)
+		}
+	}
+	return bw.subscribe(stream, handle)
+}
+
+/*
+	订阅归集成交
+*/
+func (bw *BinanceWs) SubscribeAggTrade(pair quant.CurrencyPair) error {
+	if bw.aggTradeCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
+	stream := fmt.Sprintf("%s@aggTrade", strings.ToLower(symbol))
+	handle := func(msg []byte) error {
+		datamap, msgType, err := parseEvent(msg)
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case "aggTrade":
+			trade, err := bw.parseTradeData(datamap, "a")
+			if err != nil {
+				return err
+			}
+			trade.Pair, trade.Symbol = pair, symbol
+			bw.aggTradeCallback(trade)
+			return nil
+		default:
+			return errors.New("unknown message " + msgType)
+		}
+	}
+	return bw.subscribe(stream, handle)
+}
+
+/*
+	订阅最优挂单，推送没有事件类型字段
+*/
+func (bw *BinanceWs) SubscribeBookTicker(pair quant.CurrencyPair) error {
+	if bw.bookTickerCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
+	stream := fmt.Sprintf("%s@bookTicker", strings.ToLower(symbol))
+	handle := func(msg []byte) error {
+		datamap, err := decodeEvent(msg)
+		if err != nil {
+			return err
+		}
+		bookTicker, err := bw.parseBookTickerData(datamap)
+		if err != nil {
+			return err
+		}
+		bw.bookTickerCallback(bookTicker)
+		return nil
+	}
+	return bw.subscribe(stream, handle)
+}
+
+// decodeEvent 解析推送消息，数字保留为 json.Number 以免大整数丢失精度
+func decodeEvent(msg []byte) (map[string]interface{}, error) {
+	datamap := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(msg))
+	decoder.UseNumber()
+	if err := decoder.Decode(&datamap); err != nil {
+		Logger.Error("json unmarshal error for ", zap.ByteString("msg", msg), zap.Error(err))
+		return nil, err
+	}
+	return datamap, nil
+}
+
+// parseEvent 解析推送消息并返回事件类型
+func parseEvent(msg []byte) (map[string]interface{}, string, error) {
+	datamap, err := decodeEvent(msg)
 	if err != nil {
-		Logger.Error("[ws] SubscribeDepth failed ", zap.Error(err))
+		return nil, "", err
 	}
-	return nil
+	msgType, isOk := datamap["e"].(string)
+	if !isOk {
+		return nil, "", errors.New("no message type")
+	}
+	return datamap, msgType, nil
+}
+
+/*
+	fieldParser 逐个解析字段并记录所有格式错误的字段
+	解析完成后调用 err，存在错误时记录日志并返回 *ParseError
+*/
+type fieldParser struct {
+	source string
+	fields []string
 }
 
-func (bnWs *BinanceWs) parseKlineData(k map[string]interface{}) *Kline {
+func (p *fieldParser) fail(field string, err error) *fieldParser {
+	p.fields = append(p.fields, field+": "+err.Error())
+	return p
+}
+
+func (p *fieldParser) err() error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	Logger.Error("Binance Service Parse Failed", zap.String("source", p.source), zap.Strings("fields", p.fields))
+	return &ParseError{Source: p.source, Fields: p.fields}
+}
+
+func (p *fieldParser) decimal(field string, v interface{}) decimal.Decimal {
+	d, err := util.ParseDecimal(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return d
+}
+
+func (p *fieldParser) int64(field string, v interface{}) int64 {
+	i, err := util.ParseInt64(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return i
+}
+
+func (p *fieldParser) uint64(field string, v interface{}) uint64 {
+	u, err := util.ParseUint64(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return u
+}
+
+// depthRecords 解析 [["价格","数量"],...] 形式的挂单
+func (p *fieldParser) depthRecords(field string, levels [][]interface{}) DepthRecords {
+	records := make(DepthRecords, 0, len(levels))
+	for i, level := range levels {
+		name := fmt.Sprintf("%s[%d]", field, i)
+		if len(level) < 2 {
+			p.fail(name, fmt.Errorf("invalid price level %v", level))
+			continue
+		}
+		records = append(records, DepthRecord{Price: p.decimal(name, level[0]), Amount: p.decimal(name, level[1])})
+	}
+	return records
+}
+
+func (bnWs *BinanceWs) parseKlineData(k map[string]interface{}) (*Kline, error) {
+	p := &fieldParser{source: "kline"}
+	openTime := p.int64("t", k["t"])
 	kline := &Kline{
-		Timestamp: int64(util.ToInt(k["t"])) / 1000,
-		Open:      util.ToFloat64(k["o"]),
-		Close:     util.ToFloat64(k["c"]),
-		High:      util.ToFloat64(k["h"]),
-		Low:       util.ToFloat64(k["l"]),
-		Vol:       util.ToFloat64(k["v"]),
+		Timestamp:        openTime / 1000,
+		Open:             p.decimal("o", k["o"]),
+		Close:            p.decimal("c", k["c"]),
+		High:             p.decimal("h", k["h"]),
+		Low:              p.decimal("l", k["l"]),
+		Vol:              p.decimal("v", k["v"]),
+		OpenTime:         openTime,
+		CloseTime:        p.int64("T", k["T"]),
+		QuoteVol:         p.decimal("q", k["q"]),
+		TradeCount:       p.int64("n", k["n"]),
+		TakerBuyVol:      p.decimal("V", k["V"]),
+		TakerBuyQuoteVol: p.decimal("Q", k["Q"]),
 	}
-	return kline
+	return kline, p.err()
 }
 
-func (bw *BinanceWs) parseTickerData(tickmap map[string]interface{}) *Ticker {
+func (bw *BinanceWs) parseTickerData(tickmap map[string]interface{}) (*Ticker, error) {
+	p := &fieldParser{source: "24hrTicker"}
 	t := new(Ticker)
-	t.Date = util.ToUint64(tickmap["E"])
-	t.Last = util.ToFloat64(tickmap["c"])
-	t.Vol = util.ToFloat64(tickmap["v"])
-	t.Low = util.ToFloat64(tickmap["l"])
-	t.High = util.ToFloat64(tickmap["h"])
-	t.Buy = util.ToFloat64(tickmap["b"])
-	t.Sell = util.ToFloat64(tickmap["a"])
-
-	return t
+	t.Date = p.uint64("E", tickmap["E"])
+	t.Last = p.decimal("c", tickmap["c"])
+	t.Vol = p.decimal("v", tickmap["v"])
+	t.Low = p.decimal("l", tickmap["l"])
+	t.High = p.decimal("h", tickmap["h"])
+	t.Buy = p.decimal("b", tickmap["b"])
+	t.Sell = p.decimal("a", tickmap["a"])
+
+	return t, p.err()
+}
+
+// parseTradeData idKey 为成交ID字段，逐笔成交为 t，归集成交为 a
+func (bw *BinanceWs) parseTradeData(tradeMap map[string]interface{}, idKey string) (*Trade, error) {
+	p := &fieldParser{source: "trade"}
+	t := new(Trade)
+	t.Tid = p.int64(idKey, tradeMap[idKey])
+	t.Price = p.decimal("p", tradeMap["p"])
+	t.Amount = p.decimal("q", tradeMap["q"])
+	t.Date = p.int64("T", tradeMap["T"])
+	// 买方是挂单方说明主动成交的是卖方
+	isBuyerMaker, isOk := tradeMap["m"].(bool)
+	if !isOk {
+		p.fail("m", fmt.Errorf("unexpected %T", tradeMap["m"]))
+	}
+	if isBuyerMaker {
+		t.Type = SELL
+	} else {
+		t.Type = BUY
+	}
+	return t, p.err()
+}
+
+func (bw *BinanceWs) parseBookTickerData(bookMap map[string]interface{}) (*BookTicker, error) {
+	p := &fieldParser{source: "bookTicker"}
+	symbol, isOk := bookMap["s"].(string)
+	if !isOk {
+		p.fail("s", fmt.Errorf("unexpected %T", bookMap["s"]))
+	}
+	bookTicker := &BookTicker{
+		Symbol:   symbol,
+		BidPrice: p.decimal("b", bookMap["b"]),
+		BidQty:   p.decimal("B", bookMap["B"]),
+		AskPrice: p.decimal("a", bookMap["a"]),
+		AskQty:   p.decimal("A", bookMap["A"]),
+	}
+	return bookTicker, p.err()
 }
@@ -0,0 +1,291 @@
+package binance_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+)
+
+func TestBinanceInjectedHttpClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/ticker/price" || r.URL.Query().Get("symbol") != "BTCUSDT" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-MBX-USED-WEIGHT-1D", "7")
+		w.Write([]byte(`{"symbol":"BTCUSDT","price":"52648.62000000"}`))
+	}))
+	defer srv.Close()
+
+	limiter := newDayLimiter(100)
+	b := binance.NewBinance("", "").SetHttpClient(srv.Client()).SetBaseURL(srv.URL + "/").SetRateLimiter(limiter)
+	tickers, err := b.GetPriceTicker(context.Background(), "BTCUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tickers) != 1 || tickers[0].Price.String() != "52648.62" {
+		t.Fatalf("unexpected tickers %+v", tickers)
+	}
+	if used := limiter.Used("REQUEST_WEIGHT", 24*time.Hour); used != 7 {
+		t.Fatalf("used weight %d, want 7 from header", used)
+	}
+}
+
+func TestPlaceOrderKnownClientIDNotResubmitted(t *testing.T) {
+	srv, posted, _ := orderServer(http.StatusServiceUnavailable, -1006, 0)
+	defer srv.Close()
+	req := &quant.OrderRequest{Pair: quant.BTC_USDT, Side: binance.BUY, Price: dec("100"), Amount: dec("1"), Strategy: "grid", Intent: "level1"}
+	order, err := newTestBinance(srv).PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*posted) != 0 || order.ClientOrderID != quant.ClientOrderID("grid", "level1") {
+		t.Fatalf("posted %v, order %+v", *posted, order)
+	}
+}
+
+func TestPlaceOrderSendsClientID(t *testing.T) {
+	srv, posted, _ := orderServer(http.StatusServiceUnavailable, -1006, -1)
+	defer srv.Close()
+	req := &quant.OrderRequest{Pair: quant.BTC_USDT, Side: binance.BUY, Price: dec("100"), Amount: dec("1"), Strategy: "grid", Intent: "level2"}
+	order, err := newTestBinance(srv).PlaceOrder(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := quant.ClientOrderID("grid", "level2")
+	if len(*posted) == 0 || (*posted)[0] != want || order.ClientOrderID != want {
+		t.Fatalf("posted %v, order %+v", *posted, order)
+	}
+}
+
+func TestCancelOrderByClientID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete || r.URL.Query().Get("origClientOrderId") != "grid-abc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"symbol":"BTCUSDT","orderId":1,"origClientOrderId":"grid-abc","clientOrderId":"cancel-1","status":"CANCELED"}`))
+	}))
+	defer srv.Close()
+	order, err := newTestBinance(srv).CancelOrderByClientID(context.Background(), quant.BTC_USDT, "grid-abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ClientOrderID != "grid-abc" || order.Status != binance.ORDER_CANCELED {
+		t.Fatalf("unexpected order %+v", order)
+	}
+}
+
+// paramServer 记录下单参数并返回 body
+func paramServer(body string) (*httptest.Server, *url.Values) {
+	params := new(url.Values)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*params = r.URL.Query()
+		w.Write([]byte(body))
+	}))
+	return srv, params
+}
+
+func TestPlaceOrderTypes(t *testing.T) {
+	cases := []struct {
+		req  quant.OrderRequest
+		want map[string]string // 值为空表示参数不应出现
+	}{
+		{
+			quant.OrderRequest{Type: quant.ORDER_TYPE_STOP_LOSS_LIMIT, Price: dec("95"), StopPrice: dec("96"), Amount: dec("1"), IcebergAmount: dec("0.2")},
+			map[string]string{"type": "STOP_LOSS_LIMIT", "price": "95", "stopPrice": "96", "quantity": "1", "icebergQty": "0.2", "timeInForce": "GTC", "newOrderRespType": "ACK"},
+		},
+		{
+			quant.OrderRequest{Type: quant.ORDER_TYPE_TAKE_PROFIT, StopPrice: dec("120"), Amount: dec("1")},
+			map[string]string{"type": "TAKE_PROFIT", "stopPrice": "120", "price": "", "timeInForce": "", "newOrderRespType": "RESULT"},
+		},
+		{
+			quant.OrderRequest{Type: quant.ORDER_TYPE_LIMIT_MAKER, Price: dec("100"), Amount: dec("1")},
+			map[string]string{"type": "LIMIT_MAKER", "price": "100", "timeInForce": ""},
+		},
+		{
+			quant.OrderRequest{Price: dec("100"), Amount: dec("1"), TimeInForce: quant.TIME_IN_FORCE_IOC},
+			map[string]string{"type": "LIMIT", "timeInForce": "IOC"},
+		},
+	}
+	for _, c := range cases {
+		srv, params := paramServer(`{"symbol":"BTCUSDT","orderId":1}`)
+		req := c.req
+		req.Pair, req.Side = quant.BTC_USDT, binance.BUY
+		if _, err := newTestBinance(srv).PlaceOrder(context.Background(), &req); err != nil {
+			t.Fatalf("%s: %v", req.Type, err)
+		}
+		for key, value := range c.want {
+			if got := params.Get(key); got != value {
+				t.Errorf("%s: %s = %q, want %q", req.Type, key, got, value)
+			}
+		}
+		srv.Close()
+	}
+}
+
+func TestPlaceOrderQuoteAmountFull(t *testing.T) {
+	srv, params := paramServer(`{"symbol":"BTCUSDT","orderId":1,"status":"FILLED","type":"MARKET","side":"BUY",
+		"executedQty":"0.002","cummulativeQuoteQty":"100.1","fills":[
+		{"price":"50000","qty":"0.001","commission":"0.000001","commissionAsset":"BTC","tradeId":11},
+		{"price":"50100","qty":"0.001","commission":"0.000001","commissionAsset":"BTC","tradeId":12}]}`)
+	defer srv.Close()
+	order, err := newTestBinance(srv).PlaceOrder(context.Background(), &quant.OrderRequest{
+		Pair: quant.BTC_USDT, Side: binance.BUY_MARKET, QuoteAmount: dec("100.1"), ResponseType: binance.ORDER_RESP_TYPE_FULL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Get("quoteOrderQty") != "100.1" || params.Get("quantity") != "" || params.Get("newOrderRespType") != "FULL" {
+		t.Fatalf("unexpected params %v", *params)
+	}
+	if len(order.Fills) != 2 || order.Fills[1].TradeID != 12 || order.Fills[1].Price.String() != "50100" ||
+		order.Fee.String() != "0.000002" || order.AvgPrice.String() != "50050" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+}
+
+func TestPlaceOrderRejectsInvalidRequest(t *testing.T) {
+	srv, params := paramServer(`{}`)
+	defer srv.Close()
+	reqs := []quant.OrderRequest{
+		{Type: quant.ORDER_TYPE_STOP_LOSS, Amount: dec("1")},
+		{Type: quant.ORDER_TYPE_MARKET, Amount: dec("1"), TimeInForce: quant.TIME_IN_FORCE_FOK},
+		{Type: quant.ORDER_TYPE_LIMIT, Price: dec("100"), QuoteAmount: dec("100")},
+		{Type: quant.ORDER_TYPE_LIMIT, Price: dec("100"), Amount: dec("1"), IcebergAmount: dec("0.1"), TimeInForce: quant.TIME_IN_FORCE_IOC},
+		{Type: "OCO", Price: dec("100"), Amount: dec("1")},
+	}
+	for _, req := range reqs {
+		req.Pair, req.Side = quant.BTC_USDT, binance.BUY
+		if _, err := newTestBinance(srv).PlaceOrder(context.Background(), &req); err == nil {
+			t.Errorf("%+v: expected error", req)
+		}
+	}
+	if len(*params) != 0 {
+		t.Fatalf("invalid requests should not be sent, got %v", *params)
+	}
+}
+
+type recordedRequest struct {
+	method, path string
+	query        url.Values
+}
+
+// recordServer 记录每个请求并返回 body
+func recordServer(body string) (*httptest.Server, *[]recordedRequest) {
+	var mu sync.Mutex
+	var requests []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, recordedRequest{r.Method, r.URL.Path, r.URL.Query()})
+		mu.Unlock()
+		w.Write([]byte(body))
+	}))
+	return srv, &requests
+}
+
+func TestPlaceOrderDefaultsToTestEndpoint(t *testing.T) {
+	srv, requests := recordServer(`{}`)
+	defer srv.Close()
+	b := binance.NewBinance("ak", "sk").SetHttpClient(srv.Client()).SetBaseURL(srv.URL).SetRateLimiter(nil)
+	_, err := b.PlaceOrder(context.Background(), &quant.OrderRequest{Pair: quant.BTC_USDT, Side: binance.BUY, Price: dec("100"), Amount: dec("1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*requests) != 1 || (*requests)[0].path != "/api/v3/order/test" {
+		t.Fatalf("orders must go to the test endpoint by default, got %+v", *requests)
+	}
+}
+
+func TestOrderQueryEndpoints(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name   string
+		body   string
+		call   func(b *binance.Binance) error
+		method string
+		path   string
+		params map[string]string // 值为空表示参数不应出现
+	}{
+		{"GetOrder", `{"symbol":"BTCUSDT","orderId":5}`, func(b *binance.Binance) error {
+			_, err := b.GetOrder(ctx, quant.BTC_USDT, "5")
+			return err
+		}, "GET", "/api/v3/order", map[string]string{"symbol": "BTCUSDT", "orderId": "5"}},
+		{"CancelOrder", `{"symbol":"BTCUSDT","orderId":5,"status":"CANCELED"}`, func(b *binance.Binance) error {
+			_, err := b.CancelOrder(ctx, quant.BTC_USDT, "5")
+			return err
+		}, "DELETE", "/api/v3/order", map[string]string{"symbol": "BTCUSDT", "orderId": "5"}},
+		{"CancelAllOpenOrders", `[{"symbol":"BTCUSDT","orderId":5}]`, func(b *binance.Binance) error {
+			_, err := b.CancelAllOpenOrders(ctx, quant.BTC_USDT)
+			return err
+		}, "DELETE", "/api/v3/openOrders", map[string]string{"symbol": "BTCUSDT"}},
+		{"GetOpenOrders", `[]`, func(b *binance.Binance) error {
+			_, err := b.GetOpenOrders(ctx, quant.CurrencyPair{})
+			return err
+		}, "GET", "/api/v3/openOrders", map[string]string{"symbol": ""}},
+		{"GetAllOrders", `[]`, func(b *binance.Binance) error {
+			_, err := b.GetAllOrders(ctx, quant.ETH_BTC, 1000, 2000)
+			return err
+		}, "GET", "/api/v3/allOrders", map[string]string{"symbol": "ETHBTC", "startTime": "1000", "endTime": "2000"}},
+	}
+	for _, c := range cases {
+		srv, requests := recordServer(c.body)
+		if err := c.call(newTestBinance(srv)); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		srv.Close()
+		if len(*requests) != 1 {
+			t.Fatalf("%s: %d requests", c.name, len(*requests))
+		}
+		req := (*requests)[0]
+		if req.method != c.method || req.path != c.path || req.query.Get("signature") == "" || req.query.Get("timestamp") == "" {
+			t.Fatalf("%s: unexpected request %+v", c.name, req)
+		}
+		for key, value := range c.params {
+			if got := req.query.Get(key); got != value {
+				t.Errorf("%s: %s = %q, want %q", c.name, key, got, value)
+			}
+		}
+	}
+}
+
+func TestOrderStatusMapping(t *testing.T) {
+	statuses := map[string]quant.TradeStatus{
+		"NEW":              binance.ORDER_NEW,
+		"PARTIALLY_FILLED": binance.ORDER_PARTIALLY_FILLED,
+		"FILLED":           binance.ORDER_FILLED,
+		"CANCELED":         binance.ORDER_CANCELED,
+		"PENDING_CANCEL":   binance.ORDER_PENDING_CANCEL,
+		"REJECTED":         binance.ORDER_REJECT,
+		"EXPIRED":          binance.ORDER_EXPIRED,
+		"":                 binance.ORDER_NEW, // ACK 响应没有状态
+	}
+	var body []string
+	for status := range statuses {
+		body = append(body, `{"symbol":"BTCUSDT","orderId":1,"clientOrderId":"`+status+`","status":"`+status+`"}`)
+	}
+	srv, _ := recordServer("[" + strings.Join(body, ",") + "]")
+	defer srv.Close()
+	orders, err := newTestBinance(srv).GetOpenOrders(context.Background(), quant.BTC_USDT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != len(statuses) {
+		t.Fatalf("got %d orders", len(orders))
+	}
+	for _, order := range orders {
+		if want := statuses[order.ClientOrderID]; order.Status != want {
+			t.Errorf("status %q mapped to %v, want %v", order.ClientOrderID, order.Status, want)
+		}
+		if !order.Pair.Equal(quant.BTC_USDT) {
+			t.Errorf("pair not resolved: %+v", order.Pair)
+		}
+	}
+}
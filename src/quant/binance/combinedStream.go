@@ -0,0 +1,241 @@
+package binance
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// binance 单个连接最多订阅 1024 个stream
+	defaultMaxStreamsPerConn = 1024
+	// 单个连接每秒最多发送 5 条消息，按 250ms 间隔合并发送订阅请求
+	streamFlushInterval = 250 * time.Millisecond
+	// 单条 SUBSCRIBE 消息携带的最大stream数
+	maxStreamsPerMessage = 200
+)
+
+// streamConn 一条组合流连接及其上订阅的stream
+type streamConn struct {
+	conn         *util.WsConn
+	streams      map[string]bool
+	pendingSub   []string
+	pendingUnsub []string
+	// dialing 连接建立中，不接受其他订阅，也不发送订阅请求
+	dialing bool
+}
+
+type streamRequest struct {
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+	ID     int64    `json:"id"`
+}
+
+type streamEnvelope struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+	Result interface{}     `json:"result"`
+	ID     int64           `json:"id"`
+	Error  *struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	} `json:"error"`
+}
+
+// SetMaxStreamsPerConn 设置单个连接订阅的stream上限，超出后自动新建连接
+func (bw *BinanceWs) SetMaxStreamsPerConn(n int) *BinanceWs {
+	bw.streamMu.Lock()
+	bw.maxStreamsPerConn = n
+	bw.streamMu.Unlock()
+	return bw
+}
+
+func (bw *BinanceWs) combinedURL() string {
+	return strings.TrimSuffix(strings.TrimSuffix(bw.baseURL, "/"), "/ws") + "/stream"
+}
+
+/*
+	subscribe 通过组合流订阅 stream，handle 接收 data 字段
+	订阅请求先进入队列，由后台按频率限制合并发送
+	需要新建连接时先在锁内占位，建立连接时不持有锁，避免阻塞其他订阅和消息分发
+*/
+func (bw *BinanceWs) subscribe(stream string, handle func([]byte) error) error {
+	bw.streamMu.Lock()
+	if bw.handlers == nil {
+		bw.handlers = make(map[string]func([]byte) error)
+	}
+	if _, ok := bw.handlers[stream]; ok {
+		bw.handlers[stream] = handle
+		bw.streamMu.Unlock()
+		return nil
+	}
+
+	var sc *streamConn
+	for _, c := range bw.streamConns {
+		if !c.dialing && len(c.streams) < bw.maxStreamsPerConn {
+			sc = c
+			break
+		}
+	}
+	if sc != nil {
+		bw.handlers[stream] = handle
+		sc.streams[stream] = true
+		sc.pendingSub = append(sc.pendingSub, stream)
+		bw.streamMu.Unlock()
+		bw.startFlush()
+		return nil
+	}
+
+	sc = &streamConn{streams: map[string]bool{stream: true}, dialing: true}
+	sc.conn = util.NewWsConn(bw.combinedURL(), bw.proxyUrl, bw.dispatch)
+	// 重连后重新订阅该连接上的所有stream，新连接上没有需要取消的订阅
+	sc.conn.SetReconnectHandler(func() {
+		bw.streamMu.Lock()
+		defer bw.streamMu.Unlock()
+		sc.pendingUnsub = sc.pendingUnsub[:0]
+		sc.pendingSub = sc.pendingSub[:0]
+		for s := range sc.streams {
+			sc.pendingSub = append(sc.pendingSub, s)
+		}
+	})
+	bw.handlers[stream] = handle
+	bw.streamConns = append(bw.streamConns, sc)
+	bw.streamMu.Unlock()
+
+	err := sc.conn.NewWebsocket()
+
+	bw.streamMu.Lock()
+	defer bw.streamMu.Unlock()
+	if err != nil {
+		Logger.Error("[ws] subscribe failed ", zap.String("stream", stream), zap.Error(err))
+		bw.removeStreamConn(sc)
+		// 只移除本次添加的handler，建立连接期间已取消订阅的stream可能已被其他调用方重新订阅到其他连接
+		if sc.streams[stream] {
+			delete(bw.handlers, stream)
+		}
+		return err
+	}
+	sc.dialing = false
+	// 建立连接期间可能已被取消订阅
+	sc.pendingUnsub = sc.pendingUnsub[:0]
+	for s := range sc.streams {
+		sc.pendingSub = append(sc.pendingSub, s)
+	}
+	bw.startFlush()
+	return nil
+}
+
+func (bw *BinanceWs) startFlush() {
+	bw.flushOnce.Do(func() {
+		go bw.flushLoop()
+	})
+}
+
+// removeStreamConn 移除建立失败的连接，调用方需持有 streamMu
+func (bw *BinanceWs) removeStreamConn(sc *streamConn) {
+	for i, c := range bw.streamConns {
+		if c == sc {
+			bw.streamConns = append(bw.streamConns[:i], bw.streamConns[i+1:]...)
+			return
+		}
+	}
+}
+
+// Unsubscribe 取消订阅，如 btcusdt@trade
+func (bw *BinanceWs) Unsubscribe(streams ...string) {
+	bw.streamMu.Lock()
+	defer bw.streamMu.Unlock()
+	for _, stream := range streams {
+		stream = strings.ToLower(stream)
+		delete(bw.handlers, stream)
+		for _, sc := range bw.streamConns {
+			if sc.streams[stream] {
+				delete(sc.streams, stream)
+				sc.pendingUnsub = append(sc.pendingUnsub, stream)
+			}
+		}
+	}
+}
+
+// Streams 当前订阅的所有stream
+func (bw *BinanceWs) Streams() []string {
+	bw.streamMu.Lock()
+	defer bw.streamMu.Unlock()
+	streams := make([]string, 0, len(bw.handlers))
+	for stream := range bw.handlers {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+func (bw *BinanceWs) flushLoop() {
+	ticker := time.NewTicker(streamFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-bw.closeCh:
+			return
+		case <-ticker.C:
+			bw.flush()
+		}
+	}
+}
+
+// flush 每个连接每次最多发送一条订阅或取消订阅消息
+func (bw *BinanceWs) flush() {
+	bw.streamMu.Lock()
+	defer bw.streamMu.Unlock()
+	for _, sc := range bw.streamConns {
+		if sc.dialing {
+			continue
+		}
+		method := "SUBSCRIBE"
+		pending := &sc.pendingSub
+		if len(sc.pendingSub) == 0 {
+			method = "UNSUBSCRIBE"
+			pending = &sc.pendingUnsub
+		}
+		if len(*pending) == 0 {
+			continue
+		}
+		n := len(*pending)
+		if n > maxStreamsPerMessage {
+			n = maxStreamsPerMessage
+		}
+		params := append([]string(nil), (*pending)[:n]...)
+		bw.requestID++
+		err := sc.conn.SendMessage(&streamRequest{Method: method, Params: params, ID: bw.requestID})
+		if err != nil {
+			Logger.Warn("[ws] send stream request failed ", zap.String("method", method), zap.Error(err))
+			continue
+		}
+		*pending = (*pending)[n:]
+	}
+}
+
+// dispatch 按 stream 字段将组合流消息路由到对应的handler
+func (bw *BinanceWs) dispatch(msg []byte) error {
+	envelope := new(streamEnvelope)
+	if err := json.Unmarshal(msg, envelope); err != nil {
+		Logger.Error("json unmarshal error for ", zap.ByteString("msg", msg), zap.Error(err))
+		return err
+	}
+	if envelope.Stream == "" {
+		if envelope.Error != nil {
+			Logger.Error("[ws] stream request failed ", zap.Int64("id", envelope.ID),
+				zap.Int("code", envelope.Error.Code), zap.String("msg", envelope.Error.Msg))
+		}
+		return nil
+	}
+	bw.streamMu.Lock()
+	handle, ok := bw.handlers[envelope.Stream]
+	bw.streamMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return handle(envelope.Data)
+}
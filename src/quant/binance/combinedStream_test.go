@@ -0,0 +1,283 @@
+package binance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+
+	"github.com/gorilla/websocket"
+)
+
+// newStreamServer 模拟组合流: 收到 SUBSCRIBE 后按 stream 推送一条成交
+func newStreamServer(conns *int32) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/stream" {
+			http.NotFound(w, r)
+			return
+		}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		atomic.AddInt32(conns, 1)
+		for {
+			req := struct {
+				Method string   `json:"method"`
+				Params []string `json:"params"`
+				ID     int64    `json:"id"`
+			}{}
+			if err := c.ReadJSON(&req); err != nil {
+				return
+			}
+			c.WriteJSON(map[string]interface{}{"result": nil, "id": req.ID})
+			for _, stream := range req.Params {
+				symbol := strings.ToUpper(strings.Split(stream, "@")[0])
+				data := fmt.Sprintf(`{"e":"trade","s":"%s","t":1,"p":"1.5","q":"2","T":1590000000000,"m":true}`, symbol)
+				c.WriteJSON(map[string]interface{}{"stream": stream, "data": json.RawMessage(data)})
+			}
+		}
+	}))
+}
+
+func TestCombinedStreamRouting(t *testing.T) {
+	var conns int32
+	srv := newStreamServer(&conns)
+	defer srv.Close()
+
+	bw := binance.NewBinanceWS("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", "")
+	bw.SetMaxStreamsPerConn(2)
+	defer bw.Close()
+
+	trades := make(chan *binance.Trade, 3)
+	bw.SetTradeCallback(func(trade *binance.Trade) {
+		trades <- trade
+	})
+	if err := bw.SubscribeAggTrade(quant.BTC_USDT); err != binance.ErrCallbackNotSet {
+		t.Fatalf("expected ErrCallbackNotSet, got %v", err)
+	}
+	// 不支持的周期直接返回错误，不会订阅其他周期
+	bw.SetKlineCallback(func(*binance.Kline, int) {})
+	if err := bw.SubscribeKline(quant.BTC_USDT, 0); err == nil || err.Error() != "unsupported kline period 0" {
+		t.Fatalf("expected unsupported period, got %v", err)
+	}
+	for _, pair := range []quant.CurrencyPair{quant.BTC_USDT, quant.ETH_USDT, quant.BNB_USDT} {
+		if err := bw.SubscribeTrade(pair); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case trade := <-trades:
+			if trade.Type != binance.SELL || trade.Price.String() != "1.5" {
+				t.Fatalf("unexpected trade %+v", trade)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of 3 trades", i)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 2 {
+		t.Fatalf("expected streams spread over 2 connections, got %d", n)
+	}
+	if streams := bw.Streams(); len(streams) != 3 {
+		t.Fatalf("unexpected streams %v", streams)
+	}
+}
+
+func TestCombinedStreamDialWithoutLock(t *testing.T) {
+	var conns int32
+	streamSrv := newStreamServer(&conns)
+	defer streamSrv.Close()
+	release := make(chan struct{})
+	// 握手在 release 之前阻塞，之后第一次连接失败，其余转发到组合流服务
+	var dials int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&dials, 1) == 1 {
+			<-release
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		streamSrv.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	bw := binance.NewBinanceWS("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", "")
+	defer bw.Close()
+	trades := make(chan *binance.Trade, 1)
+	bw.SetTradeCallback(func(trade *binance.Trade) {
+		trades <- trade
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- bw.SubscribeTrade(quant.BTC_USDT)
+	}()
+	for atomic.LoadInt32(&dials) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	done := make(chan []string, 1)
+	go func() {
+		done <- bw.Streams()
+	}()
+	select {
+	case streams := <-done:
+		if len(streams) != 1 {
+			t.Fatalf("expected the reserved stream, got %v", streams)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Streams blocked while a connection was dialing")
+	}
+
+	close(release)
+	if err := <-result; err == nil {
+		t.Fatal("expected dial failure")
+	}
+	if streams := bw.Streams(); len(streams) != 0 {
+		t.Fatalf("failed subscription still registered %v", streams)
+	}
+
+	if err := bw.SubscribeTrade(quant.BTC_USDT); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-trades:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trade after resubscribing")
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Fatalf("expected 1 stream connection, got %d", n)
+	}
+}
+
+func TestCombinedStreamDialFailureKeepsResubscribedHandler(t *testing.T) {
+	var conns int32
+	streamSrv := newStreamServer(&conns)
+	defer streamSrv.Close()
+	release := make(chan struct{})
+	var dials int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&dials, 1) == 1 {
+			<-release
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		streamSrv.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	bw := binance.NewBinanceWS("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", "")
+	defer bw.Close()
+	trades := make(chan *binance.Trade, 4)
+	bw.SetTradeCallback(func(trade *binance.Trade) {
+		trades <- trade
+	})
+
+	result := make(chan error, 1)
+	go func() {
+		result <- bw.SubscribeTrade(quant.BTC_USDT)
+	}()
+	for atomic.LoadInt32(&dials) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// 第一次连接建立期间取消订阅，并由其他调用方重新订阅到新连接
+	bw.Unsubscribe("btcusdt@trade")
+	if err := bw.SubscribeTrade(quant.BTC_USDT); err != nil {
+		t.Fatal(err)
+	}
+
+	close(release)
+	if err := <-result; err == nil {
+		t.Fatal("expected dial failure")
+	}
+	if streams := bw.Streams(); len(streams) != 1 || streams[0] != "btcusdt@trade" {
+		t.Fatalf("resubscribed stream removed by failed dial: %v", streams)
+	}
+	select {
+	case <-trades:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trade on the resubscribed stream")
+	}
+}
+
+func TestCombinedStreamReconnectDropsPendingUnsubscribe(t *testing.T) {
+	var mu sync.Mutex
+	var requests [][]string
+	connCh := make(chan *websocket.Conn, 2)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		mu.Lock()
+		idx := len(requests)
+		requests = append(requests, nil)
+		mu.Unlock()
+		connCh <- c
+		for {
+			req := struct {
+				Method string   `json:"method"`
+				Params []string `json:"params"`
+				ID     int64    `json:"id"`
+			}{}
+			if err := c.ReadJSON(&req); err != nil {
+				return
+			}
+			sort.Strings(req.Params)
+			mu.Lock()
+			requests[idx] = append(requests[idx], req.Method+" "+strings.Join(req.Params, ","))
+			mu.Unlock()
+			c.WriteJSON(map[string]interface{}{"result": nil, "id": req.ID})
+		}
+	}))
+	defer srv.Close()
+	connRequests := func(idx int) []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), requests[idx]...)
+	}
+
+	bw := binance.NewBinanceWS("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", "")
+	defer bw.Close()
+	bw.SetTradeCallback(func(*binance.Trade) {})
+	if err := bw.SubscribeTrade(quant.BTC_USDT); err != nil {
+		t.Fatal(err)
+	}
+	if err := bw.SubscribeTrade(quant.ETH_USDT); err != nil {
+		t.Fatal(err)
+	}
+	c1 := <-connCh
+	deadline := time.Now().Add(2 * time.Second)
+	for len(connRequests(0)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("streams not subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 断线期间取消订阅，UNSUBSCRIBE 无法发送，重连后的新连接上不应再发送
+	c1.Close()
+	time.Sleep(100 * time.Millisecond)
+	bw.Unsubscribe("btcusdt@trade")
+	select {
+	case <-connCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream connection not reestablished")
+	}
+	time.Sleep(time.Second)
+	if got := connRequests(1); len(got) != 1 || got[0] != "SUBSCRIBE ethusdt@trade" {
+		t.Fatalf("unexpected requests after reconnect %v", got)
+	}
+}
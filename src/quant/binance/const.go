@@ -1,70 +1,73 @@
 package binance
 
+import "tinyquant/src/quant"
+
 const (
-	TICKER_URI             = "ticker/24hr?symbol=%s"
-	TICKERS_URI            = "ticker/allBookTickers"
 	DEPTH_URI              = "depth?symbol=%s&limit=%d"
-	ACCOUNT_URI            = "account?"
+	ACCOUNT_URI            = "/api/v3/account"
 	ORDER_URI              = "order"
 	UNFINISHED_ORDERS_INFO = "openOrders?"
 	KLINE_URI              = "klines"
 	SERVER_TIME_URL        = "time"
 )
 
-type TradeSide int
-
-func (ts TradeSide) String() string {
-	switch ts {
-	case 1:
-		return "BUY"
-	case 2:
-		return "SELL"
-	case 3:
-		return "BUY_MARKET"
-	case 4:
-		return "SELL_MARKET"
-	default:
-		return "UNKNOWN"
-	}
-}
+type (
+	TradeSide   = quant.TradeSide
+	TradeStatus = quant.TradeStatus
+)
 
 const (
-	BUY TradeSide = 1 + iota
-	SELL
-	BUY_MARKET
-	SELL_MARKET
+	BUY         = quant.BUY
+	SELL        = quant.SELL
+	BUY_MARKET  = quant.BUY_MARKET
+	SELL_MARKET = quant.SELL_MARKET
 )
 
-type TradeStatus int
+// 下单响应类型
+const (
+	ORDER_RESP_TYPE_ACK    = "ACK"    // 只返回订单号
+	ORDER_RESP_TYPE_RESULT = "RESULT" // 返回订单状态与成交数量
+	ORDER_RESP_TYPE_FULL   = "FULL"   // 另外返回每笔成交 fills
+)
 
 const (
-	ORDER_NEW              TradeStatus = iota //新建订单
-	ORDER_PARTIALLY_FILLED                    //部分成交
-	ORDER_FILLED                              //全部成交
-	ORDER_CANCELED                            // 已撤销
-	ORDER_PENDING_CANCEL                      //撤销中
-	ORDER_REJECT                              //订单被拒绝
-	ORDER_EXPIRED                             //订单过期
+	ORDER_NEW              = quant.ORDER_NEW              //新建订单
+	ORDER_PARTIALLY_FILLED = quant.ORDER_PARTIALLY_FILLED //部分成交
+	ORDER_FILLED           = quant.ORDER_FILLED           //全部成交
+	ORDER_CANCELED         = quant.ORDER_CANCELED         // 已撤销
+	ORDER_PENDING_CANCEL   = quant.ORDER_PENDING_CANCEL   //撤销中
+	ORDER_REJECT           = quant.ORDER_REJECT           //订单被拒绝
+	ORDER_EXPIRED          = quant.ORDER_EXPIRED          //订单过期
 )
 
+var _INERNAL_ORDER_STATUS_CONVERTER = map[string]TradeStatus{
+	"NEW":              ORDER_NEW,
+	"PARTIALLY_FILLED": ORDER_PARTIALLY_FILLED,
+	"FILLED":           ORDER_FILLED,
+	"CANCELED":         ORDER_CANCELED,
+	"PENDING_CANCEL":   ORDER_PENDING_CANCEL,
+	"REJECTED":         ORDER_REJECT,
+	"EXPIRED":          ORDER_EXPIRED,
+}
+
 //k线周期
 const (
-	KLINE_PERIOD_1MIN = 1 + iota
-	KLINE_PERIOD_3MIN
-	KLINE_PERIOD_5MIN
-	KLINE_PERIOD_15MIN
-	KLINE_PERIOD_30MIN
-	KLINE_PERIOD_60MIN
-	KLINE_PERIOD_1H
-	KLINE_PERIOD_2H
-	KLINE_PERIOD_3H
-	KLINE_PERIOD_4H
-	KLINE_PERIOD_6H
-	KLINE_PERIOD_8H
-	KLINE_PERIOD_12H
-	KLINE_PERIOD_1DAY
-	KLINE_PERIOD_3DAY
-	KLINE_PERIOD_1WEEK
-	KLINE_PERIOD_1MONTH
-	KLINE_PERIOD_1YEAR
+	KLINE_PERIOD_1MIN   = quant.KLINE_PERIOD_1MIN
+	KLINE_PERIOD_3MIN   = quant.KLINE_PERIOD_3MIN
+	KLINE_PERIOD_5MIN   = quant.KLINE_PERIOD_5MIN
+	KLINE_PERIOD_15MIN  = quant.KLINE_PERIOD_15MIN
+	KLINE_PERIOD_30MIN  = quant.KLINE_PERIOD_30MIN
+	KLINE_PERIOD_60MIN  = quant.KLINE_PERIOD_60MIN
+	KLINE_PERIOD_1H     = quant.KLINE_PERIOD_1H
+	KLINE_PERIOD_2H     = quant.KLINE_PERIOD_2H
+	KLINE_PERIOD_3H     = quant.KLINE_PERIOD_3H
+	KLINE_PERIOD_4H     = quant.KLINE_PERIOD_4H
+	KLINE_PERIOD_6H     = quant.KLINE_PERIOD_6H
+	KLINE_PERIOD_8H     = quant.KLINE_PERIOD_8H
+	KLINE_PERIOD_12H    = quant.KLINE_PERIOD_12H
+	KLINE_PERIOD_1DAY   = quant.KLINE_PERIOD_1DAY
+	KLINE_PERIOD_3DAY   = quant.KLINE_PERIOD_3DAY
+	KLINE_PERIOD_1WEEK  = quant.KLINE_PERIOD_1WEEK
+	KLINE_PERIOD_1MONTH = quant.KLINE_PERIOD_1MONTH
+	KLINE_PERIOD_1YEAR  = quant.KLINE_PERIOD_1YEAR
 )
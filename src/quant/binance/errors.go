@@ -0,0 +1,167 @@
+package binance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"tinyquant/src/mod"
+	"tinyquant/src/util"
+)
+
+// binance error codes
+const (
+	ERR_UNKNOWN            = -1000
+	ERR_DISCONNECTED       = -1001
+	ERR_TOO_MANY_REQUESTS  = -1003
+	ERR_TIMEOUT            = -1007 // 等待后端响应超时，请求可能已被处理
+	ERR_TOO_MANY_ORDERS    = -1015
+	ERR_INVALID_TIMESTAMP  = -1021
+	ERR_INVALID_SIGNATURE  = -1022
+	ERR_BAD_SYMBOL         = -1121
+	ERR_INVALID_LISTEN_KEY = -1125
+	ERR_NEW_ORDER_REJECTED = -2010
+	ERR_CANCEL_REJECTED    = -2011
+	ERR_NO_SUCH_ORDER      = -2013
+	ERR_BAD_API_KEY_FMT    = -2014
+	ERR_REJECTED_MBX_KEY   = -2015
+)
+
+const (
+	statusIPBanned         = 418 // 多次触发429后IP被封禁
+	msgInsufficientBalance = "insufficient balance"
+	msgDuplicateOrder      = "duplicate order"
+)
+
+// APIError binance接口返回的错误
+type APIError struct {
+	StatusCode int           // HTTP状态码
+	Code       int64         // binance错误码
+	Msg        string        // 错误信息
+	Path       string        // 请求路径
+	RetryAfter time.Duration // 429/418 响应的 Retry-After
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("binance api error: status=%d code=%d msg=%s path=%s", e.StatusCode, e.Code, e.Msg, e.Path)
+}
+
+// IsRateLimited 请求频率超限或IP已被封禁
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode == statusIPBanned ||
+		e.Code == ERR_TOO_MANY_REQUESTS || e.Code == ERR_TOO_MANY_ORDERS
+}
+
+// IsInvalidSignature 签名错误
+func (e *APIError) IsInvalidSignature() bool {
+	return e.Code == ERR_INVALID_SIGNATURE
+}
+
+// IsTimestampOutOfWindow 请求时间戳超出recvWindow
+func (e *APIError) IsTimestampOutOfWindow() bool {
+	return e.Code == ERR_INVALID_TIMESTAMP
+}
+
+// IsInsufficientBalance 下单时余额不足
+func (e *APIError) IsInsufficientBalance() bool {
+	return e.Code == ERR_NEW_ORDER_REJECTED && strings.Contains(strings.ToLower(e.Msg), msgInsufficientBalance)
+}
+
+// IsDuplicateOrder newClientOrderId 与未完成的订单重复
+func (e *APIError) IsDuplicateOrder() bool {
+	return e.Code == ERR_NEW_ORDER_REJECTED && strings.Contains(strings.ToLower(e.Msg), msgDuplicateOrder)
+}
+
+// IsOrderNotFound 订单不存在
+func (e *APIError) IsOrderNotFound() bool {
+	return e.Code == ERR_NO_SUCH_ORDER || (e.Code == ERR_CANCEL_REJECTED && strings.Contains(e.Msg, "Unknown order"))
+}
+
+// ParseError 接口返回或推送的数据中存在格式错误的字段
+type ParseError struct {
+	Source string   // 数据来源，如 kline、24hrTicker
+	Fields []string // 格式错误的字段及原因
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("binance malformed %s fields: %s", e.Source, strings.Join(e.Fields, "; "))
+}
+
+// AsAPIError 判断err是否为binance接口错误
+func AsAPIError(err error) (*APIError, bool) {
+	apiErr, ok := err.(*APIError)
+	return apiErr, ok
+}
+
+// IsRateLimited 接口返回频率超限，或请求在本地被 RateLimiter 拒绝
+func IsRateLimited(err error) bool {
+	if _, ok := err.(*RateLimitError); ok {
+		return true
+	}
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.IsRateLimited()
+}
+
+func IsInvalidSignature(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.IsInvalidSignature()
+}
+
+func IsTimestampOutOfWindow(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.IsTimestampOutOfWindow()
+}
+
+func IsInsufficientBalance(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.IsInsufficientBalance()
+}
+
+func IsDuplicateOrder(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.IsDuplicateOrder()
+}
+
+func IsOrderNotFound(err error) bool {
+	apiErr, ok := AsAPIError(err)
+	return ok && apiErr.IsOrderNotFound()
+}
+
+// request 发送请求，GET请求按 RequestQuery 策略重试
+func (b *Binance) request(ctx context.Context, r *mod.ReqParam, out interface{}) error {
+	return b.withRetry(ctx, r, func() error {
+		return b.doRequest(ctx, r, out)
+	})
+}
+
+/*
+	doRequest 发送一次请求并将接口错误转换为 *APIError
+	请求前按接口权重等待频率限制，响应后根据响应头更新已用额度
+*/
+func (b *Binance) doRequest(ctx context.Context, r *mod.ReqParam, out interface{}) error {
+	if b.limiter != nil {
+		weight, isOrder := requestWeight(r)
+		if err := b.limiter.Wait(ctx, weight, isOrder); err != nil {
+			return err
+		}
+	}
+	header, err := util.HttpRequestJSONWithClient(ctx, b.httpClient, b.baseUrl, r, out)
+	if b.limiter != nil {
+		b.limiter.Update(header)
+	}
+	if respErr, ok := err.(*util.ResponseError); ok {
+		apiErr := &APIError{
+			StatusCode: respErr.StatusCode,
+			Code:       respErr.Code,
+			Msg:        respErr.Msg,
+			Path:       respErr.Path,
+			RetryAfter: respErr.RetryAfter,
+		}
+		if b.limiter != nil && (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == statusIPBanned) {
+			b.limiter.Block(apiErr.RetryAfter)
+		}
+		return apiErr
+	}
+	return err
+}
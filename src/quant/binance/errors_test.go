@@ -0,0 +1,44 @@
+package binance_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"tinyquant/src/quant/binance"
+)
+
+func TestAPIErrorClassification(t *testing.T) {
+	cases := []struct {
+		name                                            string
+		err                                             error
+		invalidSignature, insufficientBalance, notFound bool
+	}{
+		{name: "invalid signature", err: &binance.APIError{StatusCode: http.StatusBadRequest, Code: -1022,
+			Msg: "Signature for this request is not valid."}, invalidSignature: true},
+		{name: "insufficient balance", err: &binance.APIError{StatusCode: http.StatusBadRequest, Code: -2010,
+			Msg: "Account has insufficient balance for requested action."}, insufficientBalance: true},
+		{name: "other new order rejection", err: &binance.APIError{StatusCode: http.StatusBadRequest, Code: -2010,
+			Msg: "Order would trigger immediately."}},
+		{name: "no such order", err: &binance.APIError{StatusCode: http.StatusBadRequest, Code: -2013,
+			Msg: "Order does not exist."}, notFound: true},
+		{name: "cancel unknown order", err: &binance.APIError{StatusCode: http.StatusBadRequest, Code: -2011,
+			Msg: "Unknown order sent."}, notFound: true},
+		{name: "cancel rejected", err: &binance.APIError{StatusCode: http.StatusBadRequest, Code: -2011,
+			Msg: "Order was canceled or expired."}},
+		{name: "timestamp", err: &binance.APIError{StatusCode: http.StatusBadRequest, Code: -1021,
+			Msg: "Timestamp for this request is outside of the recvWindow."}},
+		{name: "not an api error", err: errors.New("Unknown order sent.")},
+		{name: "nil", err: nil},
+	}
+	for _, c := range cases {
+		if got := binance.IsInvalidSignature(c.err); got != c.invalidSignature {
+			t.Errorf("%s: IsInvalidSignature = %v", c.name, got)
+		}
+		if got := binance.IsInsufficientBalance(c.err); got != c.insufficientBalance {
+			t.Errorf("%s: IsInsufficientBalance = %v", c.name, got)
+		}
+		if got := binance.IsOrderNotFound(c.err); got != c.notFound {
+			t.Errorf("%s: IsOrderNotFound = %v", c.name, got)
+		}
+	}
+}
@@ -1,5 +1,32 @@
 package binance
 
+import (
+	"tinyquant/src/quant"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeInfo 交易规则和交易对信息
+type ExchangeInfo struct {
+	Timezone   string        `json:"timezone"`
+	ServerTime int64         `json:"serverTime"`
+	RateLimits []RateLimit   `json:"rateLimits"`
+	Symbols    []TradeSymbol `json:"symbols"`
+}
+
+// symbol filter types
+const (
+	FILTER_PRICE           = "PRICE_FILTER"
+	FILTER_PERCENT_PRICE   = "PERCENT_PRICE"
+	FILTER_LOT_SIZE        = "LOT_SIZE"
+	FILTER_MIN_NOTIONAL    = "MIN_NOTIONAL"
+	FILTER_MARKET_LOT_SIZE = "MARKET_LOT_SIZE"
+	FILTER_ICEBERG_PARTS   = "ICEBERG_PARTS"
+	FILTER_MAX_NUM_ORDERS  = "MAX_NUM_ORDERS"
+)
+
+const SYMBOL_STATUS_TRADING = "TRADING"
+
 type TradeSymbol struct {
 	Symbol                     string   `json:"symbol"`
 	Status                     string   `json:"status"`
@@ -18,6 +45,63 @@ type TradeSymbol struct {
 	OrderTypes                 []string `json:"orderTypes"`
 }
 
+// Filter 按类型查找交易规则，不存在时返回nil
+func (ts *TradeSymbol) Filter(filterType string) *Filter {
+	for i := range ts.Filters {
+		if ts.Filters[i].FilterType == filterType {
+			return &ts.Filters[i]
+		}
+	}
+	return nil
+}
+
+// CurrencyPair 转换为通用交易对，精度取自 PRICE_FILTER 的 tickSize 与 LOT_SIZE 的 stepSize
+func (ts *TradeSymbol) CurrencyPair() quant.CurrencyPair {
+	pair := quant.NewCurrencyPair(ts.BaseAsset, ts.QuoteAsset)
+	if f := ts.PriceFilter(); f != nil && f.TickSize.IsPositive() {
+		pair.PricePrecision = quant.StepPrecision(f.TickSize.String())
+	}
+	if f := ts.LotSizeFilter(); f != nil && f.StepSize.IsPositive() {
+		pair.AmountPrecision = quant.StepPrecision(f.StepSize.String())
+	}
+	return pair
+}
+
+func (ts *TradeSymbol) PriceFilter() *Filter {
+	return ts.Filter(FILTER_PRICE)
+}
+
+func (ts *TradeSymbol) PercentPriceFilter() *Filter {
+	return ts.Filter(FILTER_PERCENT_PRICE)
+}
+
+func (ts *TradeSymbol) LotSizeFilter() *Filter {
+	return ts.Filter(FILTER_LOT_SIZE)
+}
+
+func (ts *TradeSymbol) MinNotionalFilter() *Filter {
+	return ts.Filter(FILTER_MIN_NOTIONAL)
+}
+
+func (ts *TradeSymbol) MarketLotSizeFilter() *Filter {
+	return ts.Filter(FILTER_MARKET_LOT_SIZE)
+}
+
+// IsTrading 交易对是否处于可交易状态
+func (ts *TradeSymbol) IsTrading() bool {
+	return ts.Status == SYMBOL_STATUS_TRADING
+}
+
+// SupportsOrderType 交易对是否支持该订单类型，如 LIMIT_MAKER
+func (ts *TradeSymbol) SupportsOrderType(orderType string) bool {
+	for _, t := range ts.OrderTypes {
+		if t == orderType {
+			return true
+		}
+	}
+	return false
+}
+
 type RateLimit struct {
 	Interval      string `json:"interval"`
 	IntervalNum   int64  `json:"intervalNum"`
@@ -26,20 +110,20 @@ type RateLimit struct {
 }
 
 type Filter struct {
-	FilterType          string  `json:"filterType"`
-	MaxPrice            float64 `json:"maxPrice,string"`
-	MinPrice            float64 `json:"minPrice,string"`
-	TickSize            float64 `json:"tickSize,string"`
-	MultiplierUp        float64 `json:"multiplierUp,string"`
-	MultiplierDown      float64 `json:"multiplierDown,string"`
-	AvgPriceMins        int     `json:"avgPriceMins"`
-	MinQty              float64 `json:"minQty,string"`
-	MaxQty              float64 `json:"maxQty,string"`
-	StepSize            float64 `json:"stepSize,string"`
-	MinNotional         float64 `json:"minNotional,string"`
-	ApplyToMarket       bool    `json:"applyToMarket"`
-	Limit               int     `json:"limit"`
-	MaxNumAlgoOrders    int     `json:"maxNumAlgoOrders"`
-	MaxNumIcebergOrders int     `json:"maxNumIcebergOrders"`
-	MaxNumOrders        int     `json:"maxNumOrders"`
+	FilterType          string          `json:"filterType"`
+	MaxPrice            decimal.Decimal `json:"maxPrice"`
+	MinPrice            decimal.Decimal `json:"minPrice"`
+	TickSize            decimal.Decimal `json:"tickSize"`
+	MultiplierUp        decimal.Decimal `json:"multiplierUp"`
+	MultiplierDown      decimal.Decimal `json:"multiplierDown"`
+	AvgPriceMins        int             `json:"avgPriceMins"`
+	MinQty              decimal.Decimal `json:"minQty"`
+	MaxQty              decimal.Decimal `json:"maxQty"`
+	StepSize            decimal.Decimal `json:"stepSize"`
+	MinNotional         decimal.Decimal `json:"minNotional"`
+	ApplyToMarket       bool            `json:"applyToMarket"`
+	Limit               int             `json:"limit"`
+	MaxNumAlgoOrders    int             `json:"maxNumAlgoOrders"`
+	MaxNumIcebergOrders int             `json:"maxNumIcebergOrders"`
+	MaxNumOrders        int             `json:"maxNumOrders"`
 }
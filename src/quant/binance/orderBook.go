@@ -0,0 +1,375 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+const (
+	orderBookSnapshotLimit = 1000
+	orderBookResyncDelay   = time.Second
+	// 未同步时最多缓存的增量推送，超出后丢弃缓存并重新同步
+	orderBookMaxBuffered = 1000
+)
+
+var (
+	ErrOrderBookNotSynced = errors.New("order book not synced")
+	ErrOrderBookGap       = errors.New("order book update id gap")
+	ErrOrderBookOverflow  = errors.New("order book update buffer overflow")
+	// 快照早于缓存的第一条增量，需要重新获取快照
+	errSnapshotTooOld = errors.New("depth snapshot older than buffered updates")
+)
+
+// DepthUpdate 增量深度推送 <symbol>@depth
+type DepthUpdate struct {
+	EventTime     int64                `json:"E"`
+	Symbol        string               `json:"s"`
+	FirstUpdateID int64                `json:"U"`
+	FinalUpdateID int64                `json:"u"`
+	Bids          [][2]decimal.Decimal `json:"b"`
+	Asks          [][2]decimal.Decimal `json:"a"`
+}
+
+/*
+	OrderBook 本地维护的订单簿
+	按 binance 文档的流程同步: 缓存增量推送 -> 获取REST快照 -> 丢弃 u <= lastUpdateId 的推送 -> 按 U/u 连续应用增量
+	bids 按价格从高到低排列，asks 按价格从低到高排列
+*/
+type OrderBook struct {
+	symbol string
+	pair   quant.CurrencyPair
+
+	mu           sync.RWMutex
+	bids         DepthRecords
+	asks         DepthRecords
+	lastUpdateID int64
+	synced       bool
+	buffer       []*DepthUpdate
+	updateTime   time.Time
+}
+
+func NewOrderBook(symbol string) *OrderBook {
+	return &OrderBook{symbol: symbol}
+}
+
+func (ob *OrderBook) Symbol() string {
+	return ob.symbol
+}
+
+// IsSynced 订单簿是否已与快照同步
+func (ob *OrderBook) IsSynced() bool {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	return ob.synced
+}
+
+func (ob *OrderBook) LastUpdateID() int64 {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	return ob.lastUpdateID
+}
+
+/*
+	ApplySnapshot 使用REST快照重建订单簿，并应用快照之后缓存的增量推送
+	缓存的第一条有效推送必须满足 U <= lastUpdateId+1 <= u
+*/
+func (ob *OrderBook) ApplySnapshot(snapshot *DepthMessage) error {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	buffer := ob.buffer[:0]
+	for _, u := range ob.buffer {
+		if u.FinalUpdateID > snapshot.LastUpdateID {
+			buffer = append(buffer, u)
+		}
+	}
+	ob.buffer = buffer
+	if len(buffer) > 0 && buffer[0].FirstUpdateID > snapshot.LastUpdateID+1 {
+		return errSnapshotTooOld
+	}
+
+	ob.bids = ob.bids[:0]
+	for _, bid := range snapshot.Bids {
+		if bid.Quantity.IsPositive() {
+			ob.bids = append(ob.bids, DepthRecord{Price: bid.Price, Amount: bid.Quantity})
+		}
+	}
+	ob.asks = ob.asks[:0]
+	for _, ask := range snapshot.Asks {
+		if ask.Quantity.IsPositive() {
+			ob.asks = append(ob.asks, DepthRecord{Price: ask.Price, Amount: ask.Quantity})
+		}
+	}
+	sort.Slice(ob.bids, func(i, j int) bool { return ob.bids[i].Price.GreaterThan(ob.bids[j].Price) })
+	sort.Slice(ob.asks, func(i, j int) bool { return ob.asks[i].Price.LessThan(ob.asks[j].Price) })
+	ob.lastUpdateID = snapshot.LastUpdateID
+	ob.updateTime = time.Now()
+	ob.synced = true
+
+	pending := ob.buffer
+	ob.buffer = nil
+	for i, u := range pending {
+		if err := ob.applyUpdateLocked(u); err != nil {
+			ob.buffer = pending[i:]
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	ApplyUpdate 应用一条增量推送
+	未同步时推送被缓存等待快照，缓存超过上限时只保留本条并返回 ErrOrderBookOverflow，需要重新获取快照
+	发现 update id 不连续时返回 ErrOrderBookGap，订单簿回到未同步状态
+*/
+func (ob *OrderBook) ApplyUpdate(u *DepthUpdate) error {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	if !ob.synced {
+		if len(ob.buffer) >= orderBookMaxBuffered {
+			Logger.Warn("order book buffer overflow ", zap.String("symbol", ob.symbol), zap.Int("buffered", len(ob.buffer)))
+			ob.buffer = []*DepthUpdate{u}
+			return ErrOrderBookOverflow
+		}
+		ob.buffer = append(ob.buffer, u)
+		return ErrOrderBookNotSynced
+	}
+	return ob.applyUpdateLocked(u)
+}
+
+/*
+	applyUpdateLocked 丢弃 u <= lastUpdateId 的推送，U > lastUpdateId+1 时说明有推送丢失
+	快照之后的第一条推送可能跨越快照(U <= lastUpdateId+1 <= u)，属于正常情况
+*/
+func (ob *OrderBook) applyUpdateLocked(u *DepthUpdate) error {
+	if u.FinalUpdateID <= ob.lastUpdateID {
+		return nil
+	}
+	if u.FirstUpdateID > ob.lastUpdateID+1 {
+		Logger.Warn("order book gap detected ", zap.String("symbol", ob.symbol),
+			zap.Int64("lastUpdateId", ob.lastUpdateID), zap.Int64("U", u.FirstUpdateID))
+		ob.synced = false
+		ob.buffer = []*DepthUpdate{u}
+		return ErrOrderBookGap
+	}
+	ob.applyLocked(u)
+	return nil
+}
+
+func (ob *OrderBook) applyLocked(u *DepthUpdate) {
+	for _, bid := range u.Bids {
+		ob.bids = updateLevel(ob.bids, bid[0], bid[1], true)
+	}
+	for _, ask := range u.Asks {
+		ob.asks = updateLevel(ob.asks, ask[0], ask[1], false)
+	}
+	ob.lastUpdateID = u.FinalUpdateID
+	ob.updateTime = time.Now()
+}
+
+// updateLevel 更新有序的价格档位，数量为0时删除该档位
+func updateLevel(levels DepthRecords, price, amount decimal.Decimal, descending bool) DepthRecords {
+	i := sort.Search(len(levels), func(i int) bool {
+		if descending {
+			return levels[i].Price.LessThanOrEqual(price)
+		}
+		return levels[i].Price.GreaterThanOrEqual(price)
+	})
+	found := i < len(levels) && levels[i].Price.Equal(price)
+	switch {
+	case amount.IsZero() && found:
+		return append(levels[:i], levels[i+1:]...)
+	case amount.IsZero():
+		return levels
+	case found:
+		levels[i].Amount = amount
+		return levels
+	}
+	levels = append(levels, DepthRecord{})
+	copy(levels[i+1:], levels[i:])
+	levels[i] = DepthRecord{Price: price, Amount: amount}
+	return levels
+}
+
+// BestBid 最高买价
+func (ob *OrderBook) BestBid() (DepthRecord, bool) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	if !ob.synced || len(ob.bids) == 0 {
+		return DepthRecord{}, false
+	}
+	return ob.bids[0], true
+}
+
+// BestAsk 最低卖价
+func (ob *OrderBook) BestAsk() (DepthRecord, bool) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	if !ob.synced || len(ob.asks) == 0 {
+		return DepthRecord{}, false
+	}
+	return ob.asks[0], true
+}
+
+// Bids 前n档买单，n<=0 返回全部，未同步时返回空
+func (ob *OrderBook) Bids(n int) DepthRecords {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	if !ob.synced {
+		return nil
+	}
+	return topLevels(ob.bids, n)
+}
+
+// Asks 前n档卖单，n<=0 返回全部，未同步时返回空
+func (ob *OrderBook) Asks(n int) DepthRecords {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	if !ob.synced {
+		return nil
+	}
+	return topLevels(ob.asks, n)
+}
+
+func topLevels(levels DepthRecords, n int) DepthRecords {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	top := make(DepthRecords, n)
+	copy(top, levels[:n])
+	return top
+}
+
+// Depth 前n档深度快照，未同步时返回 ErrOrderBookNotSynced
+func (ob *OrderBook) Depth(n int) (*Depth, error) {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	if !ob.synced {
+		return nil, ErrOrderBookNotSynced
+	}
+	return &Depth{
+		Pair:    ob.pair,
+		Symbol:  ob.symbol,
+		UTime:   ob.updateTime,
+		BidList: topLevels(ob.bids, n),
+		AskList: topLevels(ob.asks, n),
+	}, nil
+}
+
+// CumulativeBidDepth 价格不低于price的买单总量，即以price卖出时可成交的数量，未同步时为0
+func (ob *OrderBook) CumulativeBidDepth(price decimal.Decimal) decimal.Decimal {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	total := decimal.Zero
+	if !ob.synced {
+		return total
+	}
+	for _, bid := range ob.bids {
+		if bid.Price.LessThan(price) {
+			break
+		}
+		total = total.Add(bid.Amount)
+	}
+	return total
+}
+
+// CumulativeAskDepth 价格不高于price的卖单总量，即以price买入时可成交的数量，未同步时为0
+func (ob *OrderBook) CumulativeAskDepth(price decimal.Decimal) decimal.Decimal {
+	ob.mu.RLock()
+	defer ob.mu.RUnlock()
+	total := decimal.Zero
+	if !ob.synced {
+		return total
+	}
+	for _, ask := range ob.asks {
+		if ask.Price.GreaterThan(price) {
+			break
+		}
+		total = total.Add(ask.Amount)
+	}
+	return total
+}
+
+/*
+	SubscribeOrderBook 订阅增量深度并维护本地订单簿
+	rest : 用于获取深度快照，发现推送不连续时自动重新同步
+	callback : 每次订单簿更新后回调，可为nil
+*/
+func (bw *BinanceWs) SubscribeOrderBook(rest *Binance, pair quant.CurrencyPair, callback func(*OrderBook)) (*OrderBook, error) {
+	symbol := toSymbol(pair)
+	ob := NewOrderBook(symbol)
+	ob.pair = pair
+	resyncCh := make(chan struct{}, 1)
+	triggerResync := func() {
+		select {
+		case resyncCh <- struct{}{}:
+		default:
+		}
+	}
+
+	stream := fmt.Sprintf("%s@depth@100ms", strings.ToLower(symbol))
+	handle := func(msg []byte) error {
+		update := new(DepthUpdate)
+		if err := json.Unmarshal(msg, update); err != nil {
+			Logger.Error("json unmarshal error for ", zap.ByteString("msg", msg), zap.Error(err))
+			return err
+		}
+		switch err := ob.ApplyUpdate(update); err {
+		case nil:
+			if callback != nil {
+				callback(ob)
+			}
+		case ErrOrderBookGap, ErrOrderBookOverflow:
+			triggerResync()
+		}
+		return nil
+	}
+	if err := bw.subscribe(stream, handle); err != nil {
+		return nil, err
+	}
+
+	// 快照请求使用 bw.ctx，Close 时中断进行中的请求
+	go func() {
+		triggerResync()
+		for {
+			select {
+			case <-bw.closeCh:
+				return
+			case <-resyncCh:
+			}
+			for !bw.isClosed() {
+				err := bw.syncOrderBook(bw.ctx, rest, ob)
+				if err == nil {
+					break
+				}
+				Logger.Warn("order book resync failed ", zap.String("symbol", ob.symbol), zap.Error(err))
+				util.Sleep(bw.ctx, orderBookResyncDelay)
+			}
+		}
+	}()
+	return ob, nil
+}
+
+func (bw *BinanceWs) syncOrderBook(ctx context.Context, rest *Binance, ob *OrderBook) error {
+	// 先等待推送到达，保证快照之后的增量都已被缓存
+	if err := util.Sleep(ctx, orderBookResyncDelay); err != nil {
+		return err
+	}
+	snapshot, err := rest.GetDepthMessage(ctx, ob.symbol, orderBookSnapshotLimit)
+	if err != nil {
+		return err
+	}
+	return ob.ApplySnapshot(snapshot)
+}
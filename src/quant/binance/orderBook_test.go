@@ -0,0 +1,172 @@
+package binance_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+func init() {
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestOrderBookSync(t *testing.T) {
+	ob := binance.NewOrderBook("BTCUSDT")
+	// 快照之前到达的推送会被缓存
+	updates := []*binance.DepthUpdate{
+		{FirstUpdateID: 90, FinalUpdateID: 100, Bids: [][2]decimal.Decimal{{dec("9.0"), dec("5")}}},
+		{FirstUpdateID: 101, FinalUpdateID: 105, Bids: [][2]decimal.Decimal{{dec("10.0"), dec("0")}, {dec("9.5"), dec("2")}}},
+		{FirstUpdateID: 106, FinalUpdateID: 106, Asks: [][2]decimal.Decimal{{dec("11.0"), dec("4")}}},
+	}
+	for _, u := range updates {
+		if err := ob.ApplyUpdate(u); err != binance.ErrOrderBookNotSynced {
+			t.Fatalf("expected buffered update, got %v", err)
+		}
+	}
+	snapshot := &binance.DepthMessage{
+		LastUpdateID: 102,
+		Bids:         []*binance.Bid{{Price: dec("10"), Quantity: dec("1")}, {Price: dec("9"), Quantity: dec("3")}},
+		Asks:         []*binance.Ask{{Price: dec("11"), Quantity: dec("2")}, {Price: dec("12"), Quantity: dec("1")}},
+	}
+	if err := ob.ApplySnapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if !ob.IsSynced() || ob.LastUpdateID() != 106 {
+		t.Fatalf("unexpected state synced=%v lastUpdateId=%d", ob.IsSynced(), ob.LastUpdateID())
+	}
+	bid, _ := ob.BestBid()
+	ask, _ := ob.BestAsk()
+	if !bid.Price.Equal(dec("9.5")) || !bid.Amount.Equal(dec("2")) || !ask.Price.Equal(dec("11")) || !ask.Amount.Equal(dec("4")) {
+		t.Fatalf("unexpected top of book bid=%+v ask=%+v", bid, ask)
+	}
+	if depth := ob.CumulativeBidDepth(dec("9")); !depth.Equal(dec("5")) {
+		t.Fatalf("unexpected cumulative bid depth %v", depth)
+	}
+	if depth := ob.CumulativeAskDepth(dec("12")); !depth.Equal(dec("5")) {
+		t.Fatalf("unexpected cumulative ask depth %v", depth)
+	}
+	if asks := ob.Asks(1); len(asks) != 1 || !asks[0].Price.Equal(dec("11")) {
+		t.Fatalf("unexpected top asks %+v", asks)
+	}
+
+	err := ob.ApplyUpdate(&binance.DepthUpdate{FirstUpdateID: 110, FinalUpdateID: 111})
+	if err != binance.ErrOrderBookGap || ob.IsSynced() {
+		t.Fatalf("expected gap, got %v", err)
+	}
+}
+
+func TestOrderBookFirstLiveEventSpansSnapshot(t *testing.T) {
+	ob := binance.NewOrderBook("BTCUSDT")
+	// 快照比所有缓存的推送都新，缓存为空
+	if err := ob.ApplySnapshot(&binance.DepthMessage{
+		LastUpdateID: 200,
+		Bids:         []*binance.Bid{{Price: dec("10"), Quantity: dec("1")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.ApplyUpdate(&binance.DepthUpdate{FirstUpdateID: 150, FinalUpdateID: 200}); err != nil {
+		t.Fatalf("stale update should be dropped, got %v", err)
+	}
+	spanning := &binance.DepthUpdate{FirstUpdateID: 195, FinalUpdateID: 210, Bids: [][2]decimal.Decimal{{dec("10"), dec("4")}}}
+	if err := ob.ApplyUpdate(spanning); err != nil {
+		t.Fatalf("update spanning the snapshot flagged as %v", err)
+	}
+	if !ob.IsSynced() || ob.LastUpdateID() != 210 {
+		t.Fatalf("unexpected state synced=%v lastUpdateId=%d", ob.IsSynced(), ob.LastUpdateID())
+	}
+	if bid, _ := ob.BestBid(); !bid.Amount.Equal(dec("4")) {
+		t.Fatalf("unexpected best bid %+v", bid)
+	}
+	if err := ob.ApplyUpdate(&binance.DepthUpdate{FirstUpdateID: 211, FinalUpdateID: 215}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOrderBookUnsyncedReadsEmpty(t *testing.T) {
+	ob := binance.NewOrderBook("BTCUSDT")
+	ob.ApplySnapshot(&binance.DepthMessage{
+		LastUpdateID: 100,
+		Bids:         []*binance.Bid{{Price: dec("10"), Quantity: dec("1")}},
+		Asks:         []*binance.Ask{{Price: dec("11"), Quantity: dec("2")}},
+	})
+	if _, err := ob.Depth(0); err != nil {
+		t.Fatal(err)
+	}
+	// 推送不连续后订单簿已不可信，读取不应返回旧数据
+	if err := ob.ApplyUpdate(&binance.DepthUpdate{FirstUpdateID: 110, FinalUpdateID: 111}); err != binance.ErrOrderBookGap {
+		t.Fatalf("expected gap, got %v", err)
+	}
+	if bids, asks := ob.Bids(0), ob.Asks(0); len(bids) != 0 || len(asks) != 0 {
+		t.Fatalf("unsynced book returned bids %+v asks %+v", bids, asks)
+	}
+	if depth, err := ob.Depth(5); err != binance.ErrOrderBookNotSynced || depth != nil {
+		t.Fatalf("unsynced book returned depth %+v, err %v", depth, err)
+	}
+	if !ob.CumulativeBidDepth(dec("0")).IsZero() || !ob.CumulativeAskDepth(dec("100")).IsZero() {
+		t.Fatal("unsynced book returned cumulative depth")
+	}
+}
+
+func TestOrderBookBufferOverflow(t *testing.T) {
+	ob := binance.NewOrderBook("BTCUSDT")
+	var err error
+	id := int64(0)
+	for err != binance.ErrOrderBookOverflow {
+		if id > 100000 {
+			t.Fatal("buffer never overflowed")
+		}
+		id++
+		err = ob.ApplyUpdate(&binance.DepthUpdate{FirstUpdateID: id, FinalUpdateID: id})
+	}
+	// 溢出后只保留最新一条推送，新快照可以直接衔接
+	if err := ob.ApplySnapshot(&binance.DepthMessage{LastUpdateID: id - 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !ob.IsSynced() || ob.LastUpdateID() != id {
+		t.Fatalf("unexpected state synced=%v lastUpdateId=%d", ob.IsSynced(), ob.LastUpdateID())
+	}
+}
+
+func TestOrderBookCloseCancelsResync(t *testing.T) {
+	var conns int32
+	streams := newStreamServer(&conns)
+	defer streams.Close()
+	requested := make(chan struct{})
+	canceled := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(requested)
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer srv.Close()
+
+	bw := binance.NewBinanceWS("ws"+strings.TrimPrefix(streams.URL, "http")+"/ws", "")
+	if _, err := bw.SubscribeOrderBook(newTestBinance(srv), quant.BTC_USDT, nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-requested:
+	case <-time.After(3 * time.Second):
+		t.Fatal("snapshot not requested")
+	}
+	bw.Close()
+	select {
+	case <-canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("snapshot request not canceled on Close")
+	}
+}
@@ -0,0 +1,192 @@
+package binance
+
+import (
+	"fmt"
+	"tinyquant/src/quant"
+
+	"github.com/shopspring/decimal"
+)
+
+// FilterError 订单不满足交易对的交易规则
+type FilterError struct {
+	Symbol     string
+	FilterType string
+	Reason     string
+}
+
+func (e *FilterError) Error() string {
+	return fmt.Sprintf("order for %s rejected by %s: %s", e.Symbol, e.FilterType, e.Reason)
+}
+
+/*
+	ValidateOrder 按交易规则校验并修正订单，返回可直接提交的价格与数量
+	price : 按 tickSize 四舍五入，市价单与 STOP_LOSS / TAKE_PROFIT 忽略并返回0
+	quantity : 按 stepSize 向下取整，避免超过可用余额
+	avgPrice : 近5分钟平均价，用于 PERCENT_PRICE 与市价单的 MIN_NOTIONAL 校验，为0时跳过
+*/
+func (ts *TradeSymbol) ValidateOrder(orderType string, price, quantity, avgPrice decimal.Decimal) (decimal.Decimal, decimal.Decimal, error) {
+	if !ts.IsTrading() {
+		return decimal.Zero, decimal.Zero, &FilterError{ts.Symbol, "STATUS", fmt.Sprintf("symbol status is %s", ts.Status)}
+	}
+	if len(ts.OrderTypes) > 0 && !ts.SupportsOrderType(orderType) {
+		return decimal.Zero, decimal.Zero, &FilterError{ts.Symbol, "ORDER_TYPES", fmt.Sprintf("order type %s not allowed", orderType)}
+	}
+	isMarket := !hasLimitPrice(orderType)
+
+	if isMarket {
+		price = decimal.Zero
+	} else {
+		var err error
+		if price, err = ts.checkPrice(price, avgPrice); err != nil {
+			return decimal.Zero, decimal.Zero, err
+		}
+	}
+
+	quantity, err := ts.checkQuantity(quantity, isMarket)
+	if err != nil {
+		return decimal.Zero, decimal.Zero, err
+	}
+
+	if f := ts.MinNotionalFilter(); f != nil && f.MinNotional.IsPositive() {
+		notionalPrice := price
+		if isMarket {
+			notionalPrice = decimal.Zero
+			if f.ApplyToMarket {
+				notionalPrice = avgPrice
+			}
+		}
+		notional := notionalPrice.Mul(quantity)
+		if notionalPrice.IsPositive() && notional.LessThan(f.MinNotional) {
+			return decimal.Zero, decimal.Zero, &FilterError{ts.Symbol, FILTER_MIN_NOTIONAL,
+				fmt.Sprintf("notional %s below minNotional %s", notional, f.MinNotional)}
+		}
+	}
+	return price, quantity, nil
+}
+
+// hasLimitPrice 订单类型是否需要限价，STOP_LOSS / TAKE_PROFIT 触发后按市价成交
+func hasLimitPrice(orderType string) bool {
+	switch orderType {
+	case quant.ORDER_TYPE_MARKET, quant.ORDER_TYPE_STOP_LOSS, quant.ORDER_TYPE_TAKE_PROFIT:
+		return false
+	}
+	return true
+}
+
+// needsAvgPrice 校验该类型的订单是否需要平均价
+func (ts *TradeSymbol) needsAvgPrice(orderType string) bool {
+	if hasLimitPrice(orderType) {
+		return ts.PercentPriceFilter() != nil
+	}
+	f := ts.MinNotionalFilter()
+	return f != nil && f.ApplyToMarket && f.MinNotional.IsPositive()
+}
+
+// ValidateStopPrice 止损止盈触发价按 PRICE_FILTER 校验并按 tickSize 四舍五入
+func (ts *TradeSymbol) ValidateStopPrice(stopPrice decimal.Decimal) (decimal.Decimal, error) {
+	return ts.checkPrice(stopPrice, decimal.Zero)
+}
+
+/*
+	ValidateIceberg 校验冰山单显示数量
+	icebergQty 按 stepSize 向下取整，拆分的份数不能超过 ICEBERG_PARTS
+*/
+func (ts *TradeSymbol) ValidateIceberg(quantity, icebergQty decimal.Decimal) (decimal.Decimal, error) {
+	if !ts.IcebergAllowed {
+		return decimal.Zero, &FilterError{ts.Symbol, FILTER_ICEBERG_PARTS, "iceberg orders not allowed"}
+	}
+	if f := ts.LotSizeFilter(); f != nil && f.StepSize.IsPositive() {
+		icebergQty = roundToStep(icebergQty, f.StepSize, true)
+	}
+	if !icebergQty.IsPositive() || icebergQty.GreaterThanOrEqual(quantity) {
+		return decimal.Zero, &FilterError{ts.Symbol, FILTER_ICEBERG_PARTS, fmt.Sprintf("invalid icebergQty %s for quantity %s", icebergQty, quantity)}
+	}
+	if f := ts.Filter(FILTER_ICEBERG_PARTS); f != nil && f.Limit > 0 {
+		parts := quantity.Div(icebergQty).Ceil()
+		if parts.GreaterThan(decimal.New(int64(f.Limit), 0)) {
+			return decimal.Zero, &FilterError{ts.Symbol, FILTER_ICEBERG_PARTS, fmt.Sprintf("%s parts exceed limit %d", parts, f.Limit)}
+		}
+	}
+	return icebergQty, nil
+}
+
+// ValidateQuoteOrderQty 校验按计价货币金额下的市价单
+func (ts *TradeSymbol) ValidateQuoteOrderQty(quoteOrderQty decimal.Decimal) error {
+	if !ts.IsTrading() {
+		return &FilterError{ts.Symbol, "STATUS", fmt.Sprintf("symbol status is %s", ts.Status)}
+	}
+	if !ts.QuoteOrderQtyMarketAllowed {
+		return &FilterError{ts.Symbol, "QUOTE_ORDER_QTY", "quoteOrderQty market orders not allowed"}
+	}
+	if !quoteOrderQty.IsPositive() {
+		return &FilterError{ts.Symbol, "QUOTE_ORDER_QTY", fmt.Sprintf("invalid quoteOrderQty %s", quoteOrderQty)}
+	}
+	if f := ts.MinNotionalFilter(); f != nil && f.ApplyToMarket && quoteOrderQty.LessThan(f.MinNotional) {
+		return &FilterError{ts.Symbol, FILTER_MIN_NOTIONAL, fmt.Sprintf("quoteOrderQty %s below minNotional %s", quoteOrderQty, f.MinNotional)}
+	}
+	return nil
+}
+
+func (ts *TradeSymbol) checkPrice(price, avgPrice decimal.Decimal) (decimal.Decimal, error) {
+	if !price.IsPositive() {
+		return decimal.Zero, &FilterError{ts.Symbol, FILTER_PRICE, fmt.Sprintf("invalid price %s", price)}
+	}
+	if f := ts.PriceFilter(); f != nil {
+		if f.TickSize.IsPositive() {
+			price = roundToStep(price, f.TickSize, false)
+		}
+		if f.MinPrice.IsPositive() && price.LessThan(f.MinPrice) {
+			return decimal.Zero, &FilterError{ts.Symbol, FILTER_PRICE, fmt.Sprintf("price %s below minPrice %s", price, f.MinPrice)}
+		}
+		if f.MaxPrice.IsPositive() && price.GreaterThan(f.MaxPrice) {
+			return decimal.Zero, &FilterError{ts.Symbol, FILTER_PRICE, fmt.Sprintf("price %s above maxPrice %s", price, f.MaxPrice)}
+		}
+	}
+	if f := ts.PercentPriceFilter(); f != nil && avgPrice.IsPositive() {
+		if f.MultiplierUp.IsPositive() && price.GreaterThan(avgPrice.Mul(f.MultiplierUp)) {
+			return decimal.Zero, &FilterError{ts.Symbol, FILTER_PERCENT_PRICE,
+				fmt.Sprintf("price %s above %s x avgPrice %s", price, f.MultiplierUp, avgPrice)}
+		}
+		if f.MultiplierDown.IsPositive() && price.LessThan(avgPrice.Mul(f.MultiplierDown)) {
+			return decimal.Zero, &FilterError{ts.Symbol, FILTER_PERCENT_PRICE,
+				fmt.Sprintf("price %s below %s x avgPrice %s", price, f.MultiplierDown, avgPrice)}
+		}
+	}
+	return price, nil
+}
+
+func (ts *TradeSymbol) checkQuantity(quantity decimal.Decimal, isMarket bool) (decimal.Decimal, error) {
+	if !quantity.IsPositive() {
+		return decimal.Zero, &FilterError{ts.Symbol, FILTER_LOT_SIZE, fmt.Sprintf("invalid quantity %s", quantity)}
+	}
+	filters := []*Filter{ts.LotSizeFilter()}
+	// 市价单同时受 LOT_SIZE 与 MARKET_LOT_SIZE 约束
+	if isMarket {
+		filters = append(filters, ts.MarketLotSizeFilter())
+	}
+	for _, f := range filters {
+		if f == nil {
+			continue
+		}
+		if f.StepSize.IsPositive() {
+			quantity = roundToStep(quantity, f.StepSize, true)
+		}
+		if f.MinQty.IsPositive() && quantity.LessThan(f.MinQty) {
+			return decimal.Zero, &FilterError{ts.Symbol, f.FilterType, fmt.Sprintf("quantity %s below minQty %s", quantity, f.MinQty)}
+		}
+		if f.MaxQty.IsPositive() && quantity.GreaterThan(f.MaxQty) {
+			return decimal.Zero, &FilterError{ts.Symbol, f.FilterType, fmt.Sprintf("quantity %s above maxQty %s", quantity, f.MaxQty)}
+		}
+	}
+	return quantity, nil
+}
+
+// roundToStep 将 v 调整为 step 的整数倍，floor 为 true 时向下取整，否则四舍五入(0.5向上)
+func roundToStep(v, step decimal.Decimal, floor bool) decimal.Decimal {
+	rem := v.Mod(step)
+	rounded := v.Sub(rem)
+	if !floor && rem.Mul(decimal.New(2, 0)).GreaterThanOrEqual(step) {
+		rounded = rounded.Add(step)
+	}
+	return rounded
+}
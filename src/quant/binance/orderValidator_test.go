@@ -0,0 +1,125 @@
+package binance_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+
+	"github.com/shopspring/decimal"
+)
+
+const btcusdtInfo = `{
+	"symbol": "BTCUSDT",
+	"status": "TRADING",
+	"orderTypes": ["LIMIT", "LIMIT_MAKER", "MARKET"],
+	"filters": [
+		{"filterType": "PRICE_FILTER", "minPrice": "0.01000000", "maxPrice": "1000000.00000000", "tickSize": "0.01000000"},
+		{"filterType": "PERCENT_PRICE", "multiplierUp": "5", "multiplierDown": "0.2", "avgPriceMins": 5},
+		{"filterType": "LOT_SIZE", "minQty": "0.00000100", "maxQty": "9000.00000000", "stepSize": "0.00000100"},
+		{"filterType": "MIN_NOTIONAL", "minNotional": "10.00000000", "applyToMarket": true, "avgPriceMins": 5},
+		{"filterType": "MARKET_LOT_SIZE", "minQty": "0.00000000", "maxQty": "100.00000000", "stepSize": "0.00000000"}
+	]
+}`
+
+func loadSymbol(t *testing.T) *binance.TradeSymbol {
+	ts := new(binance.TradeSymbol)
+	if err := json.Unmarshal([]byte(btcusdtInfo), ts); err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestValidateOrderRounding(t *testing.T) {
+	ts := loadSymbol(t)
+	price, qty, err := ts.ValidateOrder("LIMIT", dec("9123.455"), dec("0.0123456789"), dec("9100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price.String() != "9123.46" || qty.String() != "0.012345" {
+		t.Fatalf("unexpected rounding price=%s qty=%s", price, qty)
+	}
+
+	// 0.3/0.0001 在float64下为 2999.9999999999995，按十进制计算不会少一个步长
+	ts.LotSizeFilter().StepSize = dec("0.0001")
+	_, qty, err = ts.ValidateOrder("LIMIT", dec("9000"), dec("0.3"), decimal.Zero)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qty.String() != "0.3" {
+		t.Fatalf("quantity drifted to %s", qty)
+	}
+}
+
+func TestValidateOrderRejects(t *testing.T) {
+	ts := loadSymbol(t)
+	cases := []struct {
+		orderType       string
+		price, qty, avg string
+		filter          string
+	}{
+		{"LIMIT", "9000", "0.0000001", "0", binance.FILTER_LOT_SIZE},
+		{"LIMIT", "9000", "10000", "0", binance.FILTER_LOT_SIZE},
+		{"LIMIT", "9000", "0.001", "0", binance.FILTER_MIN_NOTIONAL},
+		{"LIMIT", "60000", "0.01", "9000", binance.FILTER_PERCENT_PRICE},
+		{"MARKET", "0", "200", "9000", binance.FILTER_MARKET_LOT_SIZE},
+		{"MARKET", "0", "0.001", "9000", binance.FILTER_MIN_NOTIONAL},
+		{"STOP_LOSS", "9000", "0.01", "0", "ORDER_TYPES"},
+	}
+	for _, c := range cases {
+		_, _, err := ts.ValidateOrder(c.orderType, dec(c.price), dec(c.qty), dec(c.avg))
+		filterErr, ok := err.(*binance.FilterError)
+		if !ok || filterErr.FilterType != c.filter {
+			t.Errorf("%+v: expected %s error, got %v", c, c.filter, err)
+		}
+	}
+}
+
+func TestValidateIcebergAndQuoteOrderQty(t *testing.T) {
+	ts := loadSymbol(t)
+	if _, err := ts.ValidateIceberg(dec("1"), dec("0.1")); err == nil {
+		t.Fatal("expected error when iceberg orders are not allowed")
+	}
+	ts.IcebergAllowed = true
+	ts.Filters = append(ts.Filters, binance.Filter{FilterType: binance.FILTER_ICEBERG_PARTS, Limit: 10})
+	iceberg, err := ts.ValidateIceberg(dec("1"), dec("0.12345678"))
+	if err != nil || iceberg.String() != "0.123456" {
+		t.Fatalf("iceberg %s, %v", iceberg, err)
+	}
+	if _, err := ts.ValidateIceberg(dec("1"), dec("0.05")); err == nil {
+		t.Fatal("expected ICEBERG_PARTS error for 20 parts")
+	}
+
+	if err := ts.ValidateQuoteOrderQty(dec("100")); err == nil {
+		t.Fatal("expected error when quoteOrderQty is not allowed")
+	}
+	ts.QuoteOrderQtyMarketAllowed = true
+	if err := ts.ValidateQuoteOrderQty(dec("100")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ts.ValidateQuoteOrderQty(dec("5")); err == nil {
+		t.Fatal("expected MIN_NOTIONAL error")
+	}
+}
+
+func TestPlaceOrderRejectedByPercentPrice(t *testing.T) {
+	srv, requests := recordServer(`{"mins":5,"price":"100.00"}`)
+	defer srv.Close()
+	b := newTestBinance(srv)
+	registry := binance.NewSymbolRegistry(b, 0)
+	registry.Load(&binance.ExchangeInfo{Symbols: []binance.TradeSymbol{*loadSymbol(t)}})
+	b.SetSymbolRegistry(registry)
+
+	for _, price := range []string{"1000", "10"} {
+		_, err := b.PlaceOrder(context.Background(), &quant.OrderRequest{Pair: quant.BTC_USDT, Side: binance.BUY, Price: dec(price), Amount: dec("1")})
+		filterErr, ok := err.(*binance.FilterError)
+		if !ok || filterErr.FilterType != binance.FILTER_PERCENT_PRICE {
+			t.Fatalf("price %s: expected PERCENT_PRICE error, got %v", price, err)
+		}
+	}
+	// 平均价只请求一次，订单没有发送
+	if len(*requests) != 1 || (*requests)[0].path != "/api/v3/avgPrice" || (*requests)[0].query.Get("symbol") != "BTCUSDT" {
+		t.Fatalf("unexpected requests %+v", *requests)
+	}
+}
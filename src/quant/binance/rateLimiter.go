@@ -0,0 +1,337 @@
+package binance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"tinyquant/src/mod"
+	"tinyquant/src/util"
+)
+
+const (
+	rateLimitRequestWeight = "REQUEST_WEIGHT"
+	rateLimitOrders        = "ORDERS"
+	rateLimitRawRequests   = "RAW_REQUESTS"
+
+	usedWeightHeaderPrefix = "X-MBX-USED-WEIGHT-"
+	orderCountHeaderPrefix = "X-MBX-ORDER-COUNT-"
+
+	defaultRateLimitMaxWait = time.Minute
+	// 429/418 响应未返回 Retry-After 时的暂停时间
+	defaultRetryAfter = time.Minute
+)
+
+// defaultRateLimits exchangeInfo 获取之前使用的默认限制
+var defaultRateLimits = []RateLimit{
+	{Interval: "MINUTE", IntervalNum: 1, Limit: 1200, RateLimitType: rateLimitRequestWeight},
+	{Interval: "SECOND", IntervalNum: 10, Limit: 50, RateLimitType: rateLimitOrders},
+	{Interval: "DAY", IntervalNum: 1, Limit: 160000, RateLimitType: rateLimitOrders},
+	{Interval: "MINUTE", IntervalNum: 5, Limit: 6100, RateLimitType: rateLimitRawRequests},
+}
+
+// defaultRateLimiter 同一IP下的所有 Binance 实例共享
+var defaultRateLimiter = NewRateLimiter()
+
+// RateLimitError 请求会超出频率限制或IP处于封禁期，在本地被拒绝
+type RateLimitError struct {
+	Wait   time.Duration // 需要等待的时间
+	Reason string
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("binance rate limit: %s, retry after %s", e.Reason, e.Wait)
+}
+
+type rateWindow struct {
+	limitType string
+	interval  time.Duration
+	header    string // 对应的响应头，RAW_REQUESTS 没有
+	limit     int64
+	used      int64
+	start     time.Time
+}
+
+// reset 窗口按自然时间对齐，如每分钟的0秒
+func (w *rateWindow) reset(now time.Time) {
+	start := now.Truncate(w.interval)
+	if !start.Equal(w.start) {
+		w.start = start
+		w.used = 0
+	}
+}
+
+/*
+	RateLimiter 按接口权重限制请求频率
+	限制来自 exchangeInfo 的 rateLimits，并根据响应头 X-MBX-USED-WEIGHT-* / X-MBX-ORDER-COUNT-* 校正已用额度
+	请求会超出限制时等待窗口重置，等待时间超过 maxWait 或 ctx 截止时间时返回 *RateLimitError
+*/
+type RateLimiter struct {
+	mu          sync.Mutex
+	windows     []*rateWindow
+	bannedUntil time.Time
+	maxWait     time.Duration
+}
+
+func NewRateLimiter() *RateLimiter {
+	rl := &RateLimiter{maxWait: defaultRateLimitMaxWait}
+	rl.SetRateLimits(defaultRateLimits)
+	return rl
+}
+
+// SetMaxWait 最长等待时间，为0时超出限制立即返回错误
+func (rl *RateLimiter) SetMaxWait(maxWait time.Duration) *RateLimiter {
+	rl.mu.Lock()
+	rl.maxWait = maxWait
+	rl.mu.Unlock()
+	return rl
+}
+
+// SetRateLimits 使用 exchangeInfo 返回的限制，已用额度保留
+func (rl *RateLimiter) SetRateLimits(limits []RateLimit) *RateLimiter {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	windows := make([]*rateWindow, 0, len(limits))
+	for _, limit := range limits {
+		interval, unit := intervalDuration(limit.Interval)
+		if interval == 0 || limit.IntervalNum <= 0 || limit.Limit <= 0 {
+			continue
+		}
+		w := &rateWindow{
+			limitType: limit.RateLimitType,
+			interval:  time.Duration(limit.IntervalNum) * interval,
+			limit:     limit.Limit,
+		}
+		suffix := strconv.FormatInt(limit.IntervalNum, 10) + unit
+		switch w.limitType {
+		case rateLimitRequestWeight:
+			w.header = usedWeightHeaderPrefix + suffix
+		case rateLimitOrders:
+			w.header = orderCountHeaderPrefix + suffix
+		}
+		for _, old := range rl.windows {
+			if old.limitType == w.limitType && old.interval == w.interval {
+				w.used, w.start = old.used, old.start
+			}
+		}
+		windows = append(windows, w)
+	}
+	rl.windows = windows
+	return rl
+}
+
+func intervalDuration(interval string) (time.Duration, string) {
+	switch interval {
+	case "SECOND":
+		return time.Second, "S"
+	case "MINUTE":
+		return time.Minute, "M"
+	case "HOUR":
+		return time.Hour, "H"
+	case "DAY":
+		return 24 * time.Hour, "D"
+	}
+	return 0, ""
+}
+
+// cost 请求在该窗口占用的额度
+func (w *rateWindow) cost(weight int64, isOrder bool) int64 {
+	switch w.limitType {
+	case rateLimitRequestWeight:
+		return weight
+	case rateLimitRawRequests:
+		return 1
+	case rateLimitOrders:
+		if isOrder {
+			return 1
+		}
+	}
+	return 0
+}
+
+/*
+	Wait 预占请求额度，额度不足时等待
+	weight : 接口权重
+	isOrder : 是否为下单请求，计入 ORDERS 限制
+*/
+func (rl *RateLimiter) Wait(ctx context.Context, weight int64, isOrder bool) error {
+	for {
+		wait, reason := rl.reserve(weight, isOrder)
+		if wait == 0 {
+			return nil
+		}
+		rl.mu.Lock()
+		maxWait := rl.maxWait
+		rl.mu.Unlock()
+		if wait > maxWait {
+			return &RateLimitError{Wait: wait, Reason: reason}
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return &RateLimitError{Wait: wait, Reason: reason}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve 额度足够时占用并返回0，否则返回需要等待的时间
+func (rl *RateLimiter) reserve(weight int64, isOrder bool) (time.Duration, string) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	if now.Before(rl.bannedUntil) {
+		return rl.bannedUntil.Sub(now), "blocked by server"
+	}
+	var wait time.Duration
+	var reason string
+	for _, w := range rl.windows {
+		cost := w.cost(weight, isOrder)
+		if cost == 0 {
+			continue
+		}
+		w.reset(now)
+		if w.used+cost <= w.limit {
+			continue
+		}
+		if cost > w.limit {
+			// 单次请求超过上限，等待也无法满足
+			return time.Duration(1<<63 - 1), fmt.Sprintf("%s %d exceeds limit %d", w.limitType, cost, w.limit)
+		}
+		if d := w.start.Add(w.interval).Sub(now); d > wait {
+			wait = d
+			reason = fmt.Sprintf("%s %d/%d per %s", w.limitType, w.used, w.limit, w.interval)
+		}
+	}
+	if wait > 0 {
+		return wait, reason
+	}
+	for _, w := range rl.windows {
+		w.used += w.cost(weight, isOrder)
+	}
+	return 0, ""
+}
+
+// Update 根据响应头校正已用额度，服务器统计的是同一IP/账户下所有客户端的请求
+func (rl *RateLimiter) Update(header http.Header) {
+	if header == nil {
+		return
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	for _, w := range rl.windows {
+		if w.header == "" {
+			continue
+		}
+		value := header.Get(w.header)
+		if value == "" {
+			continue
+		}
+		used, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		w.reset(now)
+		if used > w.used {
+			w.used = used
+		}
+	}
+}
+
+// Block 收到429/418后暂停所有请求，retryAfter 为0时使用默认时间
+func (rl *RateLimiter) Block(retryAfter time.Duration) {
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	until := time.Now().Add(retryAfter)
+	if until.After(rl.bannedUntil) {
+		rl.bannedUntil = until
+	}
+}
+
+// Used 返回指定限制类型当前窗口的已用额度，interval 为窗口长度
+func (rl *RateLimiter) Used(limitType string, interval time.Duration) int64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	for _, w := range rl.windows {
+		if w.limitType == limitType && w.interval == interval {
+			w.reset(time.Now())
+			return w.used
+		}
+	}
+	return 0
+}
+
+/*
+	requestWeight 接口权重，参考 binance 现货 REST 文档
+	isOrder 为 true 的请求计入 ORDERS 限制
+*/
+func requestWeight(r *mod.ReqParam) (weight int64, isOrder bool) {
+	hasSymbol := requestParam(r, util.SymbolKey) != ""
+	switch r.URL {
+	case util.DepthURL:
+		limit, _ := strconv.Atoi(requestParam(r, util.LimitKey))
+		switch {
+		case limit <= 100:
+			return 1, false
+		case limit <= 500:
+			return 5, false
+		case limit <= 1000:
+			return 10, false
+		}
+		return 50, false
+	case util.HistoryTrades:
+		return 5, false
+	case util.ExchangeInfoURL, util.AllOrdersURL, ACCOUNT_URI, util.MyTradesURL:
+		return 10, false
+	case util.Ticker24hrURL:
+		if hasSymbol {
+			return 1, false
+		}
+		return 40, false
+	case util.TickerPriceURL, util.BookTickerURL:
+		if hasSymbol {
+			return 1, false
+		}
+		return 2, false
+	case util.OpenOrdersURL:
+		if r.Method == http.MethodGet && !hasSymbol {
+			return 40, false
+		}
+		if r.Method == http.MethodGet {
+			return 3, false
+		}
+		return 1, false
+	case util.OrderURL:
+		switch r.Method {
+		case http.MethodGet:
+			return 2, false
+		case http.MethodPost:
+			return 1, true
+		}
+		return 1, false
+	}
+	return 1, false
+}
+
+func requestParam(r *mod.ReqParam, key string) string {
+	if v := r.Query.Get(key); v != "" {
+		return v
+	}
+	return r.Body.Get(key)
+}
+
+// SetRateLimiter 替换共享的频率限制器，为nil时不做限制
+func (b *Binance) SetRateLimiter(limiter *RateLimiter) *Binance {
+	b.limiter = limiter
+	return b
+}
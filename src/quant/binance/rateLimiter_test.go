@@ -0,0 +1,57 @@
+package binance_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+	"tinyquant/src/quant/binance"
+)
+
+// 使用按天的窗口，避免测试期间窗口重置
+func newDayLimiter(limit int64) *binance.RateLimiter {
+	return binance.NewRateLimiter().SetMaxWait(0).SetRateLimits([]binance.RateLimit{
+		{Interval: "DAY", IntervalNum: 1, Limit: limit, RateLimitType: "REQUEST_WEIGHT"},
+	})
+}
+
+func TestRateLimiterRejectsOverLimit(t *testing.T) {
+	rl := newDayLimiter(10)
+	ctx := context.Background()
+	if err := rl.Wait(ctx, 8, false); err != nil {
+		t.Fatal(err)
+	}
+	err := rl.Wait(ctx, 3, false)
+	if _, ok := err.(*binance.RateLimitError); !ok || !binance.IsRateLimited(err) {
+		t.Fatalf("expected *RateLimitError, got %v", err)
+	}
+	if used := rl.Used("REQUEST_WEIGHT", 24*time.Hour); used != 8 {
+		t.Fatalf("rejected request should not be counted, used %d", used)
+	}
+}
+
+func TestRateLimiterUpdateFromHeader(t *testing.T) {
+	rl := newDayLimiter(10)
+	header := http.Header{}
+	header.Set("X-MBX-USED-WEIGHT-1D", "9")
+	rl.Update(header)
+	if used := rl.Used("REQUEST_WEIGHT", 24*time.Hour); used != 9 {
+		t.Fatalf("used %d, want 9", used)
+	}
+	if err := rl.Wait(context.Background(), 2, false); err == nil {
+		t.Fatal("expected rejection after header update")
+	}
+	if err := rl.Wait(context.Background(), 1, false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimiterBlock(t *testing.T) {
+	rl := newDayLimiter(10)
+	rl.Block(time.Hour)
+	err := rl.Wait(context.Background(), 1, false)
+	rateErr, ok := err.(*binance.RateLimitError)
+	if !ok || rateErr.Wait < 59*time.Minute {
+		t.Fatalf("expected blocked error, got %v", err)
+	}
+}
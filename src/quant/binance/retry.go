@@ -0,0 +1,154 @@
+package binance
+
+import (
+	"context"
+	"net/http"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+// RequestKind 按请求类型设置重试策略
+type RequestKind string
+
+const (
+	RequestQuery RequestKind = "query" // GET 查询，可以直接重试
+	RequestOrder RequestKind = "order" // 下单，按 newClientOrderId 查询确认订单未提交后才重试
+)
+
+var defaultRetryPolicies = map[RequestKind]util.RetryPolicy{
+	RequestQuery: {MaxAttempts: 3, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second},
+	RequestOrder: {MaxAttempts: 2, BaseDelay: 200 * time.Millisecond, MaxDelay: 2 * time.Second},
+}
+
+// orderConfirmAttempts 按 clientOrderId 确认订单的最多查询次数，刚提交的订单可能短时间内查询不到
+const orderConfirmAttempts = 3
+
+// SetRetryPolicy 设置某类请求的重试策略，util.NoRetry 关闭重试
+func (b *Binance) SetRetryPolicy(kind RequestKind, policy util.RetryPolicy) *Binance {
+	if b.retryPolicies == nil {
+		b.retryPolicies = make(map[RequestKind]util.RetryPolicy)
+	}
+	b.retryPolicies[kind] = policy
+	return b
+}
+
+func (b *Binance) retryPolicy(kind RequestKind) util.RetryPolicy {
+	if policy, ok := b.retryPolicies[kind]; ok {
+		return policy
+	}
+	return defaultRetryPolicies[kind]
+}
+
+// requestKind 只有GET请求可以直接重试，其余请求不重试
+func requestKind(r *mod.ReqParam) (RequestKind, bool) {
+	if r.Method == http.MethodGet {
+		return RequestQuery, true
+	}
+	return "", false
+}
+
+/*
+	isRetryable 网络错误、5xx、-1001 与 -1007 可以重试
+	-1007 时请求可能已被处理，下单需先确认订单状态
+	频率限制由 RateLimiter 处理，不在这里重试
+*/
+func isRetryable(err error) bool {
+	if apiErr, ok := AsAPIError(err); ok {
+		return apiErr.StatusCode >= 500 || apiErr.Code == ERR_DISCONNECTED || apiErr.Code == ERR_TIMEOUT
+	}
+	return util.IsTemporary(err)
+}
+
+// withRetry 按请求类型重试 fn，非幂等请求只执行一次
+func (b *Binance) withRetry(ctx context.Context, r *mod.ReqParam, fn func() error) error {
+	kind, ok := requestKind(r)
+	if !ok {
+		return fn()
+	}
+	policy := b.retryPolicy(kind)
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= policy.MaxAttempts || !isRetryable(err) {
+			return err
+		}
+		Logger.Warn("binance request failed, retrying ", zap.String("url", r.URL), zap.Int("attempt", attempt), zap.Error(err))
+		if sleepErr := util.Sleep(ctx, policy.Backoff(attempt)); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+/*
+	placeOrder 下单，失败时按 newClientOrderId 查询订单确认是否已提交
+	订单已存在时直接返回该订单；多次查询均不存在(-2013)时才重新提交，同一 clientOrderId 的重复提交会被交易所拒绝
+	无法确认订单状态时返回原始错误，不会重复下单
+*/
+func (b *Binance) placeOrder(ctx context.Context, r *mod.ReqParam, symbol string, out *orderResponse) error {
+	clientOrderID := r.Query.Get("newClientOrderId")
+	policy := b.retryPolicy(RequestOrder)
+	for attempt := 1; ; attempt++ {
+		err := b.signedRequestOnce(ctx, r, out)
+		if err == nil {
+			return nil
+		}
+		if clientOrderID == "" || r.URL == util.TestOrderURL {
+			return err
+		}
+		duplicate := IsDuplicateOrder(err)
+		if !duplicate && (attempt >= policy.MaxAttempts || !isRetryable(err)) {
+			return err
+		}
+		// 请求可能已被处理，先查询订单
+		placed, queryErr := b.confirmOrder(ctx, symbol, clientOrderID, policy)
+		if queryErr == nil {
+			*out = *placed
+			return nil
+		}
+		if duplicate || !IsOrderNotFound(queryErr) || attempt >= policy.MaxAttempts {
+			Logger.Error("Binance Service Place Order status unknown ", zap.String("clientOrderId", clientOrderID), zap.Error(queryErr))
+			return err
+		}
+		Logger.Warn("binance order not placed, retrying ", zap.String("clientOrderId", clientOrderID), zap.Int("attempt", attempt), zap.Error(err))
+		if sleepErr := util.Sleep(ctx, policy.Backoff(attempt)); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+/*
+	confirmOrder 按 clientOrderId 查询订单，最多查询 orderConfirmAttempts 次
+	超时的订单可能尚未对查询可见，-2013 与可重试的错误都会再次查询
+*/
+func (b *Binance) confirmOrder(ctx context.Context, symbol, clientOrderID string, policy util.RetryPolicy) (*orderResponse, error) {
+	for attempt := 1; ; attempt++ {
+		placed, err := b.queryOrderByClientID(ctx, symbol, clientOrderID)
+		if err == nil {
+			return placed, nil
+		}
+		if attempt >= orderConfirmAttempts || !(IsOrderNotFound(err) || isRetryable(err)) {
+			return nil, err
+		}
+		if sleepErr := util.Sleep(ctx, policy.Backoff(attempt)); sleepErr != nil {
+			return nil, err
+		}
+	}
+}
+
+// queryOrderByClientID 按 clientOrderId 查询订单，只请求一次
+func (b *Binance) queryOrderByClientID(ctx context.Context, symbol, clientOrderID string) (*orderResponse, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.OrderURL,
+	}
+	r.SetParam(util.SymbolKey, symbol)
+	r.SetParam("origClientOrderId", clientOrderID)
+	resp := new(orderResponse)
+	if err := b.signedRequestOnce(ctx, r, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
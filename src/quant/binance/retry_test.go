@@ -0,0 +1,117 @@
+package binance_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+	"tinyquant/src/util"
+)
+
+var fastRetry = util.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond}
+
+func newTestBinance(srv *httptest.Server) *binance.Binance {
+	return binance.NewBinance("ak", "sk").SetHttpClient(srv.Client()).SetBaseURL(srv.URL).SetTestMode(false).
+		SetRateLimiter(nil).SetRetryPolicy(binance.RequestQuery, fastRetry).SetRetryPolicy(binance.RequestOrder, fastRetry)
+}
+
+func TestGetRetriedOnServerError(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"symbol":"BTCUSDT","price":"1.5"}`))
+	}))
+	defer srv.Close()
+	if _, err := newTestBinance(srv).GetPriceTicker(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("calls %d, want 2", calls)
+	}
+}
+
+/*
+	orderServer 第一次下单返回 code 对应的错误
+	订单被撮合引擎接收后，前 hidden 次查询仍返回 -2013；hidden 小于0时订单未被接收
+*/
+func orderServer(status, code, hidden int) (*httptest.Server, *[]string, *int) {
+	var mu sync.Mutex
+	var posted []string
+	queries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		q := r.URL.Query()
+		switch r.Method {
+		case http.MethodPost:
+			posted = append(posted, q.Get("newClientOrderId"))
+			if len(posted) == 1 {
+				w.WriteHeader(status)
+				fmt.Fprintf(w, `{"code":%d,"msg":"Execution status unknown."}`, code)
+				return
+			}
+			w.Write([]byte(`{"symbol":"BTCUSDT","orderId":2,"clientOrderId":"` + q.Get("newClientOrderId") + `"}`))
+		case http.MethodGet:
+			queries++
+			if hidden < 0 || queries <= hidden {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":-2013,"msg":"Order does not exist."}`))
+				return
+			}
+			w.Write([]byte(`{"symbol":"BTCUSDT","orderId":1,"clientOrderId":"` + q.Get("origClientOrderId") + `","status":"NEW","side":"BUY","type":"LIMIT","price":"100","origQty":"1"}`))
+		}
+	}))
+	return srv, &posted, &queries
+}
+
+func TestPlaceOrderNotResubmittedWhenPlaced(t *testing.T) {
+	srv, posted, _ := orderServer(http.StatusServiceUnavailable, -1006, 0)
+	defer srv.Close()
+	order, err := newTestBinance(srv).PlaceOrder(context.Background(), &quant.OrderRequest{
+		Pair: quant.BTC_USDT, Side: binance.BUY, Price: dec("100"), Amount: dec("1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*posted) != 1 || order.OrderID2 != "1" {
+		t.Fatalf("posted %v, order %+v", *posted, order)
+	}
+}
+
+// -1007 时订单可能已提交，查询到 -2013 视为尚未可见，继续查询而不是重新下单
+func TestPlaceOrderTimeoutConfirmedAfterNotFound(t *testing.T) {
+	srv, posted, queries := orderServer(http.StatusRequestTimeout, binance.ERR_TIMEOUT, 2)
+	defer srv.Close()
+	order, err := newTestBinance(srv).PlaceOrder(context.Background(), &quant.OrderRequest{
+		Pair: quant.BTC_USDT, Side: binance.BUY, Price: dec("100"), Amount: dec("1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*posted) != 1 || *queries != 3 || order.OrderID2 != "1" {
+		t.Fatalf("posted %v, queries %d, order %+v", *posted, *queries, order)
+	}
+}
+
+func TestPlaceOrderResubmittedWhenNotFound(t *testing.T) {
+	srv, posted, queries := orderServer(http.StatusRequestTimeout, binance.ERR_TIMEOUT, -1)
+	defer srv.Close()
+	order, err := newTestBinance(srv).PlaceOrder(context.Background(), &quant.OrderRequest{
+		Pair: quant.BTC_USDT, Side: binance.BUY, Price: dec("100"), Amount: dec("1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *queries != 3 || len(*posted) != 2 || (*posted)[0] == "" || (*posted)[0] != (*posted)[1] || order.OrderID2 != "2" {
+		t.Fatalf("posted %v, queries %d, order %+v", *posted, *queries, order)
+	}
+}
@@ -0,0 +1,198 @@
+package binance
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+/*
+	获取交易规则和交易对信息
+*/
+func (b *Binance) GetExchangeInfo(ctx context.Context) (*ExchangeInfo, error) {
+	r := &mod.ReqParam{
+		Method: "GET",
+		URL:    util.ExchangeInfoURL,
+	}
+	info := new(ExchangeInfo)
+	err := b.request(ctx, r, info)
+	if err != nil {
+		Logger.Error("Binance Service Get Exchange Info Failed", zap.Error(err))
+		return nil, err
+	}
+	if b.limiter != nil && len(info.RateLimits) > 0 {
+		b.limiter.SetRateLimits(info.RateLimits)
+	}
+	return info, nil
+}
+
+const defaultRegistryRefreshInterval = 10 * time.Minute
+
+// SymbolRegistry 缓存交易对规则，并定时从 exchangeInfo 刷新
+type SymbolRegistry struct {
+	b        *Binance
+	interval time.Duration
+
+	mu         sync.RWMutex
+	symbols    map[string]*TradeSymbol
+	rateLimits []RateLimit
+	updateTime time.Time
+
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+func NewSymbolRegistry(b *Binance, interval time.Duration) *SymbolRegistry {
+	if interval <= 0 {
+		interval = defaultRegistryRefreshInterval
+	}
+	return &SymbolRegistry{
+		b:        b,
+		interval: interval,
+		symbols:  make(map[string]*TradeSymbol),
+		closeCh:  make(chan struct{}),
+	}
+}
+
+/*
+	Start 加载一次交易规则，然后在后台定时刷新直至调用 Stop
+	ctx 只用于首次加载，后台刷新的请求在 Stop 时取消
+*/
+func (sr *SymbolRegistry) Start(ctx context.Context) error {
+	if err := sr.Refresh(ctx); err != nil {
+		return err
+	}
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-sr.closeCh
+			cancel()
+		}()
+		ticker := time.NewTicker(sr.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sr.closeCh:
+				Logger.Info("symbol registry refresh stopped")
+				return
+			case <-ticker.C:
+				if err := sr.Refresh(ctx); err != nil {
+					Logger.Error("refresh symbol registry failed ", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止后台刷新
+func (sr *SymbolRegistry) Stop() {
+	sr.closeOnce.Do(func() {
+		close(sr.closeCh)
+	})
+}
+
+// Refresh 立即从交易所拉取最新的交易规则
+func (sr *SymbolRegistry) Refresh(ctx context.Context) error {
+	info, err := sr.b.GetExchangeInfo(ctx)
+	if err != nil {
+		return err
+	}
+	sr.Load(info)
+	return nil
+}
+
+// Load 使用给定的 exchangeInfo 替换缓存
+func (sr *SymbolRegistry) Load(info *ExchangeInfo) {
+	symbols := make(map[string]*TradeSymbol, len(info.Symbols))
+	for i := range info.Symbols {
+		symbols[info.Symbols[i].Symbol] = &info.Symbols[i]
+	}
+	sr.mu.Lock()
+	sr.symbols = symbols
+	sr.rateLimits = info.RateLimits
+	sr.updateTime = time.Now()
+	sr.mu.Unlock()
+}
+
+// Symbol 查找交易对，symbol 不区分大小写
+func (sr *SymbolRegistry) Symbol(symbol string) (*TradeSymbol, bool) {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	ts, ok := sr.symbols[strings.ToUpper(symbol)]
+	return ts, ok
+}
+
+// Symbols 返回所有交易对
+func (sr *SymbolRegistry) Symbols() []*TradeSymbol {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	symbols := make([]*TradeSymbol, 0, len(sr.symbols))
+	for _, ts := range sr.symbols {
+		symbols = append(symbols, ts)
+	}
+	return symbols
+}
+
+// CurrencyPair 按原生名称查找通用交易对
+func (sr *SymbolRegistry) CurrencyPair(symbol string) (quant.CurrencyPair, bool) {
+	ts, ok := sr.Symbol(symbol)
+	if !ok {
+		return quant.CurrencyPair{}, false
+	}
+	return ts.CurrencyPair(), true
+}
+
+// CurrencyPairs 返回所有交易对对应的通用交易对
+func (sr *SymbolRegistry) CurrencyPairs() []quant.CurrencyPair {
+	symbols := sr.Symbols()
+	pairs := make([]quant.CurrencyPair, 0, len(symbols))
+	for _, ts := range symbols {
+		pairs = append(pairs, ts.CurrencyPair())
+	}
+	return pairs
+}
+
+// RateLimits 返回交易所的频率限制
+func (sr *SymbolRegistry) RateLimits() []RateLimit {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	return sr.rateLimits
+}
+
+// UpdateTime 最近一次刷新的时间
+func (sr *SymbolRegistry) UpdateTime() time.Time {
+	sr.mu.RLock()
+	defer sr.mu.RUnlock()
+	return sr.updateTime
+}
+
+// Filter 查找交易对的某类交易规则
+func (sr *SymbolRegistry) Filter(symbol, filterType string) (*Filter, bool) {
+	ts, ok := sr.Symbol(symbol)
+	if !ok {
+		return nil, false
+	}
+	filter := ts.Filter(filterType)
+	return filter, filter != nil
+}
+
+// IsTrading 交易对是否存在且处于可交易状态
+func (sr *SymbolRegistry) IsTrading(symbol string) bool {
+	ts, ok := sr.Symbol(symbol)
+	return ok && ts.IsTrading()
+}
+
+// SupportsOrderType 交易对是否支持该订单类型
+func (sr *SymbolRegistry) SupportsOrderType(symbol, orderType string) bool {
+	ts, ok := sr.Symbol(symbol)
+	return ok && ts.SupportsOrderType(orderType)
+}
@@ -0,0 +1,128 @@
+package binance_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+)
+
+const registrySymbol = `{"symbol":"%s","status":"%s","baseAsset":"%s","quoteAsset":"USDT","orderTypes":["LIMIT","MARKET"],
+	"filters":[{"filterType":"PRICE_FILTER","minPrice":"0.01","maxPrice":"1000000","tickSize":"0.01"},
+	{"filterType":"LOT_SIZE","minQty":"0.0001","maxQty":"9000","stepSize":"0.0001"}]}`
+
+// newExchangeInfoServer 第一次返回 BTCUSDT，之后 BTCUSDT 暂停交易并新增 ETHUSDT
+func newExchangeInfoServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/exchangeInfo" {
+			http.NotFound(w, r)
+			return
+		}
+		symbols := fmt.Sprintf(registrySymbol, "BTCUSDT", "TRADING", "BTC")
+		if atomic.AddInt32(calls, 1) > 1 {
+			symbols = fmt.Sprintf(registrySymbol, "BTCUSDT", "BREAK", "BTC") + "," + fmt.Sprintf(registrySymbol, "ETHUSDT", "TRADING", "ETH")
+		}
+		fmt.Fprintf(w, `{"timezone":"UTC","serverTime":1600000000000,
+			"rateLimits":[{"rateLimitType":"REQUEST_WEIGHT","interval":"MINUTE","intervalNum":1,"limit":1200}],
+			"symbols":[%s]}`, symbols)
+	}))
+}
+
+func TestSymbolRegistryRefresh(t *testing.T) {
+	var calls int32
+	srv := newExchangeInfoServer(&calls)
+	defer srv.Close()
+	registry := binance.NewSymbolRegistry(newTestBinance(srv), 20*time.Millisecond)
+	if err := registry.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Stop()
+
+	// Start 返回前已加载一次
+	ts, ok := registry.Symbol("btcusdt")
+	if !ok || !registry.IsTrading("BTCUSDT") || !registry.SupportsOrderType("BTCUSDT", "MARKET") || registry.SupportsOrderType("BTCUSDT", "LIMIT_MAKER") {
+		t.Fatalf("unexpected symbol %+v", ts)
+	}
+	if pair, ok := registry.CurrencyPair("BTCUSDT"); !ok || !pair.Equal(quant.BTC_USDT) || pair.PricePrecision != 2 || pair.AmountPrecision != 4 {
+		t.Fatalf("unexpected pair %+v", pair)
+	}
+	if f, ok := registry.Filter("BTCUSDT", binance.FILTER_LOT_SIZE); !ok || f.StepSize.String() != "0.0001" {
+		t.Fatalf("unexpected LOT_SIZE filter %+v", f)
+	}
+	if _, ok := registry.Filter("BTCUSDT", binance.FILTER_MIN_NOTIONAL); ok {
+		t.Fatal("missing filter reported as present")
+	}
+	if _, ok := registry.Filter("ETHUSDT", binance.FILTER_LOT_SIZE); ok {
+		t.Fatal("unknown symbol reported as present")
+	}
+	if limits := registry.RateLimits(); len(limits) != 1 || limits[0].Limit != 1200 {
+		t.Fatalf("unexpected rate limits %+v", limits)
+	}
+	loaded := registry.UpdateTime()
+
+	// 后台定时刷新
+	deadline := time.Now().Add(2 * time.Second)
+	for !registry.IsTrading("ETHUSDT") {
+		if time.Now().After(deadline) {
+			t.Fatal("registry not refreshed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if registry.IsTrading("BTCUSDT") || len(registry.Symbols()) != 2 || !registry.UpdateTime().After(loaded) {
+		t.Fatalf("stale registry %+v", registry.Symbols())
+	}
+
+	// Stop 之后不再请求
+	registry.Stop()
+	time.Sleep(30 * time.Millisecond)
+	stopped := atomic.LoadInt32(&calls)
+	time.Sleep(100 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != stopped {
+		t.Fatalf("refreshed %d times after Stop", n-stopped)
+	}
+}
+
+// Start 的 ctx 只用于首次加载，取消后仍继续刷新
+func TestSymbolRegistryRefreshOutlivesStartContext(t *testing.T) {
+	var calls int32
+	srv := newExchangeInfoServer(&calls)
+	defer srv.Close()
+	registry := binance.NewSymbolRegistry(newTestBinance(srv), 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := registry.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer registry.Stop()
+	cancel()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !registry.IsTrading("ETHUSDT") {
+		if time.Now().After(deadline) {
+			t.Fatal("registry stopped refreshing when the start context was canceled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSymbolRegistryStartFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+	}))
+	defer srv.Close()
+	registry := binance.NewSymbolRegistry(newTestBinance(srv), time.Millisecond)
+	if err := registry.Start(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if err := registry.Refresh(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(registry.Symbols()) != 0 {
+		t.Fatalf("unexpected symbols %+v", registry.Symbols())
+	}
+}
@@ -0,0 +1,100 @@
+package binance
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+	. "tinyquant/src/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultRecvWindow       = 60000 // ms
+	defaultTimeSyncInterval = time.Minute
+	timeSyncSamples         = 3
+)
+
+// timeSyncer 后台定时校准服务器时间
+type timeSyncer struct {
+	closeCh   chan struct{}
+	closeOnce sync.Once
+}
+
+// ServerTimestamp 按校准后的时间差估算当前服务器时间，单位:ms
+func (b *Binance) ServerTimestamp() int64 {
+	offset := atomic.LoadInt64(&b.timeOffset)
+	return (time.Now().UnixNano() + offset) / int64(time.Millisecond)
+}
+
+// TimeOffset 当前使用的服务器与本地时间差
+func (b *Binance) TimeOffset() time.Duration {
+	return time.Duration(atomic.LoadInt64(&b.timeOffset))
+}
+
+// SyncTime 多次采样服务器时间，取往返延迟最小的一次作为时间差
+func (b *Binance) SyncTime(ctx context.Context) error {
+	var (
+		bestOffset int64
+		bestRTT    time.Duration = -1
+		lastErr    error
+	)
+	for i := 0; i < timeSyncSamples; i++ {
+		offset, rtt, err := b.sampleTimeOffset(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if bestRTT < 0 || rtt < bestRTT {
+			bestOffset, bestRTT = offset, rtt
+		}
+	}
+	if bestRTT < 0 {
+		return lastErr
+	}
+	atomic.StoreInt64(&b.timeOffset, bestOffset)
+	Logger.Debug("binance server time synced ", zap.Duration("offset", time.Duration(bestOffset)), zap.Duration("rtt", bestRTT))
+	return nil
+}
+
+// StartTimeSync 立即校准一次服务器时间，之后每隔 interval 在后台重新校准
+func (b *Binance) StartTimeSync(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultTimeSyncInterval
+	}
+	if err := b.SyncTime(ctx); err != nil {
+		return err
+	}
+	b.StopTimeSync()
+	ts := &timeSyncer{closeCh: make(chan struct{})}
+	b.timeSync = ts
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ts.closeCh:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := b.SyncTime(ctx); err != nil {
+					Logger.Error("binance server time sync failed ", zap.Error(err))
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// StopTimeSync 停止后台时间校准，已校准的时间差继续生效
+func (b *Binance) StopTimeSync() {
+	if b.timeSync == nil {
+		return
+	}
+	b.timeSync.closeOnce.Do(func() {
+		close(b.timeSync.closeCh)
+	})
+	b.timeSync = nil
+}
@@ -0,0 +1,110 @@
+package binance_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/binance"
+)
+
+// writeServerTime 延迟 delay 后返回本地时间加 offset 作为服务器时间
+func writeServerTime(w http.ResponseWriter, delay, offset time.Duration) {
+	time.Sleep(delay)
+	serverTime := time.Now().Add(offset).UnixNano() / int64(time.Millisecond)
+	fmt.Fprintf(w, `{"serverTime":%d}`, serverTime)
+}
+
+func validSignature(query url.Values, secretKey string) bool {
+	query, _ = url.ParseQuery(query.Encode())
+	signature := query.Get("signature")
+	query.Del("signature")
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(query.Encode()))
+	return signature == hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSyncTimeUsesMinRTTSample(t *testing.T) {
+	// 往返延迟最小的第二次采样最准确
+	samples := []struct{ delay, offset time.Duration }{
+		{80 * time.Millisecond, 10 * time.Second},
+		{0, 5 * time.Second},
+		{40 * time.Millisecond, 20 * time.Second},
+	}
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		s := samples[calls%len(samples)]
+		calls++
+		mu.Unlock()
+		writeServerTime(w, s.delay, s.offset)
+	}))
+	defer srv.Close()
+	b := newTestBinance(srv)
+	if err := b.SyncTime(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(samples) {
+		t.Fatalf("sampled %d times, want %d", calls, len(samples))
+	}
+	if d := b.TimeOffset() - 5*time.Second; d < -20*time.Millisecond || d > 20*time.Millisecond {
+		t.Fatalf("offset %s not taken from the min-RTT sample", b.TimeOffset())
+	}
+	if d := b.ServerTimestamp() - time.Now().Add(5*time.Second).UnixNano()/int64(time.Millisecond); d < -20 || d > 20 {
+		t.Fatalf("server timestamp off by %dms", d)
+	}
+}
+
+func TestSignedRequestResyncsOnTimestampError(t *testing.T) {
+	const serverOffset = 30 * time.Second
+	for _, persistent := range []bool{false, true} {
+		var signed []url.Values
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/api/v3/time" {
+				writeServerTime(w, 0, serverOffset)
+				return
+			}
+			signed = append(signed, r.URL.Query())
+			if len(signed) == 1 || persistent {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":-1021,"msg":"Timestamp for this request is outside of the recvWindow."}`))
+				return
+			}
+			w.Write([]byte(`[]`))
+		}))
+		b := newTestBinance(srv)
+		_, err := b.GetOpenOrders(context.Background(), quant.BTC_USDT)
+		srv.Close()
+
+		if persistent && !binance.IsTimestampOutOfWindow(err) {
+			t.Fatalf("expected -1021 after a single retry, got %v", err)
+		}
+		if !persistent && err != nil {
+			t.Fatal(err)
+		}
+		if len(signed) != 2 {
+			t.Fatalf("persistent=%v: signed requests %d, want 2", persistent, len(signed))
+		}
+		first, _ := strconv.ParseInt(signed[0].Get("timestamp"), 10, 64)
+		second, _ := strconv.ParseInt(signed[1].Get("timestamp"), 10, 64)
+		// 重试使用校准后的服务器时间重新签名
+		if second-first < int64((serverOffset-time.Second)/time.Millisecond) {
+			t.Fatalf("retry not re-stamped with server time: %d -> %d", first, second)
+		}
+		for i, query := range signed {
+			if !validSignature(query, "sk") {
+				t.Fatalf("request %d has an invalid signature %v", i, query)
+			}
+		}
+	}
+}
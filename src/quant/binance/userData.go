@@ -0,0 +1,367 @@
+package binance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/mod"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// listenKey 有效期60分钟，每30分钟延长一次，失败时每分钟重试直至成功或失效
+const (
+	listenKeyKeepAliveInterval = 30 * time.Minute
+	listenKeyRetryInterval     = time.Minute
+)
+
+/////////////////////////////*********listenKey**********//////////////////////////////////////
+
+/*
+	生成 listenKey，只需要 apikey 不需要签名
+*/
+func (b *Binance) CreateListenKey(ctx context.Context) (string, error) {
+	r := &mod.ReqParam{
+		Method: "POST",
+		URL:    util.UserDataURL,
+		APIKEY: b.accessKey,
+	}
+	resp := struct {
+		ListenKey string `json:"listenKey"`
+	}{}
+	err := b.request(ctx, r, &resp)
+	if err != nil {
+		Logger.Error("Binance Service Create Listen Key Failed", zap.Error(err))
+		return "", err
+	}
+	return resp.ListenKey, nil
+}
+
+/*
+	延长 listenKey 有效期至60分钟后
+*/
+func (b *Binance) KeepAliveListenKey(ctx context.Context, listenKey string) error {
+	r := &mod.ReqParam{
+		Method: "PUT",
+		URL:    util.UserDataURL,
+		APIKEY: b.accessKey,
+	}
+	r.SetParam("listenKey", listenKey)
+	err := b.request(ctx, r, nil)
+	if err != nil {
+		Logger.Error("Binance Service Keep Alive Listen Key Failed", zap.Error(err))
+	}
+	return err
+}
+
+/*
+	关闭 listenKey
+*/
+func (b *Binance) CloseListenKey(ctx context.Context, listenKey string) error {
+	r := &mod.ReqParam{
+		Method: "DELETE",
+		URL:    util.UserDataURL,
+		APIKEY: b.accessKey,
+	}
+	r.SetParam("listenKey", listenKey)
+	err := b.request(ctx, r, nil)
+	if err != nil {
+		Logger.Error("Binance Service Close Listen Key Failed", zap.Error(err))
+	}
+	return err
+}
+
+/////////////////////////////*********账户推送**********//////////////////////////////////////
+
+// ExecutionReport 订单更新推送，字段需全部声明，否则大小写不同的字段会被错误匹配
+type ExecutionReport struct {
+	EventType           string          `json:"e"`
+	EventTime           int64           `json:"E"`
+	Symbol              string          `json:"s"`
+	ClientOrderID       string          `json:"c"`
+	Side                string          `json:"S"`
+	OrderType           string          `json:"o"`
+	TimeInForce         string          `json:"f"`
+	Quantity            decimal.Decimal `json:"q"`
+	Price               decimal.Decimal `json:"p"`
+	StopPrice           decimal.Decimal `json:"P"`
+	IcebergQty          decimal.Decimal `json:"F"`
+	OrderListID         int64           `json:"g"`
+	OrigClientOrderID   string          `json:"C"` // 撤单时为原始订单的 clientOrderId
+	ExecutionType       string          `json:"x"` // NEW / CANCELED / REJECTED / TRADE / EXPIRED
+	Status              string          `json:"X"` // 订单当前状态
+	RejectReason        string          `json:"r"` // 拒绝原因
+	OrderID             int             `json:"i"` // 订单号
+	LastExecutedQty     decimal.Decimal `json:"l"`
+	CumulativeFilledQty decimal.Decimal `json:"z"`
+	LastExecutedPrice   decimal.Decimal `json:"L"`
+	Commission          decimal.Decimal `json:"n"` // 本次成交的手续费
+	CommissionAsset     string          `json:"N"`
+	TransactionTime     int64           `json:"T"`
+	TradeID             int64           `json:"t"`
+	Ignore              int64           `json:"I"`
+	IsWorking           bool            `json:"w"` // 订单是否在订单簿上
+	IsMaker             bool            `json:"m"` // 本次成交是否为挂单方
+	IgnoreM             bool            `json:"M"`
+	CreateTime          int64           `json:"O"`
+	CumulativeQuoteQty  decimal.Decimal `json:"Z"`
+	LastQuoteQty        decimal.Decimal `json:"Y"`
+	QuoteOrderQty       decimal.Decimal `json:"Q"`
+}
+
+/*
+	ToOrder 转换为 Order
+	撤单推送的 c 为撤单请求的 clientOrderId，ClientOrderID 优先取原始订单的 C
+	Fee 与 Fills 仅为本次推送的成交，累计值需调用方自行汇总
+*/
+func (e *ExecutionReport) ToOrder() *Order {
+	avgPrice := decimal.Zero
+	if e.CumulativeFilledQty.IsPositive() && e.CumulativeQuoteQty.IsPositive() {
+		avgPrice = e.CumulativeQuoteQty.Div(e.CumulativeFilledQty)
+	}
+	side := BUY
+	if e.Side == "SELL" {
+		side = SELL
+	}
+	clientOrderID := e.OrigClientOrderID
+	if clientOrderID == "" {
+		clientOrderID = e.ClientOrderID
+	}
+	var fills []quant.Fill
+	if e.ExecutionType == "TRADE" {
+		fills = []quant.Fill{{
+			TradeID:         e.TradeID,
+			Price:           e.LastExecutedPrice,
+			Amount:          e.LastExecutedQty,
+			Commission:      e.Commission,
+			CommissionAsset: e.CommissionAsset,
+		}}
+	}
+	pair, _ := quant.ParseCurrencyPair(e.Symbol)
+	return &Order{
+		Pair:          pair,
+		Symbol:        e.Symbol,
+		OrderID:       e.OrderID,
+		OrderID2:      strconv.Itoa(e.OrderID),
+		Side:          side,
+		AvgPrice:      avgPrice,
+		Type:          e.OrderType,
+		Fee:           e.Commission,
+		Price:         e.Price,
+		DealAmount:    e.CumulativeFilledQty,
+		Amount:        e.Quantity,
+		Status:        toTradeStatus(e.Status),
+		OrderTime:     int(e.CreateTime),
+		ClientOrderID: clientOrderID,
+		Fills:         fills,
+	}
+}
+
+// AccountPosition 账户余额变化推送 outboundAccountPosition
+type AccountPosition struct {
+	EventType      string `json:"e"`
+	EventTime      int64  `json:"E"`
+	LastUpdateTime int64  `json:"u"`
+	Balances       []struct {
+		Asset  string          `json:"a"`
+		Free   decimal.Decimal `json:"f"`
+		Locked decimal.Decimal `json:"l"`
+	} `json:"B"`
+}
+
+// BalanceUpdate 充值、提现或划转导致的余额变化推送
+type BalanceUpdate struct {
+	EventType string          `json:"e"`
+	EventTime int64           `json:"E"`
+	Asset     string          `json:"a"`
+	Delta     decimal.Decimal `json:"d"`
+	ClearTime int64           `json:"T"`
+}
+
+func (bw *BinanceWs) SetExecutionReportCallback(callback func(*ExecutionReport)) {
+	bw.executionReportCallback = callback
+}
+
+func (bw *BinanceWs) SetAccountPositionCallback(callback func(*AccountPosition)) {
+	bw.accountPositionCallback = callback
+}
+
+func (bw *BinanceWs) SetBalanceUpdateCallback(callback func(*BalanceUpdate)) {
+	bw.balanceUpdateCallback = callback
+}
+
+// SetListenKeyKeepAlive 设置 listenKey 的延长间隔与延长失败后的重试间隔，需在 SubscribeUserData 前调用
+func (bw *BinanceWs) SetListenKeyKeepAlive(interval, retryInterval time.Duration) *BinanceWs {
+	bw.listenKeyKeepAlive, bw.listenKeyRetry = interval, retryInterval
+	return bw
+}
+
+// userDataStream 维护 listenKey 与对应的推送连接
+type userDataStream struct {
+	bw   *BinanceWs
+	rest *Binance
+
+	mu        sync.Mutex
+	listenKey string
+	conn      *util.WsConn
+	renewCh   chan struct{}
+}
+
+/*
+	SubscribeUserData 订阅账户推送
+	listenKey 每30分钟自动延长，延长失败时尽快重试，失效后自动重新生成并重连
+*/
+func (bw *BinanceWs) SubscribeUserData(rest *Binance) error {
+	if bw.executionReportCallback == nil && bw.accountPositionCallback == nil && bw.balanceUpdateCallback == nil {
+		return ErrCallbackNotSet
+	}
+	uds := &userDataStream{
+		bw:      bw,
+		rest:    rest,
+		renewCh: make(chan struct{}, 1),
+	}
+	if err := uds.connect(context.Background()); err != nil {
+		return err
+	}
+	go uds.keepAlive()
+	return nil
+}
+
+// connect 生成新的 listenKey 并建立推送连接
+func (uds *userDataStream) connect(ctx context.Context) error {
+	listenKey, err := uds.rest.CreateListenKey(ctx)
+	if err != nil {
+		return err
+	}
+	endpoint := strings.TrimSuffix(strings.TrimSuffix(uds.bw.baseURL, "/"), "/ws") + "/ws/" + listenKey
+	conn := util.NewWsConn(endpoint, uds.bw.proxyUrl, uds.handle)
+	if err := conn.NewWebsocket(); err != nil {
+		Logger.Error("[ws] subscribe user data failed ", zap.Error(err))
+		return err
+	}
+
+	uds.mu.Lock()
+	old := uds.conn
+	uds.listenKey, uds.conn = listenKey, conn
+	uds.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+func (uds *userDataStream) renew() {
+	select {
+	case uds.renewCh <- struct{}{}:
+	default:
+	}
+}
+
+func (uds *userDataStream) keepAlive() {
+	timer := time.NewTimer(uds.bw.listenKeyKeepAlive)
+	defer timer.Stop()
+	ctx := context.Background()
+	for {
+		select {
+		case <-uds.bw.closeCh:
+			uds.close(ctx)
+			return
+		case <-timer.C:
+			uds.mu.Lock()
+			listenKey := uds.listenKey
+			uds.mu.Unlock()
+			err := uds.rest.KeepAliveListenKey(ctx, listenKey)
+			if err == nil {
+				timer.Reset(uds.bw.listenKeyKeepAlive)
+				continue
+			}
+			if apiErr, ok := AsAPIError(err); !ok || apiErr.Code != ERR_INVALID_LISTEN_KEY {
+				// 等到下一个周期时 listenKey 可能已过期，尽快重试
+				timer.Reset(uds.bw.listenKeyRetry)
+				continue
+			}
+		case <-uds.renewCh:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+		Logger.Warn("[ws] listenKey expired, recreating")
+		for !uds.bw.isClosed() {
+			if err := uds.connect(ctx); err == nil {
+				break
+			}
+			time.Sleep(uds.bw.listenKeyRetry)
+		}
+		timer.Reset(uds.bw.listenKeyKeepAlive)
+	}
+}
+
+func (uds *userDataStream) close(ctx context.Context) {
+	uds.mu.Lock()
+	defer uds.mu.Unlock()
+	if uds.conn != nil {
+		uds.conn.Close()
+	}
+	if uds.listenKey != "" {
+		uds.rest.CloseListenKey(ctx, uds.listenKey)
+	}
+}
+
+func (uds *userDataStream) handle(msg []byte) error {
+	// 需同时声明 E，否则事件时间会按大小写不敏感匹配到 e
+	event := struct {
+		EventType string `json:"e"`
+		EventTime int64  `json:"E"`
+	}{}
+	if err := json.Unmarshal(msg, &event); err != nil {
+		Logger.Error("json unmarshal error for ", zap.ByteString("msg", msg), zap.Error(err))
+		return err
+	}
+	bw := uds.bw
+	switch event.EventType {
+	case "executionReport":
+		if bw.executionReportCallback == nil {
+			return nil
+		}
+		report := new(ExecutionReport)
+		if err := json.Unmarshal(msg, report); err != nil {
+			return err
+		}
+		bw.executionReportCallback(report)
+	case "outboundAccountPosition":
+		if bw.accountPositionCallback == nil {
+			return nil
+		}
+		position := new(AccountPosition)
+		if err := json.Unmarshal(msg, position); err != nil {
+			return err
+		}
+		bw.accountPositionCallback(position)
+	case "balanceUpdate":
+		if bw.balanceUpdateCallback == nil {
+			return nil
+		}
+		update := new(BalanceUpdate)
+		if err := json.Unmarshal(msg, update); err != nil {
+			return err
+		}
+		bw.balanceUpdateCallback(update)
+	case "listenKeyExpired":
+		uds.renew()
+	case "outboundAccountInfo", "listStatus":
+	default:
+		return errors.New("unknown message " + event.EventType)
+	}
+	return nil
+}
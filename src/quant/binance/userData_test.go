@@ -0,0 +1,243 @@
+package binance_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tinyquant/src/quant/binance"
+	"tinyquant/src/util"
+
+	"github.com/gorilla/websocket"
+)
+
+const executionReport = `{"e":"executionReport","E":1499405658658,"s":"ETHBTC","c":"mUvoqJxFIILMdfAW5iGSOW","S":"BUY","o":"LIMIT","f":"GTC",
+"q":"1.00000000","p":"0.10264410","P":"0.00000000","F":"0.00000000","g":-1,"C":"","x":"TRADE","X":"PARTIALLY_FILLED","r":"NONE",
+"i":4293153,"l":"0.40000000","z":"0.50000000","L":"0.10264410","n":"0.00010000","N":"BNB","T":1499405658657,"t":123,"I":8641984,
+"w":true,"m":false,"M":true,"O":1499405658657,"Z":"0.05132205","Y":"0.04105764","Q":"0.00000000"}`
+
+func TestExecutionReportToOrder(t *testing.T) {
+	report := new(binance.ExecutionReport)
+	if err := json.Unmarshal([]byte(executionReport), report); err != nil {
+		t.Fatal(err)
+	}
+	if report.OrderID != 4293153 || report.IsMaker || report.CommissionAsset != "BNB" {
+		t.Fatalf("fields mismatched by case-insensitive keys: %+v", report)
+	}
+	order := report.ToOrder()
+	if order.Status != binance.ORDER_PARTIALLY_FILLED || order.Side != binance.BUY ||
+		order.DealAmount.String() != "0.5" || order.AvgPrice.String() != "0.1026441" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+	if order.ClientOrderID != "mUvoqJxFIILMdfAW5iGSOW" || order.Fee.String() != "0.0001" || len(order.Fills) != 1 {
+		t.Fatalf("unexpected client order id or fee %+v", order)
+	}
+	fill := order.Fills[0]
+	if fill.TradeID != 123 || fill.Price.String() != "0.1026441" || fill.Amount.String() != "0.4" ||
+		fill.Commission.String() != "0.0001" || fill.CommissionAsset != "BNB" {
+		t.Fatalf("unexpected fill %+v", fill)
+	}
+
+	// 撤单推送的 c 为撤单请求的 clientOrderId，应取原始订单的 C
+	canceled := new(binance.ExecutionReport)
+	msg := strings.NewReplacer(`"c":"mUvoqJxFIILMdfAW5iGSOW"`, `"c":"cancelReq1"`, `"C":""`, `"C":"mUvoqJxFIILMdfAW5iGSOW"`,
+		`"x":"TRADE"`, `"x":"CANCELED"`, `"X":"PARTIALLY_FILLED"`, `"X":"CANCELED"`, `"n":"0.00010000"`, `"n":"0"`).Replace(executionReport)
+	if err := json.Unmarshal([]byte(msg), canceled); err != nil {
+		t.Fatal(err)
+	}
+	order = canceled.ToOrder()
+	if order.ClientOrderID != "mUvoqJxFIILMdfAW5iGSOW" || order.Status != binance.ORDER_CANCELED ||
+		!order.Fee.IsZero() || len(order.Fills) != 0 {
+		t.Fatalf("unexpected canceled order %+v", order)
+	}
+}
+
+/*
+	newUserDataServer 模拟 listenKey 接口与账户推送连接
+	每次生成的 listenKey 依次为 k1、k2...，请求与连接按 "POST k1" / "PUT k1" / "DELETE k1" / "WS k1" 记录到 calls
+	keepAlive 处理第 n 次延长请求，为 nil 时总是成功
+*/
+func newUserDataServer(keepAlive func(n int, w http.ResponseWriter)) (*httptest.Server, chan string, chan *websocket.Conn) {
+	calls := make(chan string, 32)
+	conns := make(chan *websocket.Conn, 4)
+	upgrader := websocket.Upgrader{}
+	var mu sync.Mutex
+	keys, puts := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/ws/") {
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer c.Close()
+			calls <- "WS " + strings.TrimPrefix(r.URL.Path, "/ws/")
+			conns <- c
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}
+		if r.URL.Path != util.UserDataURL || r.Header.Get("X-MBX-APIKEY") != "ak" {
+			http.NotFound(w, r)
+			return
+		}
+		listenKey := r.URL.Query().Get("listenKey")
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case "POST":
+			keys++
+			listenKey = fmt.Sprintf("k%d", keys)
+			fmt.Fprintf(w, `{"listenKey":"%s"}`, listenKey)
+		case "PUT":
+			puts++
+			if keepAlive != nil {
+				keepAlive(puts, w)
+			} else {
+				w.Write([]byte(`{}`))
+			}
+		case "DELETE":
+			w.Write([]byte(`{}`))
+		}
+		calls <- r.Method + " " + listenKey
+	}))
+	return srv, calls, conns
+}
+
+func expectCall(t *testing.T, calls chan string, want string) time.Time {
+	t.Helper()
+	select {
+	case got := <-calls:
+		if got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+		return time.Now()
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timeout waiting for %q", want)
+	}
+	return time.Time{}
+}
+
+func newUserDataWs(srv *httptest.Server) *binance.BinanceWs {
+	return binance.NewBinanceWS("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", "")
+}
+
+func TestUserDataDispatchAndExpired(t *testing.T) {
+	srv, calls, conns := newUserDataServer(nil)
+	defer srv.Close()
+
+	bw := newUserDataWs(srv)
+	defer bw.Close()
+	reports := make(chan *binance.ExecutionReport, 4)
+	positions := make(chan *binance.AccountPosition, 4)
+	updates := make(chan *binance.BalanceUpdate, 4)
+	bw.SetExecutionReportCallback(func(r *binance.ExecutionReport) { reports <- r })
+	bw.SetAccountPositionCallback(func(p *binance.AccountPosition) { positions <- p })
+	bw.SetBalanceUpdateCallback(func(u *binance.BalanceUpdate) { updates <- u })
+	if err := bw.SubscribeUserData(newTestBinance(srv)); err != nil {
+		t.Fatal(err)
+	}
+	expectCall(t, calls, "POST k1")
+	expectCall(t, calls, "WS k1")
+	c := <-conns
+
+	c.WriteMessage(websocket.TextMessage, []byte(`{"e":"outboundAccountInfo","E":1}`))
+	c.WriteMessage(websocket.TextMessage, []byte(executionReport))
+	c.WriteMessage(websocket.TextMessage, []byte(`{"e":"outboundAccountPosition","E":1,"u":2,"B":[{"a":"BTC","f":"1.5","l":"0.5"}]}`))
+	c.WriteMessage(websocket.TextMessage, []byte(`{"e":"balanceUpdate","E":1,"a":"USDT","d":"-10.5","T":3}`))
+	select {
+	case r := <-reports:
+		if r.OrderID != 4293153 {
+			t.Fatalf("unexpected report %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("execution report not dispatched")
+	}
+	select {
+	case p := <-positions:
+		if len(p.Balances) != 1 || p.Balances[0].Asset != "BTC" || p.Balances[0].Locked.String() != "0.5" {
+			t.Fatalf("unexpected position %+v", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("account position not dispatched")
+	}
+	select {
+	case u := <-updates:
+		if u.Asset != "USDT" || u.Delta.String() != "-10.5" {
+			t.Fatalf("unexpected balance update %+v", u)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("balance update not dispatched")
+	}
+
+	// listenKey 失效推送后重新生成并重连
+	c.WriteMessage(websocket.TextMessage, []byte(`{"e":"listenKeyExpired","E":1,"listenKey":"k1"}`))
+	expectCall(t, calls, "POST k2")
+	expectCall(t, calls, "WS k2")
+	c = <-conns
+	c.WriteMessage(websocket.TextMessage, []byte(executionReport))
+	select {
+	case <-reports:
+	case <-time.After(2 * time.Second):
+		t.Fatal("execution report not dispatched after reconnect")
+	}
+
+	bw.Close()
+	expectCall(t, calls, "DELETE k2")
+}
+
+func TestUserDataKeepAliveRetryAndInvalidKey(t *testing.T) {
+	srv, calls, _ := newUserDataServer(func(n int, w http.ResponseWriter) {
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusInternalServerError)
+		case 3:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":-1125,"msg":"This listenKey does not exist."}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+	defer srv.Close()
+
+	bw := newUserDataWs(srv).SetListenKeyKeepAlive(300*time.Millisecond, 10*time.Millisecond)
+	defer bw.Close()
+	bw.SetExecutionReportCallback(func(*binance.ExecutionReport) {})
+	if err := bw.SubscribeUserData(newTestBinance(srv)); err != nil {
+		t.Fatal(err)
+	}
+	expectCall(t, calls, "POST k1")
+	expectCall(t, calls, "WS k1")
+
+	// 延长失败后按重试间隔尽快重试，而不是等待下一个周期
+	failed := expectCall(t, calls, "PUT k1")
+	if retried := expectCall(t, calls, "PUT k1"); retried.Sub(failed) > 200*time.Millisecond {
+		t.Fatalf("keepalive retried after %s", retried.Sub(failed))
+	}
+
+	// -1125 表示 listenKey 已失效，重新生成并重连
+	expectCall(t, calls, "PUT k1")
+	expectCall(t, calls, "POST k2")
+	expectCall(t, calls, "WS k2")
+
+	bw.Close()
+	for {
+		select {
+		case got := <-calls:
+			if got == "PUT k2" {
+				continue
+			}
+			if got != "DELETE k2" {
+				t.Fatalf("expected DELETE k2, got %q", got)
+			}
+			return
+		case <-time.After(2 * time.Second):
+			t.Fatal("listenKey not closed")
+		}
+	}
+}
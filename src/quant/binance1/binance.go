@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"tinyquant/src/quant"
 
 	"github.com/y905699146/binance"
 )
@@ -23,7 +24,7 @@ func TestBinance() {
 	b := binance.NewBinance(binanceService)
 
 	kl, err := b.Klines(binance.KlinesRequest{
-		Symbol:   "BNBETH",
+		Symbol:   quant.BNB_ETH.ToSymbol(""),
 		Interval: binance.Hour,
 	})
 	if err != nil {
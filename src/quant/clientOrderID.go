@@ -0,0 +1,75 @@
+package quant
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+)
+
+const (
+	clientOrderIDSep      = "-"
+	maxStrategyPrefixLen  = 12
+	clientOrderIDHashLen  = 22
+	defaultStrategyPrefix = "tq"
+)
+
+// strategyPrefix 策略名只保留字母和数字，最长12位
+func strategyPrefix(strategy string) string {
+	var b strings.Builder
+	for _, r := range strategy {
+		if b.Len() >= maxStrategyPrefixLen {
+			break
+		}
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return defaultStrategyPrefix
+	}
+	return b.String()
+}
+
+/*
+	ClientOrderID 由策略名和下单意图生成确定的客户端订单号，如 grid-3f2a...
+	同一 strategy+intent 总是得到相同的订单号，程序重启后重新提交时可以据此去重
+	格式为 <策略前缀>-<22位hex>，最长35位，满足 binance newClientOrderId 的限制
+*/
+func ClientOrderID(strategy, intent string) string {
+	sum := sha256.Sum256([]byte(strategy + "\x00" + intent))
+	return strategyPrefix(strategy) + clientOrderIDSep + hex.EncodeToString(sum[:])[:clientOrderIDHashLen]
+}
+
+// StrategyOfClientOrderID 返回订单号中的策略前缀，非本系统生成的订单号返回空
+func StrategyOfClientOrderID(clientOrderID string) string {
+	i := strings.LastIndex(clientOrderID, clientOrderIDSep)
+	if i <= 0 || len(clientOrderID)-i-1 != clientOrderIDHashLen {
+		return ""
+	}
+	return clientOrderID[:i]
+}
+
+/*
+	ResolveClientOrderID 确定下单请求的客户端订单号并写回 ClientOrderID
+	已指定 ClientOrderID 时直接使用；指定 Intent 时按 ClientOrderID(Strategy, Intent) 生成；
+	否则生成随机订单号，此时无法在重启后去重
+	known 为 true 表示订单号是确定的，可能已经提交过
+	系统随机数不可用时返回错误，不写回 ClientOrderID
+*/
+func (r *OrderRequest) ResolveClientOrderID() (clientOrderID string, known bool, err error) {
+	switch {
+	case r.ClientOrderID != "":
+		return r.ClientOrderID, true, nil
+	case r.Intent != "":
+		r.ClientOrderID = ClientOrderID(r.Strategy, r.Intent)
+		return r.ClientOrderID, true, nil
+	}
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", false, err
+	}
+	r.ClientOrderID = ClientOrderID(r.Strategy, hex.EncodeToString(nonce))
+	return r.ClientOrderID, false, nil
+}
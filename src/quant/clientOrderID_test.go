@@ -0,0 +1,65 @@
+package quant_test
+
+import (
+	"crypto/rand"
+	"errors"
+	"regexp"
+	"testing"
+	"tinyquant/src/quant"
+)
+
+func TestClientOrderID(t *testing.T) {
+	id := quant.ClientOrderID("grid_v2", "level3:round12")
+	if id != quant.ClientOrderID("grid_v2", "level3:round12") {
+		t.Fatal("client order id should be deterministic")
+	}
+	if id == quant.ClientOrderID("grid_v2", "level3:round13") || id == quant.ClientOrderID("grid", "level3:round12") {
+		t.Fatal("different intents should not collide")
+	}
+	if !regexp.MustCompile(`^[.A-Z:/a-z0-9_-]{1,36}$`).MatchString(id) {
+		t.Fatalf("%s is not a valid binance client order id", id)
+	}
+	if s := quant.StrategyOfClientOrderID(id); s != "gridv2" {
+		t.Fatalf("strategy %q", s)
+	}
+	if s := quant.StrategyOfClientOrderID("web_abc123"); s != "" {
+		t.Fatalf("foreign id parsed as strategy %q", s)
+	}
+}
+
+func TestResolveClientOrderID(t *testing.T) {
+	req := &quant.OrderRequest{Strategy: "grid", Intent: "level1"}
+	if id, known, err := req.ResolveClientOrderID(); err != nil || !known || id != quant.ClientOrderID("grid", "level1") || req.ClientOrderID != id {
+		t.Fatalf("unexpected id %s %v %v", id, known, err)
+	}
+	random := &quant.OrderRequest{Strategy: "grid"}
+	id, known, err := random.ResolveClientOrderID()
+	if err != nil || known || quant.StrategyOfClientOrderID(id) != "grid" {
+		t.Fatalf("unexpected random id %s %v %v", id, known, err)
+	}
+	if other, _, _ := (&quant.OrderRequest{Strategy: "grid"}).ResolveClientOrderID(); other == id {
+		t.Fatal("random ids should differ")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("entropy unavailable")
+}
+
+func TestResolveClientOrderIDRandFailure(t *testing.T) {
+	reader := rand.Reader
+	rand.Reader = failingReader{}
+	defer func() { rand.Reader = reader }()
+
+	req := &quant.OrderRequest{Strategy: "grid"}
+	if id, _, err := req.ResolveClientOrderID(); err == nil || id != "" || req.ClientOrderID != "" {
+		t.Fatalf("expected error without id, got %q %v", id, err)
+	}
+	// 确定的订单号不需要随机数
+	req.Intent = "level1"
+	if id, known, err := req.ResolveClientOrderID(); err != nil || !known || id != quant.ClientOrderID("grid", "level1") {
+		t.Fatalf("unexpected id %s %v %v", id, known, err)
+	}
+}
@@ -0,0 +1,85 @@
+package quant
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+	CurrencyPair 与交易所无关的交易对，如 BTC/USDT
+	各交易所适配器负责与原生名称互相转换: binance BTCUSDT，huobi btcusdt，okex BTC-USDT
+	精度从交易所的交易规则中获取，为0时表示未知
+*/
+type CurrencyPair struct {
+	Base            string // 交易货币，如 BTC
+	Quote           string // 计价货币，如 USDT
+	PricePrecision  int    // 价格小数位数
+	AmountPrecision int    // 数量小数位数
+}
+
+var (
+	BTC_USDT = NewCurrencyPair("BTC", "USDT")
+	ETH_USDT = NewCurrencyPair("ETH", "USDT")
+	BNB_USDT = NewCurrencyPair("BNB", "USDT")
+	ETH_BTC  = NewCurrencyPair("ETH", "BTC")
+	BNB_ETH  = NewCurrencyPair("BNB", "ETH")
+)
+
+// 原生名称没有分隔符时按计价货币后缀拆分，较长的后缀优先匹配
+var knownQuoteAssets = []string{
+	"FDUSD", "USDT", "USDC", "BUSD", "TUSD", "HUSD", "DAI", "BTC", "ETH", "BNB", "HT", "OKB", "EUR", "TRY",
+}
+
+func NewCurrencyPair(base, quote string) CurrencyPair {
+	return CurrencyPair{Base: strings.ToUpper(base), Quote: strings.ToUpper(quote)}
+}
+
+/*
+	ParseCurrencyPair 解析交易对名称
+	支持 BTC/USDT、BTC-USDT、btc_usdt 等带分隔符的名称，以及 BTCUSDT、btcusdt 等常见计价货币结尾的名称
+*/
+func ParseCurrencyPair(symbol string) (CurrencyPair, error) {
+	if i := strings.IndexAny(symbol, "/-_"); i > 0 && i < len(symbol)-1 {
+		return NewCurrencyPair(symbol[:i], symbol[i+1:]), nil
+	}
+	upper := strings.ToUpper(symbol)
+	for _, quote := range knownQuoteAssets {
+		if len(upper) > len(quote) && strings.HasSuffix(upper, quote) {
+			return NewCurrencyPair(upper[:len(upper)-len(quote)], quote), nil
+		}
+	}
+	return CurrencyPair{}, fmt.Errorf("unknown currency pair %s", symbol)
+}
+
+// String 如 BTC/USDT
+func (p CurrencyPair) String() string {
+	return p.ToSymbol("/")
+}
+
+// ToSymbol 以 sep 连接的大写名称，如 ToSymbol("-") 返回 BTC-USDT
+func (p CurrencyPair) ToSymbol(sep string) string {
+	return p.Base + sep + p.Quote
+}
+
+// ToLowerSymbol 以 sep 连接的小写名称，如 ToLowerSymbol("") 返回 btcusdt
+func (p CurrencyPair) ToLowerSymbol(sep string) string {
+	return strings.ToLower(p.ToSymbol(sep))
+}
+
+func (p CurrencyPair) IsZero() bool {
+	return p.Base == "" || p.Quote == ""
+}
+
+// Equal 只比较交易货币与计价货币，忽略精度
+func (p CurrencyPair) Equal(other CurrencyPair) bool {
+	return p.Base == other.Base && p.Quote == other.Quote
+}
+
+// StepPrecision 按最小变动单位计算小数位数，如 "0.0100" 返回 2
+func StepPrecision(step string) int {
+	i := strings.IndexByte(step, '.')
+	if i < 0 {
+		return 0
+	}
+	return len(strings.TrimRight(step[i+1:], "0"))
+}
@@ -0,0 +1,35 @@
+package quant_test
+
+import (
+	"testing"
+	"tinyquant/src/quant"
+)
+
+func TestParseCurrencyPair(t *testing.T) {
+	for _, symbol := range []string{"BTCUSDT", "btcusdt", "BTC-USDT", "btc_usdt", "BTC/USDT"} {
+		pair, err := quant.ParseCurrencyPair(symbol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pair.Equal(quant.BTC_USDT) {
+			t.Fatalf("%s parsed as %s", symbol, pair)
+		}
+	}
+	if _, err := quant.ParseCurrencyPair("XYZ"); err == nil {
+		t.Fatal("expected error for unknown quote asset")
+	}
+
+	pair := quant.ETH_BTC
+	if pair.ToSymbol("") != "ETHBTC" || pair.ToLowerSymbol("") != "ethbtc" || pair.ToSymbol("-") != "ETH-BTC" {
+		t.Fatalf("unexpected symbols for %s", pair)
+	}
+}
+
+func TestStepPrecision(t *testing.T) {
+	cases := map[string]int{"0.01000000": 2, "1.00000000": 0, "0.00000001": 8, "10": 0, "0.5": 1}
+	for step, want := range cases {
+		if got := quant.StepPrecision(step); got != want {
+			t.Fatalf("StepPrecision(%s) = %d, want %d", step, got, want)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package quant
+
+import "context"
+
+/*
+	Exchange 交易所REST接口，策略只依赖该接口即可在不同交易所间切换
+	交易对统一使用 CurrencyPair，由适配器转换为原生名称，orderID 统一使用字符串
+*/
+type Exchange interface {
+	// 交易所名称，如 binance
+	String() string
+
+	// 交易所支持的所有交易对，包含价格与数量精度
+	GetCurrencyPairs(ctx context.Context) ([]CurrencyPair, error)
+
+	GetTicker(ctx context.Context, pair CurrencyPair) (*Ticker, error)
+	GetDepth(ctx context.Context, pair CurrencyPair, size int) (*Depth, error)
+	// period 为 KLINE_PERIOD_*，startTime/endTime 单位:ms，为0时不限制
+	GetKlines(ctx context.Context, pair CurrencyPair, period int, startTime, endTime int64, limit int) ([]*Kline, error)
+
+	GetBalances(ctx context.Context) ([]*Balance, error)
+
+	PlaceOrder(ctx context.Context, req *OrderRequest) (*Order, error)
+	CancelOrder(ctx context.Context, pair CurrencyPair, orderID string) (*Order, error)
+	GetOrder(ctx context.Context, pair CurrencyPair, orderID string) (*Order, error)
+	GetOpenOrders(ctx context.Context, pair CurrencyPair) ([]*Order, error)
+}
+
+// Stream 交易所行情推送，先注册回调再订阅
+type Stream interface {
+	SetTickerCallback(callback func(*Ticker))
+	SetDepthCallback(callback func(*Depth))
+	SetTradeCallback(callback func(*Trade))
+	// 回调参数为k线及其周期 KLINE_PERIOD_*
+	SetKlineCallback(callback func(*Kline, int))
+
+	SubscribeTicker(pair CurrencyPair) error
+	SubscribeDepth(pair CurrencyPair, size int) error
+	SubscribeTrade(pair CurrencyPair) error
+	SubscribeKline(pair CurrencyPair, period int) error
+
+	Close()
+}
@@ -0,0 +1,53 @@
+package huobi
+
+import "tinyquant/src/quant"
+
+const (
+	defaultBaseURL      = "https://api.huobi.pro"
+	defaultWebSocketURL = "wss://api.huobi.pro/ws"
+)
+
+const (
+	TimestampURL   = "/v1/common/timestamp"
+	AccountsURL    = "/v1/account/accounts"
+	BalanceURL     = "/v1/account/accounts/%d/balance"
+	PlaceOrderURL  = "/v1/order/orders/place"
+	OrderURL       = "/v1/order/orders/%s"
+	CancelOrderURL = "/v1/order/orders/%s/submitcancel"
+	OpenOrdersURL  = "/v1/order/openOrders"
+	TickerURL      = "/market/detail/merged"
+	DepthURL       = "/market/depth"
+	KlineURL       = "/market/history/kline"
+	SymbolsURL     = "/v1/common/symbols"
+)
+
+const (
+	statusOK        = "ok"
+	accountTypeSpot = "spot"
+	// 单次最多返回2000根k线
+	maxKlineLimit = 2000
+)
+
+var _INERNAL_ORDER_STATUS_CONVERTER = map[string]quant.TradeStatus{
+	"created":          quant.ORDER_NEW,
+	"submitted":        quant.ORDER_NEW,
+	"partial-filled":   quant.ORDER_PARTIALLY_FILLED,
+	"filled":           quant.ORDER_FILLED,
+	"partial-canceled": quant.ORDER_CANCELED,
+	"canceled":         quant.ORDER_CANCELED,
+	"canceling":        quant.ORDER_PENDING_CANCEL,
+}
+
+var _INERNAL_KLINE_PERIOD_CONVERTER = map[int]string{
+	quant.KLINE_PERIOD_1MIN:   "1min",
+	quant.KLINE_PERIOD_5MIN:   "5min",
+	quant.KLINE_PERIOD_15MIN:  "15min",
+	quant.KLINE_PERIOD_30MIN:  "30min",
+	quant.KLINE_PERIOD_60MIN:  "60min",
+	quant.KLINE_PERIOD_1H:     "60min",
+	quant.KLINE_PERIOD_4H:     "4hour",
+	quant.KLINE_PERIOD_1DAY:   "1day",
+	quant.KLINE_PERIOD_1WEEK:  "1week",
+	quant.KLINE_PERIOD_1MONTH: "1mon",
+	quant.KLINE_PERIOD_1YEAR:  "1year",
+}
@@ -0,0 +1,64 @@
+package huobi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// huobi error codes
+const (
+	ERR_SIGNATURE_FAILED     = "api-signature-not-valid"
+	ERR_INVALID_TIMESTAMP    = "invalid-timestamp"
+	ERR_INSUFFICIENT_BALANCE = "account-frozen-balance-insufficient-error"
+	ERR_ORDER_NOT_FOUND      = "base-record-invalid"
+	ERR_RATE_LIMITED         = "api-limit-exceeded"
+)
+
+// APIError huobi接口返回的错误，huobi的错误码为字符串
+type APIError struct {
+	StatusCode int    // HTTP状态码
+	Code       string // huobi错误码 err-code
+	Msg        string // 错误信息 err-msg
+	Path       string // 请求路径
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("huobi api error: status=%d code=%s msg=%s path=%s", e.StatusCode, e.Code, e.Msg, e.Path)
+}
+
+// IsRateLimited 请求频率超限
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.Code == ERR_RATE_LIMITED
+}
+
+// IsInvalidSignature 签名错误
+func (e *APIError) IsInvalidSignature() bool {
+	return e.Code == ERR_SIGNATURE_FAILED
+}
+
+// IsInsufficientBalance 下单时余额不足
+func (e *APIError) IsInsufficientBalance() bool {
+	return e.Code == ERR_INSUFFICIENT_BALANCE
+}
+
+// IsOrderNotFound 订单不存在
+func (e *APIError) IsOrderNotFound() bool {
+	return e.Code == ERR_ORDER_NOT_FOUND
+}
+
+// ParseError 接口返回的数据中存在格式错误的字段
+type ParseError struct {
+	Source string   // 数据来源，如 order、balance
+	Fields []string // 格式错误的字段及原因
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("huobi malformed %s fields: %s", e.Source, strings.Join(e.Fields, "; "))
+}
+
+// AsAPIError 判断err是否为huobi接口错误
+func AsAPIError(err error) (*APIError, bool) {
+	apiErr, ok := err.(*APIError)
+	return apiErr, ok
+}
@@ -1,30 +1,445 @@
-package quant
+package huobi
 
 import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
 
-	"github.com/huobirdcenter/huobi_golang/pkg/client"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
 )
 
-func init() {
-	// Get the timestamp from Huobi server and print on console
-	client := new(client.CommonClient).Init(config.Host)
-	resp, err := client.GetTimestamp()
+var _ quant.Exchange = (*Huobi)(nil)
 
+type Huobi struct {
+	accessKey  string
+	secretKey  string
+	baseURL    string
+	httpClient *http.Client
+
+	accountMu sync.Mutex
+	accountID int64 // spot账户ID，首次使用时获取
+}
+
+func NewHuobi(accessKey, secretKey string) *Huobi {
+	return &Huobi{
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		baseURL:    defaultBaseURL,
+		httpClient: newHttpClient(),
+	}
+}
+
+// newHttpClient 按 [http] / [http.huobi] 配置创建 http client，配置无效时使用默认配置
+func newHttpClient() *http.Client {
+	client, err := util.NewHttpClient(util.LoadHttpClientConfig("huobi"))
+	if err != nil {
+		Logger.Error("Huobi Service Load Http Client Config Failed", zap.Error(err))
+		client, _ = util.NewHttpClient(util.DefaultHttpClientConfig())
+	}
+	return client
+}
+
+func (h *Huobi) String() string {
+	return "huobi"
+}
+
+// toSymbol 转换为huobi的交易对名称，如 btcusdt
+func toSymbol(pair quant.CurrencyPair) string {
+	return pair.ToLowerSymbol("")
+}
+
+// SetBaseURL 设置REST地址，如 https://api-aws.huobi.pro
+func (h *Huobi) SetBaseURL(baseURL string) *Huobi {
+	h.baseURL = strings.TrimSuffix(baseURL, "/")
+	return h
+}
+
+func (h *Huobi) SetHttpClient(client *http.Client) *Huobi {
+	h.httpClient = client
+	return h
+}
+
+/*
+	签名 (Signature Version 2)
+	待签名字符串: METHOD\nhost\npath\n按参数名排序后的query
+*/
+func (h *Huobi) sign(method, path string, query url.Values) error {
+	u, err := url.Parse(h.baseURL)
+	if err != nil {
+		return err
+	}
+	query.Del("Signature")
+	query.Set("AccessKeyId", h.accessKey)
+	query.Set("SignatureMethod", "HmacSHA256")
+	query.Set("SignatureVersion", "2")
+	query.Set("Timestamp", time.Now().UTC().Format("2006-01-02T15:04:05"))
+	payload := strings.Join([]string{method, strings.ToLower(u.Host), path, query.Encode()}, "\n")
+	mac := hmac.New(sha256.New, []byte(h.secretKey))
+	mac.Write([]byte(payload))
+	query.Set("Signature", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// response huobi接口的通用响应，行情接口的数据在tick字段，其余接口在data字段
+type response struct {
+	Status  string          `json:"status"`
+	ErrCode string          `json:"err-code"`
+	ErrMsg  string          `json:"err-msg"`
+	Ts      int64           `json:"ts"`
+	Data    json.RawMessage `json:"data"`
+	Tick    json.RawMessage `json:"tick"`
+}
+
+/*
+	发送请求，status不为ok时返回 *APIError
+	body : POST请求的JSON参数，可为nil
+	signed : 是否需要签名
+*/
+func (h *Huobi) request(ctx context.Context, method, path string, query url.Values, body interface{}, signed bool) (*response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	if signed {
+		if err := h.sign(method, path, query); err != nil {
+			return nil, err
+		}
+	}
+	urlx := h.baseURL + path
+	if encoded := query.Encode(); encoded != "" {
+		urlx += "?" + encoded
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	r, err := http.NewRequest(method, urlx, reader)
+	if err != nil {
+		return nil, err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+	Logger.Debug("http request ", zap.String("method", method), zap.String("url", urlx))
+	res, err := h.httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return nil, &APIError{StatusCode: res.StatusCode, Msg: string(data), Path: path}
+	}
+	resp := new(response)
+	if err := json.Unmarshal(data, resp); err != nil {
+		return nil, fmt.Errorf("decode %s response: %v", path, err)
+	}
+	if resp.Status != statusOK {
+		return nil, &APIError{StatusCode: res.StatusCode, Code: resp.ErrCode, Msg: resp.ErrMsg, Path: path}
+	}
+	return resp, nil
+}
+
+// GetServerTime 获取服务器时间，单位:ms
+func (h *Huobi) GetServerTime(ctx context.Context) (int64, error) {
+	resp, err := h.request(ctx, http.MethodGet, TimestampURL, nil, nil, false)
+	if err != nil {
+		Logger.Error("Huobi Service Get Server Time Failed", zap.Error(err))
+		return 0, err
+	}
+	var ts int64
+	if err := json.Unmarshal(resp.Data, &ts); err != nil {
+		return 0, err
+	}
+	return ts, nil
+}
+
+/////////////////////////////*********订单**********//////////////////////////////////////
+
+/*
+	下单
+	req.Pair : 交易对
+	req.Side : BUY / SELL，BUY_MARKET / SELL_MARKET 视为市价单
+	req.ClientOrderID : 为空时由 req.ResolveClientOrderID 生成，作为 client-order-id 发送
+	req.TimeInForce : 限价单支持 IOC / FOK
+	req.Amount : 基础货币数量，市价买单不接受
+	req.QuoteAmount : 计价货币金额，市价买单必须指定且只能使用 QuoteAmount
+*/
+func (h *Huobi) PlaceOrder(ctx context.Context, req *quant.OrderRequest) (*quant.Order, error) {
+	side, orderType := quant.BUY, req.Type
+	switch req.Side {
+	case quant.BUY:
+	case quant.SELL:
+		side = quant.SELL
+	case quant.BUY_MARKET:
+		orderType = quant.ORDER_TYPE_MARKET
+	case quant.SELL_MARKET:
+		side, orderType = quant.SELL, quant.ORDER_TYPE_MARKET
+	default:
+		return nil, fmt.Errorf("invalid order side %v", req.Side)
+	}
+	if orderType == "" {
+		orderType = quant.ORDER_TYPE_LIMIT
+	}
+	if err := checkOrderRequest(orderType, side, req); err != nil {
+		return nil, err
+	}
+	clientOrderID, _, err := req.ResolveClientOrderID()
+	if err != nil {
+		Logger.Error("Huobi Service Place Order Failed", zap.Error(err))
+		return nil, err
+	}
+	accountID, err := h.getAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	symbol := toSymbol(req.Pair)
+	typ := strings.ToLower(side.String() + "-" + orderType)
+	switch req.TimeInForce {
+	case quant.TIME_IN_FORCE_IOC:
+		typ = strings.ToLower(side.String()) + "-ioc"
+	case quant.TIME_IN_FORCE_FOK:
+		typ = strings.ToLower(side.String()) + "-limit-fok"
+	}
+	// 市价买单按计价货币金额下单
+	amount := req.Amount
+	if req.QuoteAmount.IsPositive() {
+		amount = req.QuoteAmount
+	}
+	params := map[string]string{
+		"account-id":      strconv.FormatInt(accountID, 10),
+		"symbol":          symbol,
+		"type":            typ,
+		"amount":          amount.String(),
+		"client-order-id": clientOrderID,
+	}
+	if orderType == quant.ORDER_TYPE_LIMIT {
+		params["price"] = req.Price.String()
+	}
+	resp, err := h.request(ctx, http.MethodPost, PlaceOrderURL, nil, params, true)
+	if err != nil {
+		Logger.Error("Huobi Service Place Order Failed", zap.Error(err))
+		return nil, err
+	}
+	var orderID string
+	if err := json.Unmarshal(resp.Data, &orderID); err != nil {
+		return nil, err
+	}
+	return &quant.Order{
+		Pair:          req.Pair,
+		Symbol:        symbol,
+		OrderID:       util.ToInt(orderID),
+		OrderID2:      orderID,
+		ClientOrderID: clientOrderID,
+		Side:          side,
+		Type:          orderType,
+		Price:         req.Price,
+		Amount:        amount,
+		Status:        quant.ORDER_NEW,
+		OrderTime:     int(resp.Ts),
+	}, nil
+}
+
+// checkOrderRequest 检查 huobi 不支持的下单参数，避免参数被静默忽略
+func checkOrderRequest(orderType string, side quant.TradeSide, req *quant.OrderRequest) error {
+	switch {
+	case orderType != quant.ORDER_TYPE_LIMIT && orderType != quant.ORDER_TYPE_MARKET:
+		return fmt.Errorf("unsupported order type %s", orderType)
+	case !req.StopPrice.IsZero():
+		return fmt.Errorf("stop price not supported by huobi")
+	case !req.IcebergAmount.IsZero():
+		return fmt.Errorf("iceberg amount not supported by huobi")
+	case req.ResponseType != "":
+		return fmt.Errorf("response type not supported by huobi")
+	case req.QuoteAmount.IsNegative():
+		return fmt.Errorf("invalid quote amount %s", req.QuoteAmount)
+	case orderType == quant.ORDER_TYPE_MARKET && side == quant.BUY:
+		// huobi 市价买单的 amount 为计价货币金额，不接受基础货币数量，避免与其他交易所含义不同
+		if !req.Amount.IsZero() {
+			return fmt.Errorf("huobi market buy order requires quote amount instead of amount")
+		}
+		if !req.QuoteAmount.IsPositive() {
+			return fmt.Errorf("huobi market buy order requires quote amount")
+		}
+	case !req.QuoteAmount.IsZero():
+		return fmt.Errorf("quote amount only allowed for huobi market buy order")
+	}
+	switch req.TimeInForce {
+	case "":
+	case quant.TIME_IN_FORCE_GTC, quant.TIME_IN_FORCE_IOC, quant.TIME_IN_FORCE_FOK:
+		if orderType != quant.ORDER_TYPE_LIMIT {
+			return fmt.Errorf("timeInForce not allowed for %s order", orderType)
+		}
+	default:
+		return fmt.Errorf("invalid timeInForce %s", req.TimeInForce)
+	}
+	return nil
+}
+
+/*
+	撤销订单，撤单为异步处理，返回的订单状态为撤销中
+	pair : huobi撤单不需要交易对，仅用于填充返回的订单
+*/
+func (h *Huobi) CancelOrder(ctx context.Context, pair quant.CurrencyPair, orderID string) (*quant.Order, error) {
+	_, err := h.request(ctx, http.MethodPost, fmt.Sprintf(CancelOrderURL, url.PathEscape(orderID)), nil, nil, true)
+	if err != nil {
+		Logger.Error("Huobi Service Cancel Order Failed", zap.Error(err))
+		return nil, err
+	}
+	return &quant.Order{
+		Pair:     pair,
+		Symbol:   toSymbol(pair),
+		OrderID:  util.ToInt(orderID),
+		OrderID2: orderID,
+		Status:   quant.ORDER_PENDING_CANCEL,
+	}, nil
+}
+
+// GetOrder 查询订单
+func (h *Huobi) GetOrder(ctx context.Context, pair quant.CurrencyPair, orderID string) (*quant.Order, error) {
+	resp, err := h.request(ctx, http.MethodGet, fmt.Sprintf(OrderURL, url.PathEscape(orderID)), nil, nil, true)
 	if err != nil {
-		fmt.Println(err)
-	} else {
-		fmt.Println("timestamp:", resp)
+		Logger.Error("Huobi Service Get Order Failed", zap.Error(err))
+		return nil, err
+	}
+	order := new(orderResponse)
+	if err := json.Unmarshal(resp.Data, order); err != nil {
+		return nil, err
 	}
+	return order.toOrder(pair)
+}
 
-	// Get the list of accounts owned by this API user and print the detail on console
-	client := new(client.AccountClient).Init(config.AccessKey, config.SecretKey, config.Host)
-	resp, err := client.GetAccountInfo()
+// GetOpenOrders 查询交易对的所有挂单
+func (h *Huobi) GetOpenOrders(ctx context.Context, pair quant.CurrencyPair) ([]*quant.Order, error) {
+	accountID, err := h.getAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	query.Set("account-id", strconv.FormatInt(accountID, 10))
+	query.Set("symbol", toSymbol(pair))
+	resp, err := h.request(ctx, http.MethodGet, OpenOrdersURL, query, nil, true)
 	if err != nil {
-		fmt.Println(err)
-	} else {
-		for _, result := range resp {
-			fmt.Printf("account: %+v\n", result)
+		Logger.Error("Huobi Service Get Open Orders Failed", zap.Error(err))
+		return nil, err
+	}
+	var orders []*orderResponse
+	if err := json.Unmarshal(resp.Data, &orders); err != nil {
+		return nil, err
+	}
+	result := make([]*quant.Order, 0, len(orders))
+	for _, order := range orders {
+		o, err := order.toOrder(pair)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+/*
+	orderResponse 订单详情
+	查询单个订单返回 field-* 字段，查询挂单返回 filled-* 字段
+*/
+type orderResponse struct {
+	ID               int64  `json:"id"`
+	Symbol           string `json:"symbol"`
+	AccountID        int64  `json:"account-id"`
+	ClientOrderID    string `json:"client-order-id"`
+	Amount           string `json:"amount"`
+	Price            string `json:"price"`
+	CreatedAt        int64  `json:"created-at"`
+	Type             string `json:"type"`
+	FieldAmount      string `json:"field-amount"`
+	FieldCashAmount  string `json:"field-cash-amount"`
+	FieldFees        string `json:"field-fees"`
+	FilledAmount     string `json:"filled-amount"`
+	FilledCashAmount string `json:"filled-cash-amount"`
+	FilledFees       string `json:"filled-fees"`
+	State            string `json:"state"`
+}
+
+func (o *orderResponse) toOrder(pair quant.CurrencyPair) (*quant.Order, error) {
+	p := &fieldParser{source: "order"}
+	dealAmount := p.decimal("filled-amount", firstNonEmpty(o.FilledAmount, o.FieldAmount))
+	cashAmount := p.decimal("filled-cash-amount", firstNonEmpty(o.FilledCashAmount, o.FieldCashAmount))
+	avgPrice := decimal.Zero
+	if dealAmount.IsPositive() && cashAmount.IsPositive() {
+		avgPrice = cashAmount.Div(dealAmount)
+	}
+	// type 形如 buy-limit、sell-market、buy-limit-maker、buy-ioc、buy-limit-fok
+	side := quant.BUY
+	if strings.HasPrefix(o.Type, "sell") {
+		side = quant.SELL
+	}
+	orderType := 0
+	switch {
+	case strings.HasSuffix(o.Type, "-limit-maker"):
+		orderType = 1
+	case strings.HasSuffix(o.Type, "-fok"):
+		orderType = 2
+	case strings.HasSuffix(o.Type, "-ioc"):
+		orderType = 3
+	}
+	typ := quant.ORDER_TYPE_LIMIT
+	if strings.HasSuffix(o.Type, "-market") {
+		typ = quant.ORDER_TYPE_MARKET
+	}
+	status, ok := _INERNAL_ORDER_STATUS_CONVERTER[o.State]
+	if !ok {
+		status = quant.ORDER_NEW
+	}
+	order := &quant.Order{
+		Pair:       pair,
+		Symbol:     o.Symbol,
+		OrderID:    int(o.ID),
+		OrderID2:   strconv.FormatInt(o.ID, 10),
+		OrderType:  orderType,
+		Side:       side,
+		AvgPrice:   avgPrice,
+		Type:       typ,
+		Fee:        p.decimal("filled-fees", firstNonEmpty(o.FilledFees, o.FieldFees)),
+		Price:      p.decimal("price", o.Price),
+		DealAmount: dealAmount,
+		Amount:     p.decimal("amount", o.Amount),
+		Status:     status,
+		OrderTime:  int(o.CreatedAt),
+
+		ClientOrderID: o.ClientOrderID,
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
 		}
 	}
+	return ""
 }
@@ -0,0 +1,106 @@
+package huobi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+
+	"go.uber.org/zap"
+)
+
+// Account 账户，一个API Key下可有多个账户
+type Account struct {
+	ID      int64  `json:"id"`
+	Type    string `json:"type"` // spot / margin / otc / point
+	SubType string `json:"subtype"`
+	State   string `json:"state"` // working / lock
+}
+
+/*
+	获取账户列表
+*/
+func (h *Huobi) GetAccounts(ctx context.Context) ([]*Account, error) {
+	resp, err := h.request(ctx, http.MethodGet, AccountsURL, nil, nil, true)
+	if err != nil {
+		Logger.Error("Huobi Service Get Accounts Failed", zap.Error(err))
+		return nil, err
+	}
+	var accounts []*Account
+	if err := json.Unmarshal(resp.Data, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
+
+// getAccountID 获取并缓存spot账户ID，下单与查询余额都需要
+func (h *Huobi) getAccountID(ctx context.Context) (int64, error) {
+	h.accountMu.Lock()
+	defer h.accountMu.Unlock()
+	if h.accountID != 0 {
+		return h.accountID, nil
+	}
+	accounts, err := h.GetAccounts(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, account := range accounts {
+		if account.Type == accountTypeSpot {
+			h.accountID = account.ID
+			return h.accountID, nil
+		}
+	}
+	return 0, errors.New("huobi spot account not found")
+}
+
+/*
+	获取spot账户所有资产的余额
+	huobi按币种分别返回 trade(可用) 和 frozen(冻结) 两条记录，合并为一个 Balance
+*/
+func (h *Huobi) GetBalances(ctx context.Context) ([]*quant.Balance, error) {
+	accountID, err := h.getAccountID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.request(ctx, http.MethodGet, fmt.Sprintf(BalanceURL, accountID), nil, nil, true)
+	if err != nil {
+		Logger.Error("Huobi Service Get Balances Failed", zap.Error(err))
+		return nil, err
+	}
+	data := struct {
+		List []struct {
+			Currency string `json:"currency"`
+			Type     string `json:"type"`
+			Balance  string `json:"balance"`
+		} `json:"list"`
+	}{}
+	if err := json.Unmarshal(resp.Data, &data); err != nil {
+		return nil, err
+	}
+	p := &fieldParser{source: "balance"}
+	var balances []*quant.Balance
+	index := make(map[string]*quant.Balance)
+	for _, item := range data.List {
+		asset := strings.ToUpper(item.Currency)
+		balance, ok := index[asset]
+		if !ok {
+			balance = &quant.Balance{Asset: asset}
+			index[asset] = balance
+			balances = append(balances, balance)
+		}
+		switch item.Type {
+		case "trade":
+			balance.Free = p.decimal(item.Currency+".trade", item.Balance)
+		case "frozen":
+			balance.Locked = p.decimal(item.Currency+".frozen", item.Balance)
+		}
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
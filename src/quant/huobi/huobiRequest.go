@@ -0,0 +1,237 @@
+package huobi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+/////////////////////////////*********获取行情数据**********//////////////////////////////////////
+
+/*
+	获取所有在线交易对及其价格、数量精度
+*/
+func (h *Huobi) GetCurrencyPairs(ctx context.Context) ([]quant.CurrencyPair, error) {
+	resp, err := h.request(ctx, http.MethodGet, SymbolsURL, nil, nil, false)
+	if err != nil {
+		Logger.Error("Huobi Service Get Symbols Failed", zap.Error(err))
+		return nil, err
+	}
+	var symbols []struct {
+		BaseCurrency    string `json:"base-currency"`
+		QuoteCurrency   string `json:"quote-currency"`
+		PricePrecision  int    `json:"price-precision"`
+		AmountPrecision int    `json:"amount-precision"`
+		State           string `json:"state"`
+	}
+	if err := json.Unmarshal(resp.Data, &symbols); err != nil {
+		return nil, err
+	}
+	pairs := make([]quant.CurrencyPair, 0, len(symbols))
+	for _, s := range symbols {
+		if s.State != "online" {
+			continue
+		}
+		pair := quant.NewCurrencyPair(s.BaseCurrency, s.QuoteCurrency)
+		pair.PricePrecision, pair.AmountPrecision = s.PricePrecision, s.AmountPrecision
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+/*
+	获取聚合行情
+	pair(必需) : 交易对
+*/
+func (h *Huobi) GetTicker(ctx context.Context, pair quant.CurrencyPair) (*quant.Ticker, error) {
+	query := url.Values{}
+	query.Set("symbol", toSymbol(pair))
+	resp, err := h.request(ctx, http.MethodGet, TickerURL, query, nil, false)
+	if err != nil {
+		Logger.Error("Huobi Service Get Ticker Failed", zap.Error(err))
+		return nil, err
+	}
+	tick := struct {
+		Close  decimal.Decimal    `json:"close"`
+		High   decimal.Decimal    `json:"high"`
+		Low    decimal.Decimal    `json:"low"`
+		Amount decimal.Decimal    `json:"amount"` // 成交量
+		Vol    decimal.Decimal    `json:"vol"`    // 成交额
+		Bid    [2]decimal.Decimal `json:"bid"`
+		Ask    [2]decimal.Decimal `json:"ask"`
+	}{}
+	if err := json.Unmarshal(resp.Tick, &tick); err != nil {
+		return nil, err
+	}
+	return &quant.Ticker{
+		Pair:   pair,
+		Symbol: toSymbol(pair),
+		Last:   tick.Close,
+		Buy:    tick.Bid[0],
+		Sell:   tick.Ask[0],
+		High:   tick.High,
+		Low:    tick.Low,
+		Vol:    tick.Amount,
+		Date:   uint64(resp.Ts),
+	}, nil
+}
+
+/*
+	获取深度
+	size : 可选值 5, 10, 20，其他值返回150档后截取前size档，<=0 返回全部
+*/
+func (h *Huobi) GetDepth(ctx context.Context, pair quant.CurrencyPair, size int) (*quant.Depth, error) {
+	query := url.Values{}
+	query.Set("symbol", toSymbol(pair))
+	query.Set("type", "step0")
+	if size == 5 || size == 10 || size == 20 {
+		query.Set("depth", strconv.Itoa(size))
+	}
+	resp, err := h.request(ctx, http.MethodGet, DepthURL, query, nil, false)
+	if err != nil {
+		Logger.Error("Huobi Service Get Depth Failed", zap.Error(err))
+		return nil, err
+	}
+	depth, err := parseDepth(resp.Tick, size)
+	if err != nil {
+		return nil, err
+	}
+	depth.Pair, depth.Symbol = pair, toSymbol(pair)
+	return depth, nil
+}
+
+// parseDepth 解析 {"bids":[[price,amount]...],"asks":[...]}，bids 由高到低，asks 由低到高
+func parseDepth(tick json.RawMessage, size int) (*quant.Depth, error) {
+	raw := struct {
+		Bids [][2]decimal.Decimal `json:"bids"`
+		Asks [][2]decimal.Decimal `json:"asks"`
+	}{}
+	if err := json.Unmarshal(tick, &raw); err != nil {
+		return nil, err
+	}
+	depth := &quant.Depth{UTime: time.Now()}
+	for i, bid := range raw.Bids {
+		if size > 0 && i >= size {
+			break
+		}
+		depth.BidList = append(depth.BidList, quant.DepthRecord{Price: bid[0], Amount: bid[1]})
+	}
+	for i, ask := range raw.Asks {
+		if size > 0 && i >= size {
+			break
+		}
+		depth.AskList = append(depth.AskList, quant.DepthRecord{Price: ask[0], Amount: ask[1]})
+	}
+	return depth, nil
+}
+
+// kline huobi k线，id 为开盘时间(s)
+type kline struct {
+	ID     int64           `json:"id"`
+	Open   decimal.Decimal `json:"open"`
+	Close  decimal.Decimal `json:"close"`
+	Low    decimal.Decimal `json:"low"`
+	High   decimal.Decimal `json:"high"`
+	Amount decimal.Decimal `json:"amount"` // 成交量
+	Vol    decimal.Decimal `json:"vol"`    // 成交额
+	Count  int64           `json:"count"`
+}
+
+func (k *kline) toKline(pair quant.CurrencyPair) *quant.Kline {
+	return &quant.Kline{
+		Pair:       pair,
+		Symbol:     toSymbol(pair),
+		Timestamp:  k.ID,
+		Open:       k.Open,
+		Close:      k.Close,
+		High:       k.High,
+		Low:        k.Low,
+		Vol:        k.Amount,
+		OpenTime:   k.ID * 1000,
+		QuoteVol:   k.Vol,
+		TradeCount: k.Count,
+	}
+}
+
+/*
+	获取k线，按开盘时间升序返回
+	huobi只能获取最近的k线，startTime/endTime(ms) 仅用于过滤结果，为0时不限制
+	limit : 默认150，最大2000
+*/
+func (h *Huobi) GetKlines(ctx context.Context, pair quant.CurrencyPair, period int, startTime, endTime int64, limit int) ([]*quant.Kline, error) {
+	periodS, ok := _INERNAL_KLINE_PERIOD_CONVERTER[period]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kline period %d", period)
+	}
+	if limit > maxKlineLimit {
+		limit = maxKlineLimit
+	}
+	query := url.Values{}
+	query.Set("symbol", toSymbol(pair))
+	query.Set("period", periodS)
+	if limit > 0 {
+		query.Set("size", strconv.Itoa(limit))
+	}
+	resp, err := h.request(ctx, http.MethodGet, KlineURL, query, nil, false)
+	if err != nil {
+		Logger.Error("Huobi Service Get Klines Failed", zap.Error(err))
+		return nil, err
+	}
+	var rows []*kline
+	if err := json.Unmarshal(resp.Data, &rows); err != nil {
+		return nil, err
+	}
+	// 接口按时间倒序返回
+	klines := make([]*quant.Kline, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		openTime := rows[i].ID * 1000
+		if (startTime > 0 && openTime < startTime) || (endTime > 0 && openTime > endTime) {
+			continue
+		}
+		klines = append(klines, rows[i].toKline(pair))
+	}
+	return klines, nil
+}
+
+/*
+	fieldParser 逐个解析字段并记录所有格式错误的字段
+	字符串形式的数值为空时表示没有值(如未成交订单的 field-fees)，解析为0
+*/
+type fieldParser struct {
+	source string
+	fields []string
+}
+
+func (p *fieldParser) fail(field string, err error) *fieldParser {
+	p.fields = append(p.fields, field+": "+err.Error())
+	return p
+}
+
+func (p *fieldParser) err() error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	Logger.Error("Huobi Service Parse Failed", zap.String("source", p.source), zap.Strings("fields", p.fields))
+	return &ParseError{Source: p.source, Fields: p.fields}
+}
+
+func (p *fieldParser) decimal(field, v string) decimal.Decimal {
+	if v == "" {
+		return decimal.Zero
+	}
+	d, err := util.ParseDecimal(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return d
+}
@@ -0,0 +1,308 @@
+package huobi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+var _ quant.Stream = (*HuobiWs)(nil)
+
+var (
+	// ErrCallbackNotSet 订阅前未注册对应的回调
+	ErrCallbackNotSet = errors.New("callback not set, register it before subscribing")
+	// ErrClosed 建立连接期间调用了 Close
+	ErrClosed = errors.New("ws closed while connecting")
+)
+
+/*
+	HuobiWs huobi行情推送
+	所有订阅共用一条连接，服务端推送的消息经过gzip压缩，需要回复 pong 保持连接
+*/
+type HuobiWs struct {
+	baseURL        string
+	proxyUrl       string
+	tickerCallback func(*quant.Ticker)
+	depthCallback  func(*quant.Depth)
+	tradeCallback  func(*quant.Trade)
+	klineCallback  func(*quant.Kline, int)
+
+	mu       sync.Mutex
+	conn     *util.WsConn
+	handlers map[string]func(json.RawMessage) error
+	closeGen int // 每次 Close 递增，丢弃 Close 之前开始建立的连接
+}
+
+func NewHuobiWs(baseURL, proxyURL string) *HuobiWs {
+	if baseURL == "" {
+		baseURL = defaultWebSocketURL
+	}
+	return &HuobiWs{
+		baseURL:  baseURL,
+		proxyUrl: proxyURL,
+		handlers: make(map[string]func(json.RawMessage) error),
+	}
+}
+
+func (hw *HuobiWs) SetTickerCallback(callback func(*quant.Ticker)) {
+	hw.tickerCallback = callback
+}
+
+func (hw *HuobiWs) SetDepthCallback(callback func(*quant.Depth)) {
+	hw.depthCallback = callback
+}
+
+func (hw *HuobiWs) SetTradeCallback(callback func(*quant.Trade)) {
+	hw.tradeCallback = callback
+}
+
+// SetKlineCallback 回调参数为k线及其周期 KLINE_PERIOD_*
+func (hw *HuobiWs) SetKlineCallback(callback func(*quant.Kline, int)) {
+	hw.klineCallback = callback
+}
+
+// wsMessage 推送消息，ping 为心跳，ch 为数据推送，其余为订阅结果
+type wsMessage struct {
+	Ping    int64           `json:"ping"`
+	Ch      string          `json:"ch"`
+	Ts      int64           `json:"ts"`
+	Tick    json.RawMessage `json:"tick"`
+	Status  string          `json:"status"`
+	Subbed  string          `json:"subbed"`
+	ErrCode string          `json:"err-code"`
+	ErrMsg  string          `json:"err-msg"`
+}
+
+/*
+	subscribe 订阅频道 ch，handle 接收 tick 字段
+	首次订阅时建立连接，订阅消息在重连后自动重发
+*/
+func (hw *HuobiWs) subscribe(ch string, handle func(json.RawMessage) error) error {
+	conn, err := hw.connect()
+	if err != nil {
+		Logger.Error("[ws] subscribe failed ", zap.String("ch", ch), zap.Error(err))
+		return err
+	}
+	hw.mu.Lock()
+	hw.handlers[ch] = handle
+	hw.mu.Unlock()
+	return conn.Subscribe(map[string]string{"sub": ch, "id": ch})
+}
+
+/*
+	connect 返回共用的连接，没有连接时新建
+	建立连接时不持有锁，避免阻塞心跳回复与消息分发；并发建立的连接只保留先完成的一条
+*/
+func (hw *HuobiWs) connect() (*util.WsConn, error) {
+	hw.mu.Lock()
+	conn, gen := hw.conn, hw.closeGen
+	hw.mu.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+
+	conn = util.NewWsConn(hw.baseURL, hw.proxyUrl, hw.handle)
+	// huobi由服务端发送ping，客户端不需要主动ping
+	conn.SetPingInterval(0)
+	if err := conn.NewWebsocket(); err != nil {
+		return nil, err
+	}
+
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	switch {
+	case hw.closeGen != gen:
+		conn.Close()
+		return nil, ErrClosed
+	case hw.conn != nil:
+		conn.Close()
+		return hw.conn, nil
+	}
+	hw.conn = conn
+	return conn, nil
+}
+
+func (hw *HuobiWs) handle(msg []byte) error {
+	reader, err := gzip.NewReader(bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	message := new(wsMessage)
+	if err := json.Unmarshal(data, message); err != nil {
+		Logger.Error("json unmarshal error for ", zap.ByteString("msg", data), zap.Error(err))
+		return err
+	}
+	if message.Ping != 0 {
+		hw.mu.Lock()
+		conn := hw.conn
+		hw.mu.Unlock()
+		if conn == nil {
+			return nil
+		}
+		return conn.SendMessage(map[string]int64{"pong": message.Ping})
+	}
+	if message.Ch == "" {
+		if message.Status != "" && message.Status != statusOK {
+			Logger.Error("[ws] subscribe failed ", zap.String("code", message.ErrCode), zap.String("msg", message.ErrMsg))
+		}
+		return nil
+	}
+	hw.mu.Lock()
+	handle, ok := hw.handlers[message.Ch]
+	hw.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return handle(message.Tick)
+}
+
+/*
+	订阅行情
+*/
+func (hw *HuobiWs) SubscribeTicker(pair quant.CurrencyPair) error {
+	if hw.tickerCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
+	ch := fmt.Sprintf("market.%s.ticker", symbol)
+	return hw.subscribe(ch, func(msg json.RawMessage) error {
+		tick := struct {
+			High      decimal.Decimal `json:"high"`
+			Low       decimal.Decimal `json:"low"`
+			Amount    decimal.Decimal `json:"amount"`
+			Bid       decimal.Decimal `json:"bid"`
+			Ask       decimal.Decimal `json:"ask"`
+			LastPrice decimal.Decimal `json:"lastPrice"`
+		}{}
+		if err := json.Unmarshal(msg, &tick); err != nil {
+			return err
+		}
+		hw.tickerCallback(&quant.Ticker{
+			Pair:   pair,
+			Symbol: symbol,
+			Last:   tick.LastPrice,
+			Buy:    tick.Bid,
+			Sell:   tick.Ask,
+			High:   tick.High,
+			Low:    tick.Low,
+			Vol:    tick.Amount,
+			Date:   uint64(time.Now().UnixNano() / int64(time.Millisecond)),
+		})
+		return nil
+	})
+}
+
+/*
+	订阅深度
+	size 为 5, 10, 20 时订阅全量刷新的 mbp.refresh 频道，其他值订阅150档后截取前size档
+*/
+func (hw *HuobiWs) SubscribeDepth(pair quant.CurrencyPair, size int) error {
+	if hw.depthCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
+	ch := fmt.Sprintf("market.%s.depth.step0", symbol)
+	if size == 5 || size == 10 || size == 20 {
+		ch = fmt.Sprintf("market.%s.mbp.refresh.%d", symbol, size)
+	}
+	return hw.subscribe(ch, func(msg json.RawMessage) error {
+		depth, err := parseDepth(msg, size)
+		if err != nil {
+			return err
+		}
+		depth.Pair, depth.Symbol = pair, symbol
+		hw.depthCallback(depth)
+		return nil
+	})
+}
+
+/*
+	订阅逐笔成交，每条推送可能包含多笔成交
+*/
+func (hw *HuobiWs) SubscribeTrade(pair quant.CurrencyPair) error {
+	if hw.tradeCallback == nil {
+		return ErrCallbackNotSet
+	}
+	symbol := toSymbol(pair)
+	ch := fmt.Sprintf("market.%s.trade.detail", symbol)
+	return hw.subscribe(ch, func(msg json.RawMessage) error {
+		tick := struct {
+			Data []struct {
+				TradeID   int64           `json:"tradeId"`
+				Ts        int64           `json:"ts"`
+				Amount    decimal.Decimal `json:"amount"`
+				Price     decimal.Decimal `json:"price"`
+				Direction string          `json:"direction"` // 主动成交方向
+			} `json:"data"`
+		}{}
+		if err := json.Unmarshal(msg, &tick); err != nil {
+			return err
+		}
+		for _, t := range tick.Data {
+			side := quant.BUY
+			if t.Direction == "sell" {
+				side = quant.SELL
+			}
+			hw.tradeCallback(&quant.Trade{
+				Tid:    t.TradeID,
+				Type:   side,
+				Amount: t.Amount,
+				Price:  t.Price,
+				Date:   t.Ts,
+				Symbol: symbol,
+				Pair:   pair,
+			})
+		}
+		return nil
+	})
+}
+
+/*
+	订阅k线
+*/
+func (hw *HuobiWs) SubscribeKline(pair quant.CurrencyPair, period int) error {
+	if hw.klineCallback == nil {
+		return ErrCallbackNotSet
+	}
+	periodS, ok := _INERNAL_KLINE_PERIOD_CONVERTER[period]
+	if !ok {
+		return fmt.Errorf("unsupported kline period %d", period)
+	}
+	symbol := toSymbol(pair)
+	ch := fmt.Sprintf("market.%s.kline.%s", symbol, periodS)
+	return hw.subscribe(ch, func(msg json.RawMessage) error {
+		k := new(kline)
+		if err := json.Unmarshal(msg, k); err != nil {
+			return err
+		}
+		hw.klineCallback(k.toKline(pair), period)
+		return nil
+	})
+}
+
+// Close 关闭连接
+func (hw *HuobiWs) Close() {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	if hw.conn != nil {
+		hw.conn.Close()
+		hw.conn = nil
+	}
+	hw.closeGen++
+	hw.handlers = make(map[string]func(json.RawMessage) error)
+}
@@ -0,0 +1,130 @@
+package huobi_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/huobi"
+
+	"github.com/gorilla/websocket"
+)
+
+func gzipMessage(t *testing.T, msg string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(msg))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestHuobiWsTrade(t *testing.T) {
+	pongs := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_, sub, err := c.ReadMessage()
+		if err != nil || !strings.Contains(string(sub), `"sub":"market.btcusdt.trade.detail"`) {
+			t.Errorf("unexpected subscription %s %v", sub, err)
+			return
+		}
+		c.WriteMessage(websocket.BinaryMessage, gzipMessage(t, `{"ping":1492420473027}`))
+		_, pong, _ := c.ReadMessage()
+		pongs <- string(pong)
+		c.WriteMessage(websocket.BinaryMessage, gzipMessage(t, `{"ch":"market.btcusdt.trade.detail","ts":1630994963175,
+			"tick":{"id":137005445109,"ts":1630994963173,"data":[{"id":1.3700544510943e+22,"ts":1630994963173,
+			"tradeId":102523573486,"amount":0.006754,"price":52648.62,"direction":"sell"}]}}`))
+		c.ReadMessage()
+	}))
+	defer srv.Close()
+
+	trades := make(chan *quant.Trade, 1)
+	hw := huobi.NewHuobiWs("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	defer hw.Close()
+	hw.SetTradeCallback(func(trade *quant.Trade) {
+		trades <- trade
+	})
+	if err := hw.SubscribeTrade(quant.BTC_USDT); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case pong := <-pongs:
+		if pong != `{"pong":1492420473027}` {
+			t.Fatalf("unexpected pong %s", pong)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pong received")
+	}
+	select {
+	case trade := <-trades:
+		if trade.Tid != 102523573486 || trade.Type != quant.SELL || trade.Price.String() != "52648.62" || trade.Symbol != "btcusdt" || !trade.Pair.Equal(quant.BTC_USDT) {
+			t.Fatalf("unexpected trade %+v", trade)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no trade received")
+	}
+}
+
+func TestHuobiWsCloseWhileConnecting(t *testing.T) {
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(dialing)
+		<-release
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	var releaseOnce sync.Once
+	defer releaseOnce.Do(func() { close(release) })
+
+	hw := huobi.NewHuobiWs("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	hw.SetTradeCallback(func(*quant.Trade) {})
+	result := make(chan error, 1)
+	go func() {
+		result <- hw.SubscribeTrade(quant.BTC_USDT)
+	}()
+	<-dialing
+
+	// 建立连接时不持有锁，Close 不会被阻塞
+	closed := make(chan struct{})
+	go func() {
+		hw.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked while a connection was dialing")
+	}
+	releaseOnce.Do(func() { close(release) })
+	select {
+	case err := <-result:
+		if err != huobi.ErrClosed {
+			t.Fatalf("expected ErrClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscribe not finished")
+	}
+}
@@ -0,0 +1,274 @@
+package huobi_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/huobi"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	testAccessKey = "ak"
+	testSecretKey = "sk"
+)
+
+func init() {
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+}
+
+// verifySignature 按huobi的规则重新计算签名
+func verifySignature(r *http.Request) bool {
+	query := r.URL.Query()
+	signature := query.Get("Signature")
+	query.Del("Signature")
+	if query.Get("AccessKeyId") != testAccessKey {
+		return false
+	}
+	payload := strings.Join([]string{r.Method, strings.ToLower(r.Host), r.URL.Path, query.Encode()}, "\n")
+	mac := hmac.New(sha256.New, []byte(testSecretKey))
+	mac.Write([]byte(payload))
+	return signature == base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/") && !strings.HasPrefix(r.URL.Path, "/v1/common/") && !verifySignature(r) {
+			w.Write([]byte(`{"status":"error","err-code":"api-signature-not-valid","err-msg":"Signature not valid"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/account/accounts":
+			w.Write([]byte(`{"status":"ok","data":[{"id":1,"type":"otc","state":"working"},{"id":100,"type":"spot","state":"working"}]}`))
+		case "/v1/account/accounts/100/balance":
+			w.Write([]byte(`{"status":"ok","data":{"id":100,"type":"spot","state":"working","list":[
+				{"currency":"usdt","type":"trade","balance":"91.5"},
+				{"currency":"usdt","type":"frozen","balance":"8.5"},
+				{"currency":"btc","type":"trade","balance":"0.1"}]}}`))
+		case "/v1/order/orders/place":
+			params := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&params)
+			if params["account-id"] != "100" || params["type"] != "buy-limit" || params["price"] != "9000" ||
+				params["client-order-id"] != quant.ClientOrderID("grid", "level1") {
+				t.Errorf("unexpected order params %v", params)
+			}
+			w.Write([]byte(`{"status":"ok","data":"59378"}`))
+		case "/v1/order/orders/59378":
+			w.Write([]byte(`{"status":"ok","data":{"id":59378,"symbol":"btcusdt","account-id":100,"amount":"0.2",
+				"price":"9000","created-at":1494901162595,"type":"buy-limit","field-amount":"0.1",
+				"field-cash-amount":"900","field-fees":"0.0002","state":"partial-filled"}}`))
+		case "/v1/order/orders/500":
+			w.Write([]byte(`{"status":"ok","data":{"id":500,"symbol":"btcusdt","account-id":100,"amount":"0.2",
+				"price":"9000","created-at":1494901162595,"type":"buy-limit","field-amount":"0.1",
+				"field-cash-amount":"900","field-fees":"NaN?","state":"partial-filled"}}`))
+		case "/v1/order/orders/404":
+			w.Write([]byte(`{"status":"error","err-code":"base-record-invalid","err-msg":"record invalid"}`))
+		case "/market/detail/merged":
+			w.Write([]byte(`{"status":"ok","ch":"market.btcusdt.detail.merged","ts":1629788763750,"tick":{
+				"close":9050.5,"high":9100,"low":8900,"amount":1234.5,"vol":11172000,"bid":[9050,1.2],"ask":[9051,0.8]}}`))
+		case "/v1/common/symbols":
+			w.Write([]byte(`{"status":"ok","data":[
+				{"base-currency":"btc","quote-currency":"usdt","price-precision":2,"amount-precision":6,"symbol":"btcusdt","state":"online"},
+				{"base-currency":"abc","quote-currency":"btc","price-precision":8,"amount-precision":2,"symbol":"abcbtc","state":"offline"}]}`))
+		case "/market/history/kline":
+			w.Write([]byte(`{"status":"ok","data":[
+				{"id":1629788820,"open":2,"close":3,"low":1,"high":4,"amount":10,"vol":30,"count":5},
+				{"id":1629788760,"open":1,"close":2,"low":1,"high":2,"amount":20,"vol":40,"count":6}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestHuobiOrders(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	var exchange quant.Exchange = huobi.NewHuobi(testAccessKey, testSecretKey).SetBaseURL(srv.URL)
+	ctx := context.Background()
+
+	order, err := exchange.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.BUY, Price: decimal.RequireFromString("9000"), Amount: decimal.RequireFromString("0.2"),
+		Strategy: "grid", Intent: "level1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.OrderID2 != "59378" || order.Symbol != "btcusdt" || !order.Pair.Equal(quant.BTC_USDT) || order.Status != quant.ORDER_NEW ||
+		order.ClientOrderID != quant.ClientOrderID("grid", "level1") {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	order, err = exchange.GetOrder(ctx, quant.BTC_USDT, order.OrderID2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != quant.ORDER_PARTIALLY_FILLED || order.DealAmount.String() != "0.1" || order.AvgPrice.String() != "9000" ||
+		order.Side != quant.BUY || order.Type != quant.ORDER_TYPE_LIMIT {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	_, err = exchange.GetOrder(ctx, quant.BTC_USDT, "500")
+	parseErr, ok := err.(*huobi.ParseError)
+	if !ok || parseErr.Source != "order" || len(parseErr.Fields) != 1 || !strings.HasPrefix(parseErr.Fields[0], "filled-fees:") {
+		t.Fatalf("expected fee parse error, got %v", err)
+	}
+
+	_, err = exchange.GetOrder(ctx, quant.BTC_USDT, "404")
+	apiErr, ok := huobi.AsAPIError(err)
+	if !ok || !apiErr.IsOrderNotFound() {
+		t.Fatalf("expected order not found, got %v", err)
+	}
+}
+
+func TestHuobiMarketAndBalances(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	h := huobi.NewHuobi(testAccessKey, testSecretKey).SetBaseURL(srv.URL)
+	ctx := context.Background()
+
+	ticker, err := h.GetTicker(ctx, quant.BTC_USDT)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ticker.Last.String() != "9050.5" || ticker.Buy.String() != "9050" || ticker.Sell.String() != "9051" || ticker.Date != 1629788763750 {
+		t.Fatalf("unexpected ticker %+v", ticker)
+	}
+
+	klines, err := h.GetKlines(ctx, quant.BTC_USDT, quant.KLINE_PERIOD_1MIN, 0, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 2 || klines[0].OpenTime != 1629788760000 || klines[1].Close.String() != "3" {
+		t.Fatalf("klines not in ascending order: %+v %+v", klines[0], klines[1])
+	}
+
+	pairs, err := h.GetCurrencyPairs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 1 || !pairs[0].Equal(quant.BTC_USDT) || pairs[0].PricePrecision != 2 || pairs[0].AmountPrecision != 6 {
+		t.Fatalf("unexpected pairs %+v", pairs)
+	}
+
+	balances, err := h.GetBalances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 2 || balances[0].Asset != "USDT" || balances[0].Free.String() != "91.5" || balances[0].Locked.String() != "8.5" {
+		t.Fatalf("unexpected balances %+v", balances)
+	}
+}
+
+func TestHuobiInvalidSignature(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	h := huobi.NewHuobi(testAccessKey, "wrong").SetBaseURL(srv.URL)
+	_, err := h.GetAccounts(context.Background())
+	apiErr, ok := huobi.AsAPIError(err)
+	if !ok || !apiErr.IsInvalidSignature() {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestHuobiHttpClientConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer srv.Close()
+	// [http.huobi] 的超时覆盖默认的10秒
+	viper.Set("http.huobi.Timeout", "50ms")
+	defer viper.Set("http.huobi.Timeout", "10s")
+	h := huobi.NewHuobi(testAccessKey, testSecretKey).SetBaseURL(srv.URL)
+	start := time.Now()
+	if _, err := h.GetTicker(context.Background(), quant.BTC_USDT); err == nil {
+		t.Fatal("expected timeout")
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Fatalf("configured timeout not applied, took %s", elapsed)
+	}
+}
+
+func TestHuobiPlaceOrderOptions(t *testing.T) {
+	var placed []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/account/accounts":
+			w.Write([]byte(`{"status":"ok","data":[{"id":100,"type":"spot","state":"working"}]}`))
+		case "/v1/order/orders/place":
+			params := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&params)
+			placed = append(placed, params)
+			w.Write([]byte(`{"status":"ok","data":"59378"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	h := huobi.NewHuobi(testAccessKey, testSecretKey).SetBaseURL(srv.URL)
+	ctx := context.Background()
+	price, amount := decimal.RequireFromString("9000"), decimal.RequireFromString("0.2")
+
+	// 不支持的参数直接返回错误，不发送请求
+	rejected := []*quant.OrderRequest{
+		{Side: quant.SELL, Price: price, Amount: amount, StopPrice: price},
+		{Side: quant.SELL, Price: price, Amount: amount, IcebergAmount: amount},
+		{Side: quant.SELL, Price: price, Amount: amount, ResponseType: "FULL"},
+		{Side: quant.SELL, Price: price, Amount: amount, TimeInForce: "GTX"},
+		{Side: quant.BUY_MARKET, QuoteAmount: amount, TimeInForce: quant.TIME_IN_FORCE_IOC},
+		// 市价买单必须使用 QuoteAmount，Amount 在其他交易所为基础货币数量
+		{Side: quant.BUY_MARKET, Amount: amount},
+		{Side: quant.BUY_MARKET, Amount: amount, QuoteAmount: amount},
+		{Side: quant.BUY_MARKET},
+		{Side: quant.SELL_MARKET, QuoteAmount: amount},
+		{Side: quant.BUY, Price: price, QuoteAmount: amount},
+		{Side: quant.BUY, Type: quant.ORDER_TYPE_STOP_LOSS_LIMIT, Price: price, Amount: amount, StopPrice: price},
+	}
+	for i, req := range rejected {
+		req.Pair = quant.BTC_USDT
+		if _, err := h.PlaceOrder(ctx, req); err == nil {
+			t.Errorf("request %d: expected error", i)
+		}
+	}
+	if len(placed) != 0 {
+		t.Fatalf("rejected requests were sent %v", placed)
+	}
+
+	if _, err := h.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.SELL, Price: price, Amount: amount, TimeInForce: quant.TIME_IN_FORCE_FOK}); err != nil {
+		t.Fatal(err)
+	}
+	if placed[0]["type"] != "sell-limit-fok" || placed[0]["price"] != "9000" {
+		t.Fatalf("unexpected fok params %v", placed[0])
+	}
+	if _, err := h.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.BUY, Price: price, Amount: amount, TimeInForce: quant.TIME_IN_FORCE_IOC}); err != nil {
+		t.Fatal(err)
+	}
+	if placed[1]["type"] != "buy-ioc" {
+		t.Fatalf("unexpected ioc params %v", placed[1])
+	}
+	order, err := h.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.BUY_MARKET, QuoteAmount: decimal.RequireFromString("100")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed[2]["type"] != "buy-market" || placed[2]["amount"] != "100" || !order.Amount.Equal(decimal.RequireFromString("100")) {
+		t.Fatalf("unexpected quote amount params %v order %+v", placed[2], order)
+	}
+	// 市价卖单的 Amount 为基础货币数量
+	if _, err := h.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.SELL_MARKET, Amount: amount}); err != nil {
+		t.Fatal(err)
+	}
+	if placed[3]["type"] != "sell-market" || placed[3]["amount"] != "0.2" {
+		t.Fatalf("unexpected market sell params %v", placed[3])
+	}
+}
@@ -0,0 +1,231 @@
+package quant
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type TradeSide int
+
+func (ts TradeSide) String() string {
+	switch ts {
+	case 1:
+		return "BUY"
+	case 2:
+		return "SELL"
+	case 3:
+		return "BUY_MARKET"
+	case 4:
+		return "SELL_MARKET"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+const (
+	BUY TradeSide = 1 + iota
+	SELL
+	BUY_MARKET
+	SELL_MARKET
+)
+
+type TradeStatus int
+
+func (ts TradeStatus) String() string {
+	switch ts {
+	case ORDER_NEW:
+		return "NEW"
+	case ORDER_PARTIALLY_FILLED:
+		return "PARTIALLY_FILLED"
+	case ORDER_FILLED:
+		return "FILLED"
+	case ORDER_CANCELED:
+		return "CANCELED"
+	case ORDER_PENDING_CANCEL:
+		return "PENDING_CANCEL"
+	case ORDER_REJECT:
+		return "REJECTED"
+	case ORDER_EXPIRED:
+		return "EXPIRED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+const (
+	ORDER_NEW              TradeStatus = iota //新建订单
+	ORDER_PARTIALLY_FILLED                    //部分成交
+	ORDER_FILLED                              //全部成交
+	ORDER_CANCELED                            // 已撤销
+	ORDER_PENDING_CANCEL                      //撤销中
+	ORDER_REJECT                              //订单被拒绝
+	ORDER_EXPIRED                             //订单过期
+)
+
+//k线周期
+const (
+	KLINE_PERIOD_1MIN = 1 + iota
+	KLINE_PERIOD_3MIN
+	KLINE_PERIOD_5MIN
+	KLINE_PERIOD_15MIN
+	KLINE_PERIOD_30MIN
+	KLINE_PERIOD_60MIN
+	KLINE_PERIOD_1H
+	KLINE_PERIOD_2H
+	KLINE_PERIOD_3H
+	KLINE_PERIOD_4H
+	KLINE_PERIOD_6H
+	KLINE_PERIOD_8H
+	KLINE_PERIOD_12H
+	KLINE_PERIOD_1DAY
+	KLINE_PERIOD_3DAY
+	KLINE_PERIOD_1WEEK
+	KLINE_PERIOD_1MONTH
+	KLINE_PERIOD_1YEAR
+)
+
+// 订单类型，止损止盈与只做maker并非所有交易所都支持
+const (
+	ORDER_TYPE_LIMIT             = "LIMIT"
+	ORDER_TYPE_MARKET            = "MARKET"
+	ORDER_TYPE_LIMIT_MAKER       = "LIMIT_MAKER"       // 只做maker，会立即成交时被拒绝
+	ORDER_TYPE_STOP_LOSS         = "STOP_LOSS"         // 触发 StopPrice 后市价止损
+	ORDER_TYPE_STOP_LOSS_LIMIT   = "STOP_LOSS_LIMIT"   // 触发 StopPrice 后限价止损
+	ORDER_TYPE_TAKE_PROFIT       = "TAKE_PROFIT"       // 触发 StopPrice 后市价止盈
+	ORDER_TYPE_TAKE_PROFIT_LIMIT = "TAKE_PROFIT_LIMIT" // 触发 StopPrice 后限价止盈
+)
+
+// 订单有效方式
+const (
+	TIME_IN_FORCE_GTC = "GTC" // 成交或撤销前一直有效
+	TIME_IN_FORCE_IOC = "IOC" // 立即成交，未成交部分撤销
+	TIME_IN_FORCE_FOK = "FOK" // 全部成交，否则全部撤销
+)
+
+/*
+	行情数据与订单中的 Symbol 为交易所原生名称，Pair 为对应的通用交易对
+	价格与数量统一使用 decimal.Decimal，按交易所返回的字符串精确解析，避免浮点误差
+*/
+type Ticker struct {
+	Pair   CurrencyPair    `json:"-"`
+	Symbol string          `json:"symbol,omitempty"`
+	Last   decimal.Decimal `json:"last"`
+	Buy    decimal.Decimal `json:"buy"`
+	Sell   decimal.Decimal `json:"sell"`
+	High   decimal.Decimal `json:"high"`
+	Low    decimal.Decimal `json:"low"`
+	Vol    decimal.Decimal `json:"vol"`
+	Date   uint64          `json:"date"` // 单位:ms
+}
+
+type Trade struct {
+	Pair   CurrencyPair    `json:"-"`
+	Tid    int64           `json:"tid"`
+	Type   TradeSide       `json:"type"`
+	Amount decimal.Decimal `json:"amount"`
+	Price  decimal.Decimal `json:"price"`
+	Date   int64           `json:"date_ms"`
+	Symbol string          `json:"symbol,omitempty"`
+}
+
+type Depth struct {
+	//ContractType string //for future
+	Pair    CurrencyPair
+	Symbol  string
+	UTime   time.Time
+	AskList DepthRecords // Ascending order, best ask first
+	BidList DepthRecords // Descending order, best bid first
+}
+
+type DepthRecord struct {
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+}
+
+type DepthRecords []DepthRecord
+
+type Kline struct {
+	Pair             CurrencyPair
+	Symbol           string
+	Timestamp        int64 // 开盘时间，单位:s
+	Open             decimal.Decimal
+	Close            decimal.Decimal
+	High             decimal.Decimal
+	Low              decimal.Decimal
+	Vol              decimal.Decimal
+	OpenTime         int64           // 开盘时间，单位:ms
+	CloseTime        int64           // 收盘时间，单位:ms
+	QuoteVol         decimal.Decimal // 成交额
+	TradeCount       int64           // 成交笔数
+	TakerBuyVol      decimal.Decimal // 主动买入成交量
+	TakerBuyQuoteVol decimal.Decimal // 主动买入成交额
+}
+
+type Order struct {
+	Pair       CurrencyPair
+	Symbol     string
+	OrderID    int
+	OrderID2   string // 字符串形式的订单号，用于订单号不是整数的交易所
+	OrderType  int    //0:default,1:maker,2:fok,3:ioc
+	Side       TradeSide
+	AvgPrice   decimal.Decimal
+	Type       string // limit / market
+	Fee        decimal.Decimal
+	Price      decimal.Decimal
+	DealAmount decimal.Decimal
+	Amount     decimal.Decimal
+	Status     TradeStatus
+	OrderTime  int
+
+	ClientOrderID string // 客户端订单号，由 ClientOrderID 生成时带有策略前缀
+	Fills         []Fill // 下单时的成交明细，仅 binance FULL 响应返回
+}
+
+// Fill 订单的一笔成交
+type Fill struct {
+	TradeID         int64
+	Price           decimal.Decimal
+	Amount          decimal.Decimal
+	Commission      decimal.Decimal
+	CommissionAsset string
+}
+
+// Balance 单个资产的余额
+type Balance struct {
+	Asset  string          `json:"asset"`
+	Free   decimal.Decimal `json:"free"`   //可用
+	Locked decimal.Decimal `json:"locked"` //冻结
+}
+
+/*
+	OrderRequest 下单请求
+	Side : BUY / SELL
+	Type : ORDER_TYPE_*，为空时为限价单
+	Price : 市价单与 STOP_LOSS / TAKE_PROFIT 忽略
+	Amount : 基础货币数量
+	QuoteAmount : 市价单按计价货币金额下单，设置后忽略 Amount
+	StopPrice : 止损止盈单的触发价
+	IcebergAmount : 冰山单每次显示的数量，仅限价类订单
+	TimeInForce : TIME_IN_FORCE_*，限价单为空时为GTC
+	ResponseType : 下单响应类型 ACK / RESULT / FULL，仅 binance 支持，FULL 返回成交明细
+	Strategy : 策略名，作为客户端订单号的前缀
+	Intent : 下单意图的唯一标识，如 "grid:level3:round12"，相同意图生成相同的客户端订单号
+	ClientOrderID : 指定客户端订单号，为空时由 ResolveClientOrderID 生成
+	交易所不支持的参数在下单时返回错误，不会被忽略
+*/
+type OrderRequest struct {
+	Pair          CurrencyPair
+	Side          TradeSide
+	Type          string
+	Price         decimal.Decimal
+	Amount        decimal.Decimal
+	QuoteAmount   decimal.Decimal
+	StopPrice     decimal.Decimal
+	IcebergAmount decimal.Decimal
+	TimeInForce   string
+	ResponseType  string
+	Strategy      string
+	Intent        string
+	ClientOrderID string
+}
@@ -0,0 +1,62 @@
+package okex
+
+import "tinyquant/src/quant"
+
+const (
+	defaultBaseURL     = "https://www.okx.com"
+	defaultPublicWsURL = "wss://ws.okx.com:8443/ws/v5/public"
+	// k线频道在business连接上推送
+	defaultBusinessWsURL = "wss://ws.okx.com:8443/ws/v5/business"
+	defaultPrivateWsURL  = "wss://ws.okx.com:8443/ws/v5/private"
+)
+
+const (
+	InstrumentsURL  = "/api/v5/public/instruments"
+	TickerURL       = "/api/v5/market/ticker"
+	BooksURL        = "/api/v5/market/books"
+	CandlesURL      = "/api/v5/market/candles"
+	BalanceURL      = "/api/v5/account/balance"
+	OrderURL        = "/api/v5/trade/order"
+	CancelOrderURL  = "/api/v5/trade/cancel-order"
+	PendingOrderURL = "/api/v5/trade/orders-pending"
+	// websocket登录签名使用的路径
+	wsLoginPath = "/users/self/verify"
+)
+
+const (
+	codeOK              = "0"
+	instTypeSpot        = "SPOT"
+	tradeModeCash       = "cash"      // 现货非保证金模式
+	targetCurrencyBase  = "base_ccy"  // 市价单 sz 的单位为基础货币
+	targetCurrencyQuote = "quote_ccy" // 市价单 sz 的单位为计价货币
+	maxCandleLimit      = 300
+	maxBooksSize        = 400
+	books5Size          = 5
+	timestampLayout     = "2006-01-02T15:04:05.000Z"
+)
+
+var _INERNAL_ORDER_STATUS_CONVERTER = map[string]quant.TradeStatus{
+	"live":             quant.ORDER_NEW,
+	"partially_filled": quant.ORDER_PARTIALLY_FILLED,
+	"filled":           quant.ORDER_FILLED,
+	"canceled":         quant.ORDER_CANCELED,
+	"mmp_canceled":     quant.ORDER_CANCELED,
+}
+
+var _INERNAL_KLINE_PERIOD_CONVERTER = map[int]string{
+	quant.KLINE_PERIOD_1MIN:   "1m",
+	quant.KLINE_PERIOD_3MIN:   "3m",
+	quant.KLINE_PERIOD_5MIN:   "5m",
+	quant.KLINE_PERIOD_15MIN:  "15m",
+	quant.KLINE_PERIOD_30MIN:  "30m",
+	quant.KLINE_PERIOD_60MIN:  "1H",
+	quant.KLINE_PERIOD_1H:     "1H",
+	quant.KLINE_PERIOD_2H:     "2H",
+	quant.KLINE_PERIOD_4H:     "4H",
+	quant.KLINE_PERIOD_6H:     "6H",
+	quant.KLINE_PERIOD_12H:    "12H",
+	quant.KLINE_PERIOD_1DAY:   "1D",
+	quant.KLINE_PERIOD_3DAY:   "3D",
+	quant.KLINE_PERIOD_1WEEK:  "1W",
+	quant.KLINE_PERIOD_1MONTH: "1M",
+}
@@ -0,0 +1,73 @@
+package okex
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// okex error codes
+const (
+	ERR_RATE_LIMITED         = "50011"
+	ERR_TIMESTAMP_EXPIRED    = "50102"
+	ERR_INVALID_SIGNATURE    = "50113"
+	ERR_INSUFFICIENT_BALANCE = "51008"
+	ERR_CANCEL_FAILED        = "51400"
+	ERR_ORDER_NOT_FOUND      = "51603"
+)
+
+/*
+	APIError okex接口返回的错误
+	批量类接口(下单、撤单)的整体code为0时，单个订单的错误码 sCode 同样以 APIError 返回
+*/
+type APIError struct {
+	StatusCode int    // HTTP状态码
+	Code       string // okex错误码
+	Msg        string // 错误信息
+	Path       string // 请求路径
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("okex api error: status=%d code=%s msg=%s path=%s", e.StatusCode, e.Code, e.Msg, e.Path)
+}
+
+// IsRateLimited 请求频率超限
+func (e *APIError) IsRateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.Code == ERR_RATE_LIMITED
+}
+
+// IsInvalidSignature 签名错误
+func (e *APIError) IsInvalidSignature() bool {
+	return e.Code == ERR_INVALID_SIGNATURE
+}
+
+// IsTimestampOutOfWindow 请求时间戳过期
+func (e *APIError) IsTimestampOutOfWindow() bool {
+	return e.Code == ERR_TIMESTAMP_EXPIRED
+}
+
+// IsInsufficientBalance 下单时余额不足
+func (e *APIError) IsInsufficientBalance() bool {
+	return e.Code == ERR_INSUFFICIENT_BALANCE
+}
+
+// IsOrderNotFound 订单不存在
+func (e *APIError) IsOrderNotFound() bool {
+	return e.Code == ERR_ORDER_NOT_FOUND || e.Code == ERR_CANCEL_FAILED
+}
+
+// ParseError 接口返回或推送的数据中存在格式错误的字段
+type ParseError struct {
+	Source string   // 数据来源，如 order、trades
+	Fields []string // 格式错误的字段及原因
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("okex malformed %s fields: %s", e.Source, strings.Join(e.Fields, "; "))
+}
+
+// AsAPIError 判断err是否为okex接口错误
+func AsAPIError(err error) (*APIError, bool) {
+	apiErr, ok := err.(*APIError)
+	return apiErr, ok
+}
@@ -1 +1,460 @@
-package quant
+package okex
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+var _ quant.Exchange = (*Okex)(nil)
+
+type Okex struct {
+	accessKey  string
+	secretKey  string
+	passphrase string
+	baseURL    string
+	httpClient *http.Client
+	simulated  bool // 模拟盘
+}
+
+func NewOkex(accessKey, secretKey, passphrase string) *Okex {
+	return &Okex{
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		passphrase: passphrase,
+		baseURL:    defaultBaseURL,
+		httpClient: newHttpClient(),
+	}
+}
+
+// newHttpClient 按 [http] / [http.okex] 配置创建 http client，配置无效时使用默认配置
+func newHttpClient() *http.Client {
+	client, err := util.NewHttpClient(util.LoadHttpClientConfig("okex"))
+	if err != nil {
+		Logger.Error("Okex Service Load Http Client Config Failed", zap.Error(err))
+		client, _ = util.NewHttpClient(util.DefaultHttpClientConfig())
+	}
+	return client
+}
+
+func (o *Okex) String() string {
+	return "okex"
+}
+
+// SetBaseURL 设置REST地址
+func (o *Okex) SetBaseURL(baseURL string) *Okex {
+	o.baseURL = strings.TrimSuffix(baseURL, "/")
+	return o
+}
+
+func (o *Okex) SetHttpClient(client *http.Client) *Okex {
+	o.httpClient = client
+	return o
+}
+
+// SetSimulated 切换模拟盘，模拟盘需要使用模拟盘的API Key
+func (o *Okex) SetSimulated(simulated bool) *Okex {
+	o.simulated = simulated
+	return o
+}
+
+// toSymbol 通用交易对转换为产品ID，如 BTC-USDT
+func toSymbol(pair quant.CurrencyPair) string {
+	return pair.ToSymbol("-")
+}
+
+// currencyPair 产品ID转换为通用交易对，无法解析时返回空交易对
+func currencyPair(instID string) quant.CurrencyPair {
+	pair, _ := quant.ParseCurrencyPair(instID)
+	return pair
+}
+
+/*
+	签名
+	待签名字符串: timestamp + METHOD + requestPath(含query) + body，HMAC SHA256后base64编码
+*/
+func sign(secretKey, timestamp, method, requestPath, body string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(timestamp + method + requestPath + body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// response okex接口的通用响应
+type response struct {
+	Code string          `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+/*
+	发送请求，code不为0时返回 *APIError，out 为 data 字段的解析目标
+	body : POST请求的JSON参数，可为nil
+	signed : 是否需要签名
+*/
+func (o *Okex) request(ctx context.Context, method, path string, query url.Values, body interface{}, signed bool, out interface{}) error {
+	requestPath := path
+	if encoded := query.Encode(); encoded != "" {
+		requestPath += "?" + encoded
+	}
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	var reader io.Reader
+	if payload != nil {
+		reader = bytes.NewReader(payload)
+	}
+	r, err := http.NewRequest(method, o.baseURL+requestPath, reader)
+	if err != nil {
+		return err
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+	if signed {
+		timestamp := time.Now().UTC().Format(timestampLayout)
+		r.Header.Set("OK-ACCESS-KEY", o.accessKey)
+		r.Header.Set("OK-ACCESS-SIGN", sign(o.secretKey, timestamp, method, requestPath, string(payload)))
+		r.Header.Set("OK-ACCESS-TIMESTAMP", timestamp)
+		r.Header.Set("OK-ACCESS-PASSPHRASE", o.passphrase)
+	}
+	if o.simulated {
+		r.Header.Set("x-simulated-trading", "1")
+	}
+	Logger.Debug("http request ", zap.String("method", method), zap.String("url", o.baseURL+requestPath))
+	res, err := o.httpClient.Do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	resp := new(response)
+	if err := json.Unmarshal(data, resp); err != nil {
+		if res.StatusCode >= http.StatusBadRequest {
+			return &APIError{StatusCode: res.StatusCode, Msg: string(data), Path: path}
+		}
+		return fmt.Errorf("decode %s response: %v", path, err)
+	}
+	if res.StatusCode >= http.StatusBadRequest || resp.Code != codeOK {
+		apiErr := &APIError{StatusCode: res.StatusCode, Code: resp.Code, Msg: resp.Msg, Path: path}
+		// 下单、撤单失败时整体code为1，具体原因在单个订单的 sCode 中
+		var acks []*orderAck
+		if json.Unmarshal(resp.Data, &acks) == nil && len(acks) > 0 && acks[0].SCode != "" && acks[0].SCode != codeOK {
+			apiErr.Code, apiErr.Msg = acks[0].SCode, acks[0].SMsg
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+/////////////////////////////*********订单**********//////////////////////////////////////
+
+// orderAck 下单、撤单的结果，sCode 不为0表示该订单失败
+type orderAck struct {
+	OrdID   string `json:"ordId"`
+	ClOrdID string `json:"clOrdId"`
+	SCode   string `json:"sCode"`
+	SMsg    string `json:"sMsg"`
+}
+
+func (o *Okex) orderAction(ctx context.Context, path string, params map[string]string) (*orderAck, error) {
+	var acks []*orderAck
+	if err := o.request(ctx, http.MethodPost, path, nil, params, true, &acks); err != nil {
+		return nil, err
+	}
+	if len(acks) == 0 {
+		return nil, errors.New("empty order response")
+	}
+	if acks[0].SCode != codeOK {
+		return nil, &APIError{StatusCode: http.StatusOK, Code: acks[0].SCode, Msg: acks[0].SMsg, Path: path}
+	}
+	return acks[0], nil
+}
+
+/*
+	下单
+	req.Pair : 交易对，如 quant.BTC_USDT
+	req.Side : BUY / SELL，BUY_MARKET / SELL_MARKET 视为市价单
+	req.ClientOrderID : 为空时由 req.ResolveClientOrderID 生成，以 toClOrdID 转换后的形式发送并返回
+	req.TimeInForce : 限价单支持 IOC / FOK
+	req.Amount : 基础货币数量，市价单以 tgtCcy=base_ccy 发送
+	req.QuoteAmount : 仅市价单，按计价货币金额下单 (tgtCcy=quote_ccy)
+*/
+func (o *Okex) PlaceOrder(ctx context.Context, req *quant.OrderRequest) (*quant.Order, error) {
+	side, orderType := quant.BUY, req.Type
+	switch req.Side {
+	case quant.BUY:
+	case quant.SELL:
+		side = quant.SELL
+	case quant.BUY_MARKET:
+		orderType = quant.ORDER_TYPE_MARKET
+	case quant.SELL_MARKET:
+		side, orderType = quant.SELL, quant.ORDER_TYPE_MARKET
+	default:
+		return nil, fmt.Errorf("invalid order side %v", req.Side)
+	}
+	if orderType == "" {
+		orderType = quant.ORDER_TYPE_LIMIT
+	}
+	if err := checkOrderRequest(orderType, req); err != nil {
+		return nil, err
+	}
+	clientOrderID, _, err := req.ResolveClientOrderID()
+	if err == nil {
+		clientOrderID, err = toClOrdID(clientOrderID)
+	}
+	if err != nil {
+		Logger.Error("Okex Service Place Order Failed", zap.Error(err))
+		return nil, err
+	}
+	instID := toSymbol(req.Pair)
+	params := map[string]string{
+		"instId":  instID,
+		"tdMode":  tradeModeCash,
+		"side":    strings.ToLower(side.String()),
+		"ordType": strings.ToLower(orderType),
+		"sz":      req.Amount.String(),
+		"clOrdId": clientOrderID,
+	}
+	switch req.TimeInForce {
+	case quant.TIME_IN_FORCE_IOC, quant.TIME_IN_FORCE_FOK:
+		params["ordType"] = strings.ToLower(req.TimeInForce)
+	}
+	// 现货市价买单的 sz 默认为计价货币金额，显式指定单位使 Amount 始终为基础货币数量
+	amount := req.Amount
+	if orderType == quant.ORDER_TYPE_MARKET {
+		params["tgtCcy"] = targetCurrencyBase
+	}
+	if req.QuoteAmount.IsPositive() {
+		amount = req.QuoteAmount
+		params["sz"] = amount.String()
+		params["tgtCcy"] = targetCurrencyQuote
+	}
+	if orderType == quant.ORDER_TYPE_LIMIT {
+		params["px"] = req.Price.String()
+	}
+	ack, err := o.orderAction(ctx, OrderURL, params)
+	if err != nil {
+		Logger.Error("Okex Service Place Order Failed", zap.Error(err))
+		return nil, err
+	}
+	return &quant.Order{
+		Pair:          req.Pair,
+		Symbol:        instID,
+		OrderID:       util.ToInt(ack.OrdID),
+		OrderID2:      ack.OrdID,
+		ClientOrderID: clientOrderID,
+		Side:          side,
+		Type:          orderType,
+		Price:         req.Price,
+		Amount:        amount,
+		Status:        quant.ORDER_NEW,
+		OrderTime:     int(time.Now().UnixNano() / int64(time.Millisecond)),
+	}, nil
+}
+
+// checkOrderRequest 检查 okex 不支持的下单参数，避免参数被静默忽略
+func checkOrderRequest(orderType string, req *quant.OrderRequest) error {
+	switch {
+	case orderType != quant.ORDER_TYPE_LIMIT && orderType != quant.ORDER_TYPE_MARKET:
+		return fmt.Errorf("unsupported order type %s", orderType)
+	case !req.StopPrice.IsZero():
+		return fmt.Errorf("stop price not supported by okex")
+	case !req.IcebergAmount.IsZero():
+		return fmt.Errorf("iceberg amount not supported by okex")
+	case req.ResponseType != "":
+		return fmt.Errorf("response type not supported by okex")
+	case req.QuoteAmount.IsNegative():
+		return fmt.Errorf("invalid quote amount %s", req.QuoteAmount)
+	case !req.QuoteAmount.IsZero() && orderType != quant.ORDER_TYPE_MARKET:
+		return fmt.Errorf("quote amount only allowed for MARKET order")
+	}
+	switch req.TimeInForce {
+	case "":
+	case quant.TIME_IN_FORCE_GTC, quant.TIME_IN_FORCE_IOC, quant.TIME_IN_FORCE_FOK:
+		if orderType != quant.ORDER_TYPE_LIMIT {
+			return fmt.Errorf("timeInForce not allowed for %s order", orderType)
+		}
+	default:
+		return fmt.Errorf("invalid timeInForce %s", req.TimeInForce)
+	}
+	return nil
+}
+
+// maxClOrdIDLen okex 的 clOrdId 为1-32位字母和数字
+const maxClOrdIDLen = 32
+
+/*
+	toClOrdID 转换为 okex 的 clOrdId
+	quant.ClientOrderID 生成的订单号去掉分隔符，并截短策略前缀使总长度不超过32位
+	其他订单号原样使用，不满足 okex 格式时返回错误
+*/
+func toClOrdID(clientOrderID string) (string, error) {
+	if strategy := quant.StrategyOfClientOrderID(clientOrderID); strategy != "" {
+		hash := clientOrderID[len(strategy)+1:]
+		if n := maxClOrdIDLen - len(hash); len(strategy) > n {
+			strategy = strategy[:n]
+		}
+		if isAlphanumeric(strategy + hash) {
+			return strategy + hash, nil
+		}
+	}
+	if len(clientOrderID) == 0 || len(clientOrderID) > maxClOrdIDLen || !isAlphanumeric(clientOrderID) {
+		return "", fmt.Errorf("invalid okex clOrdId %q", clientOrderID)
+	}
+	return clientOrderID, nil
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+	撤销订单，撤单为异步处理，返回的订单状态为撤销中
+*/
+func (o *Okex) CancelOrder(ctx context.Context, pair quant.CurrencyPair, orderID string) (*quant.Order, error) {
+	instID := toSymbol(pair)
+	ack, err := o.orderAction(ctx, CancelOrderURL, map[string]string{"instId": instID, "ordId": orderID})
+	if err != nil {
+		Logger.Error("Okex Service Cancel Order Failed", zap.Error(err))
+		return nil, err
+	}
+	return &quant.Order{
+		Pair:     pair,
+		Symbol:   instID,
+		OrderID:  util.ToInt(ack.OrdID),
+		OrderID2: ack.OrdID,
+		Status:   quant.ORDER_PENDING_CANCEL,
+	}, nil
+}
+
+// GetOrder 查询订单
+func (o *Okex) GetOrder(ctx context.Context, pair quant.CurrencyPair, orderID string) (*quant.Order, error) {
+	query := url.Values{}
+	query.Set("instId", toSymbol(pair))
+	query.Set("ordId", orderID)
+	var orders []*orderResponse
+	err := o.request(ctx, http.MethodGet, OrderURL, query, nil, true, &orders)
+	if err == nil && len(orders) == 0 {
+		err = &APIError{StatusCode: http.StatusOK, Code: ERR_ORDER_NOT_FOUND, Msg: "order does not exist", Path: OrderURL}
+	}
+	if err != nil {
+		Logger.Error("Okex Service Get Order Failed", zap.Error(err))
+		return nil, err
+	}
+	return orders[0].toOrder(pair)
+}
+
+// GetOpenOrders 查询产品的所有挂单
+func (o *Okex) GetOpenOrders(ctx context.Context, pair quant.CurrencyPair) ([]*quant.Order, error) {
+	query := url.Values{}
+	query.Set("instType", instTypeSpot)
+	query.Set("instId", toSymbol(pair))
+	var orders []*orderResponse
+	if err := o.request(ctx, http.MethodGet, PendingOrderURL, query, nil, true, &orders); err != nil {
+		Logger.Error("Okex Service Get Open Orders Failed", zap.Error(err))
+		return nil, err
+	}
+	result := make([]*quant.Order, 0, len(orders))
+	for _, order := range orders {
+		o, err := order.toOrder(pair)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, nil
+}
+
+// orderResponse 订单详情，REST查询与订单频道推送格式相同
+type orderResponse struct {
+	InstID    string `json:"instId"`
+	OrdID     string `json:"ordId"`
+	ClOrdID   string `json:"clOrdId"`
+	Px        string `json:"px"`
+	Sz        string `json:"sz"`
+	OrdType   string `json:"ordType"` // market / limit / post_only / fok / ioc
+	Side      string `json:"side"`
+	AccFillSz string `json:"accFillSz"`
+	AvgPx     string `json:"avgPx"`
+	State     string `json:"state"`
+	Fee       string `json:"fee"` // 手续费为负数
+	FeeCcy    string `json:"feeCcy"`
+	CTime     string `json:"cTime"`
+}
+
+func (r *orderResponse) toOrder(pair quant.CurrencyPair) (*quant.Order, error) {
+	side := quant.BUY
+	if r.Side == "sell" {
+		side = quant.SELL
+	}
+	orderType := 0
+	switch r.OrdType {
+	case "post_only":
+		orderType = 1
+	case "fok":
+		orderType = 2
+	case "ioc":
+		orderType = 3
+	}
+	typ := quant.ORDER_TYPE_LIMIT
+	if r.OrdType == "market" {
+		typ = quant.ORDER_TYPE_MARKET
+	}
+	status, ok := _INERNAL_ORDER_STATUS_CONVERTER[r.State]
+	if !ok {
+		status = quant.ORDER_NEW
+	}
+	p := &fieldParser{source: "order"}
+	order := &quant.Order{
+		Pair:       pair,
+		Symbol:     r.InstID,
+		OrderID:    p.int("ordId", r.OrdID),
+		OrderID2:   r.OrdID,
+		OrderType:  orderType,
+		Side:       side,
+		AvgPrice:   p.decimal("avgPx", r.AvgPx),
+		Type:       typ,
+		Fee:        p.decimal("fee", r.Fee).Abs(),
+		Price:      p.decimal("px", r.Px),
+		DealAmount: p.decimal("accFillSz", r.AccFillSz),
+		Amount:     p.decimal("sz", r.Sz),
+		Status:     status,
+		OrderTime:  p.int("cTime", r.CTime),
+
+		ClientOrderID: r.ClOrdID,
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
@@ -0,0 +1,58 @@
+package okex
+
+import (
+	"context"
+	"net/http"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+
+	"go.uber.org/zap"
+)
+
+// balanceDetail 单个币种的余额，REST查询与账户频道推送格式相同
+type balanceDetail struct {
+	Ccy       string `json:"ccy"`
+	AvailBal  string `json:"availBal"`
+	FrozenBal string `json:"frozenBal"`
+}
+
+type accountBalance struct {
+	UTime   string           `json:"uTime"`
+	Details []*balanceDetail `json:"details"`
+}
+
+func (a *accountBalance) toBalances() ([]*quant.Balance, error) {
+	p := &fieldParser{source: "balance"}
+	balances := make([]*quant.Balance, 0, len(a.Details))
+	for _, d := range a.Details {
+		balances = append(balances, &quant.Balance{
+			Asset:  d.Ccy,
+			Free:   p.decimal(d.Ccy+".availBal", d.AvailBal),
+			Locked: p.decimal(d.Ccy+".frozenBal", d.FrozenBal),
+		})
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+/*
+	获取交易账户中所有币种的余额，只返回余额不为0的币种
+*/
+func (o *Okex) GetBalances(ctx context.Context) ([]*quant.Balance, error) {
+	var accounts []*accountBalance
+	if err := o.request(ctx, http.MethodGet, BalanceURL, nil, nil, true, &accounts); err != nil {
+		Logger.Error("Okex Service Get Balances Failed", zap.Error(err))
+		return nil, err
+	}
+	var balances []*quant.Balance
+	for _, account := range accounts {
+		items, err := account.toBalances()
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, items...)
+	}
+	return balances, nil
+}
@@ -0,0 +1,291 @@
+package okex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+/////////////////////////////*********获取行情数据**********//////////////////////////////////////
+
+/*
+	获取所有可交易的现货交易对，精度由 tickSz/lotSz 换算
+*/
+func (o *Okex) GetCurrencyPairs(ctx context.Context) ([]quant.CurrencyPair, error) {
+	query := url.Values{}
+	query.Set("instType", instTypeSpot)
+	var instruments []struct {
+		BaseCcy  string `json:"baseCcy"`
+		QuoteCcy string `json:"quoteCcy"`
+		TickSz   string `json:"tickSz"`
+		LotSz    string `json:"lotSz"`
+		State    string `json:"state"`
+	}
+	if err := o.request(ctx, http.MethodGet, InstrumentsURL, query, nil, false, &instruments); err != nil {
+		Logger.Error("Okex Service Get Instruments Failed", zap.Error(err))
+		return nil, err
+	}
+	pairs := make([]quant.CurrencyPair, 0, len(instruments))
+	for _, inst := range instruments {
+		if inst.State != "live" {
+			continue
+		}
+		pair := quant.NewCurrencyPair(inst.BaseCcy, inst.QuoteCcy)
+		pair.PricePrecision, pair.AmountPrecision = quant.StepPrecision(inst.TickSz), quant.StepPrecision(inst.LotSz)
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// ticker 行情，REST查询与 tickers 频道推送格式相同
+type ticker struct {
+	InstID  string `json:"instId"`
+	Last    string `json:"last"`
+	AskPx   string `json:"askPx"`
+	BidPx   string `json:"bidPx"`
+	High24h string `json:"high24h"`
+	Low24h  string `json:"low24h"`
+	Vol24h  string `json:"vol24h"` // 以交易货币为单位的成交量
+	Ts      string `json:"ts"`
+}
+
+func (t *ticker) toTicker(pair quant.CurrencyPair) (*quant.Ticker, error) {
+	p := &fieldParser{source: "ticker"}
+	result := &quant.Ticker{
+		Pair:   pair,
+		Symbol: t.InstID,
+		Last:   p.decimal("last", t.Last),
+		Buy:    p.decimal("bidPx", t.BidPx),
+		Sell:   p.decimal("askPx", t.AskPx),
+		High:   p.decimal("high24h", t.High24h),
+		Low:    p.decimal("low24h", t.Low24h),
+		Vol:    p.decimal("vol24h", t.Vol24h),
+		Date:   p.uint64("ts", t.Ts),
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+/*
+	获取行情
+	pair(必需) : 交易对，如 quant.BTC_USDT
+*/
+func (o *Okex) GetTicker(ctx context.Context, pair quant.CurrencyPair) (*quant.Ticker, error) {
+	query := url.Values{}
+	query.Set("instId", toSymbol(pair))
+	var tickers []*ticker
+	err := o.request(ctx, http.MethodGet, TickerURL, query, nil, false, &tickers)
+	if err == nil && len(tickers) == 0 {
+		err = fmt.Errorf("no ticker for %s", pair)
+	}
+	if err != nil {
+		Logger.Error("Okex Service Get Ticker Failed", zap.Error(err))
+		return nil, err
+	}
+	return tickers[0].toTicker(pair)
+}
+
+// books 深度，每档为 [价格, 数量, 0, 订单数]
+type books struct {
+	Asks [][]string `json:"asks"`
+	Bids [][]string `json:"bids"`
+	Ts   string     `json:"ts"`
+}
+
+/*
+	获取深度
+	size : 最大400，<=0 时默认1档
+*/
+func (o *Okex) GetDepth(ctx context.Context, pair quant.CurrencyPair, size int) (*quant.Depth, error) {
+	if size > maxBooksSize {
+		size = maxBooksSize
+	}
+	instID := toSymbol(pair)
+	query := url.Values{}
+	query.Set("instId", instID)
+	if size > 0 {
+		query.Set("sz", strconv.Itoa(size))
+	}
+	var result []*books
+	err := o.request(ctx, http.MethodGet, BooksURL, query, nil, false, &result)
+	if err == nil && len(result) == 0 {
+		err = fmt.Errorf("no depth for %s", pair)
+	}
+	if err != nil {
+		Logger.Error("Okex Service Get Depth Failed", zap.Error(err))
+		return nil, err
+	}
+	p := &fieldParser{source: "books"}
+	depth := &quant.Depth{
+		Pair:    pair,
+		Symbol:  instID,
+		UTime:   time.Now(),
+		AskList: p.depthRecords("asks", result[0].Asks),
+		BidList: p.depthRecords("bids", result[0].Bids),
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return depth, nil
+}
+
+// toKline 解析 [ts,o,h,l,c,vol,volCcy,volCcyQuote,confirm]
+func toKline(pair quant.CurrencyPair, row []string) (*quant.Kline, error) {
+	if len(row) < 7 {
+		return nil, fmt.Errorf("invalid candle %v", row)
+	}
+	p := &fieldParser{source: "candle"}
+	openTime := p.int64("ts", row[0])
+	kline := &quant.Kline{
+		Pair:      pair,
+		Symbol:    toSymbol(pair),
+		Timestamp: openTime / 1000,
+		OpenTime:  openTime,
+		Open:      p.decimal("o", row[1]),
+		High:      p.decimal("h", row[2]),
+		Low:       p.decimal("l", row[3]),
+		Close:     p.decimal("c", row[4]),
+		Vol:       p.decimal("vol", row[5]),
+		QuoteVol:  p.decimal("volCcy", row[6]),
+	}
+	if err := p.err(); err != nil {
+		return nil, err
+	}
+	return kline, nil
+}
+
+/*
+	获取k线，按开盘时间升序返回
+	startTime/endTime(ms) 为0时不限制
+	limit : 默认100，最大300
+*/
+func (o *Okex) GetKlines(ctx context.Context, pair quant.CurrencyPair, period int, startTime, endTime int64, limit int) ([]*quant.Kline, error) {
+	bar, ok := _INERNAL_KLINE_PERIOD_CONVERTER[period]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kline period %d", period)
+	}
+	if limit > maxCandleLimit {
+		limit = maxCandleLimit
+	}
+	query := url.Values{}
+	query.Set("instId", toSymbol(pair))
+	query.Set("bar", bar)
+	// after 返回早于该时间的数据，before 返回晚于该时间的数据
+	if endTime > 0 {
+		query.Set("after", strconv.FormatInt(endTime+1, 10))
+	}
+	if startTime > 0 {
+		query.Set("before", strconv.FormatInt(startTime-1, 10))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var rows [][]string
+	if err := o.request(ctx, http.MethodGet, CandlesURL, query, nil, false, &rows); err != nil {
+		Logger.Error("Okex Service Get Klines Failed", zap.Error(err))
+		return nil, err
+	}
+	// 接口按时间倒序返回
+	klines := make([]*quant.Kline, 0, len(rows))
+	for i := len(rows) - 1; i >= 0; i-- {
+		kline, err := toKline(pair, rows[i])
+		if err != nil {
+			return nil, err
+		}
+		klines = append(klines, kline)
+	}
+	return klines, nil
+}
+
+/*
+	fieldParser 逐个解析字段并记录所有格式错误的字段
+	okex 以空字符串表示没有值(如未成交订单的 avgPx)，解析为0
+*/
+type fieldParser struct {
+	source string
+	fields []string
+}
+
+func (p *fieldParser) fail(field string, err error) *fieldParser {
+	p.fields = append(p.fields, field+": "+err.Error())
+	return p
+}
+
+func (p *fieldParser) err() error {
+	if len(p.fields) == 0 {
+		return nil
+	}
+	Logger.Error("Okex Service Parse Failed", zap.String("source", p.source), zap.Strings("fields", p.fields))
+	return &ParseError{Source: p.source, Fields: p.fields}
+}
+
+func (p *fieldParser) decimal(field, v string) decimal.Decimal {
+	if v == "" {
+		return decimal.Zero
+	}
+	d, err := util.ParseDecimal(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return d
+}
+
+func (p *fieldParser) int64(field, v string) int64 {
+	if v == "" {
+		return 0
+	}
+	i, err := util.ParseInt64(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return i
+}
+
+func (p *fieldParser) int(field, v string) int {
+	if v == "" {
+		return 0
+	}
+	i, err := util.ParseInt(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return i
+}
+
+func (p *fieldParser) uint64(field, v string) uint64 {
+	if v == "" {
+		return 0
+	}
+	u, err := util.ParseUint64(v)
+	if err != nil {
+		p.fail(field, err)
+	}
+	return u
+}
+
+// depthRecords 解析 [价格, 数量, ...] 形式的档位
+func (p *fieldParser) depthRecords(field string, levels [][]string) quant.DepthRecords {
+	records := make(quant.DepthRecords, 0, len(levels))
+	for i, level := range levels {
+		if len(level) < 2 {
+			p.fail(fmt.Sprintf("%s[%d]", field, i), fmt.Errorf("expected [price, amount], got %v", level))
+			continue
+		}
+		records = append(records, quant.DepthRecord{
+			Price:  p.decimal(fmt.Sprintf("%s[%d].price", field, i), level[0]),
+			Amount: p.decimal(fmt.Sprintf("%s[%d].amount", field, i), level[1]),
+		})
+	}
+	return records
+}
@@ -0,0 +1,544 @@
+package okex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	. "tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/util"
+
+	"go.uber.org/zap"
+)
+
+var _ quant.Stream = (*OkexWs)(nil)
+
+// 30秒内没有收到消息服务端会断开连接
+const wsPingInterval = 20 * time.Second
+
+var (
+	// ErrCallbackNotSet 订阅前未注册对应的回调
+	ErrCallbackNotSet = errors.New("callback not set, register it before subscribing")
+	// ErrCredentialsNotSet 订阅私有频道前未设置API Key
+	ErrCredentialsNotSet = errors.New("credentials not set, call SetCredentials before subscribing private channels")
+	// ErrClosed 建立连接期间调用了 Close
+	ErrClosed = errors.New("ws closed while connecting")
+)
+
+/*
+	OkexWs okex v5 websocket
+	公共频道、k线(business)频道与私有频道分别使用一条连接，私有连接登录成功后才会发送订阅
+*/
+type OkexWs struct {
+	baseURL    string
+	proxyUrl   string
+	accessKey  string
+	secretKey  string
+	passphrase string
+
+	tickerCallback  func(*quant.Ticker)
+	depthCallback   func(*quant.Depth)
+	tradeCallback   func(*quant.Trade)
+	klineCallback   func(*quant.Kline, int)
+	orderCallback   func(*quant.Order)
+	balanceCallback func([]*quant.Balance)
+
+	mu          sync.Mutex
+	conns       map[string]*util.WsConn // endpoint -> 连接
+	handlers    map[string]func(*wsMessage) error
+	privateArgs []*wsArg
+	loggedIn    bool
+	closeGen    int // 每次 Close 递增，丢弃 Close 之前开始建立的连接
+}
+
+/*
+	baseURL : 如 wss://ws.okx.com:8443/ws/v5，为空时使用默认地址
+	各频道分别连接 baseURL/public、baseURL/business、baseURL/private
+*/
+func NewOkexWs(baseURL, proxyURL string) *OkexWs {
+	if baseURL == "" {
+		baseURL = strings.TrimSuffix(defaultPublicWsURL, "/public")
+	}
+	return &OkexWs{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		proxyUrl: proxyURL,
+		conns:    make(map[string]*util.WsConn),
+		handlers: make(map[string]func(*wsMessage) error),
+	}
+}
+
+// SetCredentials 设置私有频道登录使用的API Key
+func (ow *OkexWs) SetCredentials(accessKey, secretKey, passphrase string) *OkexWs {
+	ow.accessKey = accessKey
+	ow.secretKey = secretKey
+	ow.passphrase = passphrase
+	return ow
+}
+
+func (ow *OkexWs) SetTickerCallback(callback func(*quant.Ticker)) {
+	ow.tickerCallback = callback
+}
+
+func (ow *OkexWs) SetDepthCallback(callback func(*quant.Depth)) {
+	ow.depthCallback = callback
+}
+
+func (ow *OkexWs) SetTradeCallback(callback func(*quant.Trade)) {
+	ow.tradeCallback = callback
+}
+
+// SetKlineCallback 回调参数为k线及其周期 KLINE_PERIOD_*
+func (ow *OkexWs) SetKlineCallback(callback func(*quant.Kline, int)) {
+	ow.klineCallback = callback
+}
+
+// SetOrderCallback 订单频道推送
+func (ow *OkexWs) SetOrderCallback(callback func(*quant.Order)) {
+	ow.orderCallback = callback
+}
+
+// SetBalanceCallback 账户频道推送，只包含发生变化的币种
+func (ow *OkexWs) SetBalanceCallback(callback func([]*quant.Balance)) {
+	ow.balanceCallback = callback
+}
+
+type wsArg struct {
+	Channel  string `json:"channel"`
+	InstID   string `json:"instId,omitempty"`
+	InstType string `json:"instType,omitempty"`
+}
+
+func (a *wsArg) key() string {
+	return a.Channel + ":" + a.InstID
+}
+
+type wsRequest struct {
+	Op   string        `json:"op"`
+	Args []interface{} `json:"args"`
+}
+
+/*
+	wsMessage 推送消息
+	event 不为空时为登录、订阅结果或错误，否则为 arg 频道的数据推送
+	action 仅深度频道使用: snapshot 全量 / update 增量
+*/
+type wsMessage struct {
+	Event  string          `json:"event"`
+	Code   string          `json:"code"`
+	Msg    string          `json:"msg"`
+	Arg    *wsArg          `json:"arg"`
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data"`
+}
+
+/*
+	connect 获取或建立到 endpoint 的连接，created 表示连接为本次新建
+	建立连接时不持有锁，避免阻塞消息分发；并发建立的连接只保留先完成的一条
+*/
+func (ow *OkexWs) connect(endpoint string, onReconnect func()) (*util.WsConn, bool, error) {
+	ow.mu.Lock()
+	conn, ok := ow.conns[endpoint]
+	gen := ow.closeGen
+	ow.mu.Unlock()
+	if ok {
+		return conn, false, nil
+	}
+
+	conn = util.NewWsConn(endpoint, ow.proxyUrl, ow.handle)
+	conn.SetPingMessage("ping").SetPingInterval(wsPingInterval)
+	if onReconnect != nil {
+		conn.SetReconnectHandler(onReconnect)
+	}
+	if err := conn.NewWebsocket(); err != nil {
+		Logger.Error("[ws] connect failed ", zap.String("endpoint", endpoint), zap.Error(err))
+		return nil, false, err
+	}
+
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+	if ow.closeGen != gen {
+		conn.Close()
+		return nil, false, ErrClosed
+	}
+	if existing, ok := ow.conns[endpoint]; ok {
+		conn.Close()
+		return existing, false, nil
+	}
+	ow.conns[endpoint] = conn
+	return conn, true, nil
+}
+
+// subscribe 订阅公共频道，订阅消息在重连后自动重发
+func (ow *OkexWs) subscribe(endpoint string, arg *wsArg, handle func(*wsMessage) error) error {
+	conn, _, err := ow.connect(endpoint, nil)
+	if err != nil {
+		return err
+	}
+	ow.mu.Lock()
+	ow.handlers[arg.key()] = handle
+	ow.mu.Unlock()
+	return conn.Subscribe(&wsRequest{Op: "subscribe", Args: []interface{}{arg}})
+}
+
+/*
+	subscribePrivate 订阅私有频道
+	登录消息的时间戳30秒内有效，因此每次(重新)连接都重新登录，登录成功后再发送全部订阅
+*/
+func (ow *OkexWs) subscribePrivate(arg *wsArg, handle func(*wsMessage) error) error {
+	if ow.accessKey == "" {
+		return ErrCredentialsNotSet
+	}
+	conn, created, err := ow.connect(ow.baseURL+"/private", func() {
+		ow.mu.Lock()
+		ow.loggedIn = false
+		ow.mu.Unlock()
+		ow.login()
+	})
+	if err != nil {
+		return err
+	}
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+	ow.handlers[arg.key()] = handle
+	ow.privateArgs = append(ow.privateArgs, arg)
+	if created {
+		return ow.sendLoginLocked(conn)
+	}
+	if ow.loggedIn {
+		return conn.SendMessage(&wsRequest{Op: "subscribe", Args: []interface{}{arg}})
+	}
+	return nil
+}
+
+func (ow *OkexWs) login() {
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+	if conn, ok := ow.conns[ow.baseURL+"/private"]; ok {
+		if err := ow.sendLoginLocked(conn); err != nil {
+			Logger.Error("[ws] okex login failed ", zap.Error(err))
+		}
+	}
+}
+
+func (ow *OkexWs) sendLoginLocked(conn *util.WsConn) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return conn.SendMessage(&wsRequest{Op: "login", Args: []interface{}{map[string]string{
+		"apiKey":     ow.accessKey,
+		"passphrase": ow.passphrase,
+		"timestamp":  timestamp,
+		"sign":       sign(ow.secretKey, timestamp, "GET", wsLoginPath, ""),
+	}}})
+}
+
+// onLogin 登录成功后订阅所有私有频道
+func (ow *OkexWs) onLogin() error {
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+	ow.loggedIn = true
+	conn, ok := ow.conns[ow.baseURL+"/private"]
+	if !ok || len(ow.privateArgs) == 0 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(ow.privateArgs))
+	for _, arg := range ow.privateArgs {
+		args = append(args, arg)
+	}
+	return conn.SendMessage(&wsRequest{Op: "subscribe", Args: args})
+}
+
+func (ow *OkexWs) handle(msg []byte) error {
+	if string(msg) == "pong" {
+		return nil
+	}
+	message := new(wsMessage)
+	if err := json.Unmarshal(msg, message); err != nil {
+		Logger.Error("json unmarshal error for ", zap.ByteString("msg", msg), zap.Error(err))
+		return err
+	}
+	switch message.Event {
+	case "":
+	case "login":
+		if message.Code != codeOK {
+			return &APIError{Code: message.Code, Msg: message.Msg, Path: wsLoginPath}
+		}
+		return ow.onLogin()
+	case "error":
+		Logger.Error("[ws] okex request failed ", zap.String("code", message.Code), zap.String("msg", message.Msg))
+		return nil
+	default:
+		return nil
+	}
+	if message.Arg == nil {
+		return nil
+	}
+	ow.mu.Lock()
+	handle, ok := ow.handlers[message.Arg.key()]
+	ow.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return handle(message)
+}
+
+/*
+	订阅行情
+	pair : 交易对，如 quant.BTC_USDT
+*/
+func (ow *OkexWs) SubscribeTicker(pair quant.CurrencyPair) error {
+	if ow.tickerCallback == nil {
+		return ErrCallbackNotSet
+	}
+	arg := &wsArg{Channel: "tickers", InstID: toSymbol(pair)}
+	return ow.subscribe(ow.baseURL+"/public", arg, func(msg *wsMessage) error {
+		var tickers []*ticker
+		if err := json.Unmarshal(msg.Data, &tickers); err != nil {
+			return err
+		}
+		for _, t := range tickers {
+			ticker, err := t.toTicker(pair)
+			if err != nil {
+				return err
+			}
+			ow.tickerCallback(ticker)
+		}
+		return nil
+	})
+}
+
+/*
+	订阅深度
+	size<=5 时订阅全量推送的 books5 频道，否则订阅400档增量推送的 books 频道，在本地合并后截取前size档
+*/
+func (ow *OkexWs) SubscribeDepth(pair quant.CurrencyPair, size int) error {
+	if ow.depthCallback == nil {
+		return ErrCallbackNotSet
+	}
+	instID := toSymbol(pair)
+	if size > 0 && size <= books5Size {
+		arg := &wsArg{Channel: "books5", InstID: instID}
+		return ow.subscribe(ow.baseURL+"/public", arg, func(msg *wsMessage) error {
+			var result []*books
+			if err := json.Unmarshal(msg.Data, &result); err != nil {
+				return err
+			}
+			for _, b := range result {
+				p := &fieldParser{source: "books5"}
+				asks, bids := p.depthRecords("asks", b.Asks), p.depthRecords("bids", b.Bids)
+				if err := p.err(); err != nil {
+					return err
+				}
+				ow.depthCallback(&quant.Depth{
+					Pair:    pair,
+					Symbol:  instID,
+					UTime:   time.Now(),
+					AskList: topLevels(asks, size),
+					BidList: topLevels(bids, size),
+				})
+			}
+			return nil
+		})
+	}
+
+	var bids, asks quant.DepthRecords
+	arg := &wsArg{Channel: "books", InstID: instID}
+	return ow.subscribe(ow.baseURL+"/public", arg, func(msg *wsMessage) error {
+		var result []*books
+		if err := json.Unmarshal(msg.Data, &result); err != nil {
+			return err
+		}
+		for _, b := range result {
+			p := &fieldParser{source: "books"}
+			bidUpdates, askUpdates := p.depthRecords("bids", b.Bids), p.depthRecords("asks", b.Asks)
+			if err := p.err(); err != nil {
+				return err
+			}
+			if msg.Action == "snapshot" {
+				bids, asks = nil, nil
+			}
+			bids = mergeLevels(bids, bidUpdates, true)
+			asks = mergeLevels(asks, askUpdates, false)
+		}
+		ow.depthCallback(&quant.Depth{
+			Pair:    pair,
+			Symbol:  instID,
+			UTime:   time.Now(),
+			AskList: topLevels(asks, size),
+			BidList: topLevels(bids, size),
+		})
+		return nil
+	})
+}
+
+// mergeLevels 将增量档位合并到有序的深度中，数量为0时删除该档位
+func mergeLevels(levels quant.DepthRecords, updates quant.DepthRecords, descending bool) quant.DepthRecords {
+	for _, update := range updates {
+		i := sort.Search(len(levels), func(i int) bool {
+			if descending {
+				return levels[i].Price.LessThanOrEqual(update.Price)
+			}
+			return levels[i].Price.GreaterThanOrEqual(update.Price)
+		})
+		found := i < len(levels) && levels[i].Price.Equal(update.Price)
+		switch {
+		case update.Amount.IsZero() && found:
+			levels = append(levels[:i], levels[i+1:]...)
+		case update.Amount.IsZero():
+		case found:
+			levels[i].Amount = update.Amount
+		default:
+			levels = append(levels, quant.DepthRecord{})
+			copy(levels[i+1:], levels[i:])
+			levels[i] = update
+		}
+	}
+	return levels
+}
+
+// topLevels 复制前n档，n<=0 返回全部
+func topLevels(levels quant.DepthRecords, n int) quant.DepthRecords {
+	if n <= 0 || n > len(levels) {
+		n = len(levels)
+	}
+	top := make(quant.DepthRecords, n)
+	copy(top, levels[:n])
+	return top
+}
+
+/*
+	订阅逐笔成交
+*/
+func (ow *OkexWs) SubscribeTrade(pair quant.CurrencyPair) error {
+	if ow.tradeCallback == nil {
+		return ErrCallbackNotSet
+	}
+	arg := &wsArg{Channel: "trades", InstID: toSymbol(pair)}
+	return ow.subscribe(ow.baseURL+"/public", arg, func(msg *wsMessage) error {
+		var trades []struct {
+			InstID  string `json:"instId"`
+			TradeID string `json:"tradeId"`
+			Px      string `json:"px"`
+			Sz      string `json:"sz"`
+			Side    string `json:"side"` // 主动成交方向
+			Ts      string `json:"ts"`
+		}
+		if err := json.Unmarshal(msg.Data, &trades); err != nil {
+			return err
+		}
+		for _, t := range trades {
+			side := quant.BUY
+			if t.Side == "sell" {
+				side = quant.SELL
+			}
+			p := &fieldParser{source: "trades"}
+			trade := &quant.Trade{
+				Pair:   pair,
+				Tid:    p.int64("tradeId", t.TradeID),
+				Type:   side,
+				Amount: p.decimal("sz", t.Sz),
+				Price:  p.decimal("px", t.Px),
+				Date:   p.int64("ts", t.Ts),
+				Symbol: t.InstID,
+			}
+			if err := p.err(); err != nil {
+				return err
+			}
+			ow.tradeCallback(trade)
+		}
+		return nil
+	})
+}
+
+/*
+	订阅k线，k线频道在business连接上
+*/
+func (ow *OkexWs) SubscribeKline(pair quant.CurrencyPair, period int) error {
+	if ow.klineCallback == nil {
+		return ErrCallbackNotSet
+	}
+	bar, ok := _INERNAL_KLINE_PERIOD_CONVERTER[period]
+	if !ok {
+		return fmt.Errorf("unsupported kline period %d", period)
+	}
+	arg := &wsArg{Channel: "candle" + bar, InstID: toSymbol(pair)}
+	return ow.subscribe(ow.baseURL+"/business", arg, func(msg *wsMessage) error {
+		var rows [][]string
+		if err := json.Unmarshal(msg.Data, &rows); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			kline, err := toKline(pair, row)
+			if err != nil {
+				return err
+			}
+			ow.klineCallback(kline, period)
+		}
+		return nil
+	})
+}
+
+/*
+	订阅现货订单推送，需要先 SetCredentials
+*/
+func (ow *OkexWs) SubscribeOrders() error {
+	if ow.orderCallback == nil {
+		return ErrCallbackNotSet
+	}
+	arg := &wsArg{Channel: "orders", InstType: instTypeSpot}
+	return ow.subscribePrivate(arg, func(msg *wsMessage) error {
+		var orders []*orderResponse
+		if err := json.Unmarshal(msg.Data, &orders); err != nil {
+			return err
+		}
+		for _, order := range orders {
+			o, err := order.toOrder(currencyPair(order.InstID))
+			if err != nil {
+				return err
+			}
+			ow.orderCallback(o)
+		}
+		return nil
+	})
+}
+
+/*
+	订阅账户余额推送，需要先 SetCredentials
+*/
+func (ow *OkexWs) SubscribeAccount() error {
+	if ow.balanceCallback == nil {
+		return ErrCallbackNotSet
+	}
+	arg := &wsArg{Channel: "account"}
+	return ow.subscribePrivate(arg, func(msg *wsMessage) error {
+		var accounts []*accountBalance
+		if err := json.Unmarshal(msg.Data, &accounts); err != nil {
+			return err
+		}
+		for _, account := range accounts {
+			balances, err := account.toBalances()
+			if err != nil {
+				return err
+			}
+			ow.balanceCallback(balances)
+		}
+		return nil
+	})
+}
+
+// Close 关闭所有连接
+func (ow *OkexWs) Close() {
+	ow.mu.Lock()
+	defer ow.mu.Unlock()
+	for _, conn := range ow.conns {
+		conn.Close()
+	}
+	ow.conns = make(map[string]*util.WsConn)
+	ow.handlers = make(map[string]func(*wsMessage) error)
+	ow.privateArgs = nil
+	ow.loggedIn = false
+	ow.closeGen++
+}
@@ -0,0 +1,189 @@
+package okex_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/okex"
+
+	"github.com/gorilla/websocket"
+)
+
+type wsRequest struct {
+	Op   string              `json:"op"`
+	Args []map[string]string `json:"args"`
+}
+
+func TestOkexWsPrivateLogin(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ws/v5/private" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+			return
+		}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		login := new(wsRequest)
+		if err := c.ReadJSON(login); err != nil || login.Op != "login" || len(login.Args) != 1 {
+			t.Errorf("expected login, got %+v %v", login, err)
+			return
+		}
+		arg := login.Args[0]
+		if arg["apiKey"] != testAccessKey || arg["sign"] != testSign(arg["timestamp"], "GET", "/users/self/verify", "") {
+			c.WriteMessage(websocket.TextMessage, []byte(`{"event":"error","code":"60009","msg":"Login failed."}`))
+			return
+		}
+		c.WriteMessage(websocket.TextMessage, []byte(`{"event":"login","code":"0","msg":""}`))
+
+		sub := new(wsRequest)
+		if err := c.ReadJSON(sub); err != nil || sub.Op != "subscribe" || sub.Args[0]["channel"] != "orders" {
+			t.Errorf("expected orders subscription, got %+v %v", sub, err)
+			return
+		}
+		c.WriteMessage(websocket.TextMessage, []byte(`{"arg":{"channel":"orders","instType":"SPOT","uid":"1"},
+			"data":[{"instId":"BTC-USDT","ordId":"452197707845865472","px":"30000","sz":"0.1","ordType":"limit",
+			"side":"buy","accFillSz":"0.1","avgPx":"29990","state":"filled","fee":"-0.0001","cTime":"1597026383085"}]}`))
+		c.ReadMessage()
+	}))
+	defer srv.Close()
+
+	orders := make(chan *quant.Order, 1)
+	ow := okex.NewOkexWs("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/v5", "").
+		SetCredentials(testAccessKey, testSecretKey, testPassphrase)
+	defer ow.Close()
+	ow.SetOrderCallback(func(order *quant.Order) {
+		orders <- order
+	})
+	if err := ow.SubscribeOrders(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case order := <-orders:
+		if order.OrderID2 != "452197707845865472" || !order.Pair.Equal(quant.BTC_USDT) || order.Status != quant.ORDER_FILLED || order.AvgPrice.String() != "29990" {
+			t.Fatalf("unexpected order %+v", order)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no order received")
+	}
+}
+
+func TestOkexWsBooksMerge(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		sub := new(wsRequest)
+		if err := c.ReadJSON(sub); err != nil || sub.Args[0]["channel"] != "books" {
+			t.Errorf("expected books subscription, got %+v %v", sub, err)
+			return
+		}
+		for _, msg := range []interface{}{
+			map[string]interface{}{"arg": sub.Args[0], "action": "snapshot", "data": []interface{}{map[string]interface{}{
+				"bids": [][]string{{"100", "1", "0", "1"}, {"99", "2", "0", "1"}},
+				"asks": [][]string{{"101", "1", "0", "1"}, {"102", "2", "0", "1"}},
+			}}},
+			map[string]interface{}{"arg": sub.Args[0], "action": "update", "data": []interface{}{map[string]interface{}{
+				"bids": [][]string{{"100", "0", "0", "0"}, {"99.5", "3", "0", "1"}},
+				"asks": [][]string{{"100.5", "4", "0", "1"}},
+			}}},
+		} {
+			data, _ := json.Marshal(msg)
+			c.WriteMessage(websocket.TextMessage, data)
+		}
+		c.ReadMessage()
+	}))
+	defer srv.Close()
+
+	depths := make(chan *quant.Depth, 2)
+	ow := okex.NewOkexWs("ws"+strings.TrimPrefix(srv.URL, "http"), "")
+	defer ow.Close()
+	ow.SetDepthCallback(func(depth *quant.Depth) {
+		depths <- depth
+	})
+	if err := ow.SubscribeDepth(quant.BTC_USDT, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	var depth *quant.Depth
+	for i := 0; i < 2; i++ {
+		select {
+		case depth = <-depths:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no depth received")
+		}
+	}
+	if len(depth.BidList) != 2 || depth.BidList[0].Price.String() != "99.5" || depth.BidList[1].Price.String() != "99" {
+		t.Fatalf("unexpected bids %+v", depth.BidList)
+	}
+	if len(depth.AskList) != 3 || depth.AskList[0].Price.String() != "100.5" || depth.AskList[0].Amount.String() != "4" {
+		t.Fatalf("unexpected asks %+v", depth.AskList)
+	}
+}
+
+func TestOkexWsDialWithoutLock(t *testing.T) {
+	dialing := make(chan struct{})
+	release := make(chan struct{})
+	var releaseOnce sync.Once
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// public 连接在 release 之前阻塞
+		if r.URL.Path == "/ws/v5/public" {
+			close(dialing)
+			<-release
+		}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		for {
+			if _, _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+	defer releaseOnce.Do(func() { close(release) })
+
+	ow := okex.NewOkexWs("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/v5", "")
+	defer ow.Close()
+	ow.SetTickerCallback(func(*quant.Ticker) {})
+	ow.SetKlineCallback(func(*quant.Kline, int) {})
+	result := make(chan error, 1)
+	go func() {
+		result <- ow.SubscribeTicker(quant.BTC_USDT)
+	}()
+	<-dialing
+
+	// public 连接建立中时，business 连接的订阅不会被阻塞
+	done := make(chan error, 1)
+	go func() {
+		done <- ow.SubscribeKline(quant.BTC_USDT, quant.KLINE_PERIOD_1MIN)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscribe blocked while another connection was dialing")
+	}
+
+	releaseOnce.Do(func() { close(release) })
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
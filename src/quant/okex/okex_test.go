@@ -0,0 +1,279 @@
+package okex_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+	"tinyquant/src/logger"
+	"tinyquant/src/quant"
+	"tinyquant/src/quant/okex"
+
+	"github.com/shopspring/decimal"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	testAccessKey  = "ak"
+	testSecretKey  = "sk"
+	testPassphrase = "pass"
+)
+
+func init() {
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+}
+
+var clOrdIDPattern = regexp.MustCompile(`^[a-zA-Z0-9]{1,32}$`)
+
+func testSign(timestamp, method, requestPath, body string) string {
+	mac := hmac.New(sha256.New, []byte(testSecretKey))
+	mac.Write([]byte(timestamp + method + requestPath + body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("OK-ACCESS-KEY") != "" {
+			expected := testSign(r.Header.Get("OK-ACCESS-TIMESTAMP"), r.Method, r.URL.RequestURI(), string(body))
+			if r.Header.Get("OK-ACCESS-SIGN") != expected || r.Header.Get("OK-ACCESS-PASSPHRASE") != testPassphrase {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"code":"50113","msg":"Invalid Sign","data":[]}`))
+				return
+			}
+		}
+		switch r.URL.Path {
+		case "/api/v5/trade/order":
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`{"code":"0","msg":"","data":[{"instId":"BTC-USDT","ordId":"312269865356374016",
+					"px":"30000","sz":"0.02","ordType":"limit","side":"sell","accFillSz":"0.01","avgPx":"30010",
+					"state":"partially_filled","fee":"-0.3001","feeCcy":"USDT","cTime":"1597026383085"}]}`))
+				return
+			}
+			params := map[string]string{}
+			json.Unmarshal(body, &params)
+			if params["sz"] == "1000" {
+				w.Write([]byte(`{"code":"1","msg":"Operation failed.","data":[{"ordId":"","sCode":"51008","sMsg":"Insufficient balance"}]}`))
+				return
+			}
+			if params["instId"] != "BTC-USDT" || params["side"] != "sell" || params["ordType"] != "limit" || params["tdMode"] != "cash" {
+				t.Errorf("unexpected order params %v", params)
+			}
+			if !clOrdIDPattern.MatchString(params["clOrdId"]) {
+				t.Errorf("invalid clOrdId %q", params["clOrdId"])
+			}
+			w.Write([]byte(`{"code":"0","msg":"","data":[{"ordId":"312269865356374016","clOrdId":"` + params["clOrdId"] + `","sCode":"0","sMsg":""}]}`))
+		case "/api/v5/account/balance":
+			w.Write([]byte(`{"code":"0","msg":"","data":[{"uTime":"1597026383085","details":[
+				{"ccy":"USDT","availBal":"900","frozenBal":"100"},{"ccy":"BTC","availBal":"0.5","frozenBal":"0"}]}]}`))
+		case "/api/v5/public/instruments":
+			w.Write([]byte(`{"code":"0","msg":"","data":[
+				{"instId":"BTC-USDT","baseCcy":"BTC","quoteCcy":"USDT","tickSz":"0.1","lotSz":"0.00000001","state":"live"},
+				{"instId":"ABC-BTC","baseCcy":"ABC","quoteCcy":"BTC","tickSz":"0.0001","lotSz":"1","state":"suspend"}]}`))
+		case "/api/v5/market/candles":
+			if r.URL.Query().Get("bar") != "1H" || r.URL.Query().Get("after") != "1597028400001" {
+				t.Errorf("unexpected candle query %s", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"code":"0","msg":"","data":[
+				["1597028400000","2","4","1","3","10","30","30","1"],
+				["1597024800000","1","2","1","2","20","40","40","1"]]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestOkexOrders(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	var exchange quant.Exchange = okex.NewOkex(testAccessKey, testSecretKey, testPassphrase).SetBaseURL(srv.URL)
+	ctx := context.Background()
+
+	order, err := exchange.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.SELL, Price: decimal.RequireFromString("30000"), Amount: decimal.RequireFromString("0.02"),
+		Strategy: "gridstrategy01", Intent: "level1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 策略前缀被截短，使 clOrdId 不超过32位
+	id := quant.ClientOrderID("gridstrategy01", "level1")
+	if order.OrderID2 != "312269865356374016" || order.Symbol != "BTC-USDT" || !order.Pair.Equal(quant.BTC_USDT) || order.Side != quant.SELL ||
+		order.ClientOrderID != "gridstrate"+id[len(id)-22:] {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	_, err = exchange.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.SELL, Price: decimal.RequireFromString("30000"), Amount: decimal.RequireFromString("0.02"),
+		ClientOrderID: "web_order_1"})
+	if err == nil {
+		t.Fatal("expected invalid clOrdId error")
+	}
+
+	order, err = exchange.GetOrder(ctx, quant.BTC_USDT, order.OrderID2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != quant.ORDER_PARTIALLY_FILLED || order.DealAmount.String() != "0.01" || order.AvgPrice.String() != "30010" || order.Fee.String() != "0.3001" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	_, err = exchange.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.SELL, Price: decimal.RequireFromString("30000"), Amount: decimal.RequireFromString("1000")})
+	apiErr, ok := okex.AsAPIError(err)
+	if !ok || !apiErr.IsInsufficientBalance() {
+		t.Fatalf("expected insufficient balance, got %v", err)
+	}
+
+	_, err = okex.NewOkex(testAccessKey, "wrong", testPassphrase).SetBaseURL(srv.URL).GetBalances(ctx)
+	apiErr, ok = okex.AsAPIError(err)
+	if !ok || !apiErr.IsInvalidSignature() || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestOkexMarketAndBalances(t *testing.T) {
+	srv := newTestServer(t)
+	defer srv.Close()
+	o := okex.NewOkex(testAccessKey, testSecretKey, testPassphrase).SetBaseURL(srv.URL)
+	ctx := context.Background()
+
+	balances, err := o.GetBalances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(balances) != 2 || balances[0].Asset != "USDT" || balances[0].Free.String() != "900" || balances[0].Locked.String() != "100" {
+		t.Fatalf("unexpected balances %+v", balances)
+	}
+
+	pairs, err := o.GetCurrencyPairs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 1 || !pairs[0].Equal(quant.BTC_USDT) || pairs[0].PricePrecision != 1 || pairs[0].AmountPrecision != 8 {
+		t.Fatalf("unexpected pairs %+v", pairs)
+	}
+
+	klines, err := o.GetKlines(ctx, quant.BTC_USDT, quant.KLINE_PERIOD_1H, 0, 1597028400000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(klines) != 2 || klines[0].OpenTime != 1597024800000 || klines[1].Close.String() != "3" || klines[1].QuoteVol.String() != "30" {
+		t.Fatalf("klines not in ascending order: %+v %+v", klines[0], klines[1])
+	}
+}
+
+func TestOkexHttpClientConfig(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}))
+	defer srv.Close()
+	// [http.okex] 的超时覆盖默认的10秒
+	viper.Set("http.okex.Timeout", "50ms")
+	defer viper.Set("http.okex.Timeout", "10s")
+	o := okex.NewOkex(testAccessKey, testSecretKey, testPassphrase).SetBaseURL(srv.URL)
+	start := time.Now()
+	if _, err := o.GetTicker(context.Background(), quant.BTC_USDT); err == nil {
+		t.Fatal("expected timeout")
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Fatalf("configured timeout not applied, took %s", elapsed)
+	}
+}
+
+func TestOkexPlaceOrderOptions(t *testing.T) {
+	var placed []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v5/trade/order" {
+			http.NotFound(w, r)
+			return
+		}
+		params := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&params)
+		placed = append(placed, params)
+		w.Write([]byte(`{"code":"0","msg":"","data":[{"ordId":"312269865356374016","clOrdId":"","sCode":"0","sMsg":""}]}`))
+	}))
+	defer srv.Close()
+	o := okex.NewOkex(testAccessKey, testSecretKey, testPassphrase).SetBaseURL(srv.URL)
+	ctx := context.Background()
+	price, amount := decimal.RequireFromString("30000"), decimal.RequireFromString("0.02")
+
+	// 不支持的参数直接返回错误，不发送请求
+	rejected := []*quant.OrderRequest{
+		{Side: quant.SELL, Price: price, Amount: amount, StopPrice: price},
+		{Side: quant.SELL, Price: price, Amount: amount, IcebergAmount: amount},
+		{Side: quant.SELL, Price: price, Amount: amount, ResponseType: "FULL"},
+		{Side: quant.SELL, Price: price, Amount: amount, TimeInForce: "GTX"},
+		{Side: quant.SELL_MARKET, Amount: amount, TimeInForce: quant.TIME_IN_FORCE_FOK},
+		{Side: quant.BUY, Price: price, QuoteAmount: amount},
+		{Side: quant.BUY, Type: quant.ORDER_TYPE_TAKE_PROFIT, Amount: amount, StopPrice: price},
+	}
+	for i, req := range rejected {
+		req.Pair = quant.BTC_USDT
+		if _, err := o.PlaceOrder(ctx, req); err == nil {
+			t.Errorf("request %d: expected error", i)
+		}
+	}
+	if len(placed) != 0 {
+		t.Fatalf("rejected requests were sent %v", placed)
+	}
+
+	if _, err := o.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.SELL, Price: price, Amount: amount, TimeInForce: quant.TIME_IN_FORCE_IOC}); err != nil {
+		t.Fatal(err)
+	}
+	if placed[0]["ordType"] != "ioc" || placed[0]["px"] != "30000" {
+		t.Fatalf("unexpected ioc params %v", placed[0])
+	}
+	order, err := o.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.SELL_MARKET, QuoteAmount: decimal.RequireFromString("500")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if placed[1]["ordType"] != "market" || placed[1]["sz"] != "500" || placed[1]["tgtCcy"] != "quote_ccy" || !order.Amount.Equal(decimal.RequireFromString("500")) {
+		t.Fatalf("unexpected quote amount params %v order %+v", placed[1], order)
+	}
+	// 市价买单的 Amount 为基础货币数量
+	if _, err := o.PlaceOrder(ctx, &quant.OrderRequest{Pair: quant.BTC_USDT, Side: quant.BUY_MARKET, Amount: amount}); err != nil {
+		t.Fatal(err)
+	}
+	if placed[2]["ordType"] != "market" || placed[2]["sz"] != "0.02" || placed[2]["tgtCcy"] != "base_ccy" {
+		t.Fatalf("unexpected market buy params %v", placed[2])
+	}
+	if _, ok := placed[0]["tgtCcy"]; ok {
+		t.Fatalf("tgtCcy sent for limit order %v", placed[0])
+	}
+}
+
+func TestOkexOrderParseError(t *testing.T) {
+	avgPx := `""`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"code":"0","msg":"","data":[{"instId":"BTC-USDT","ordId":"312269865356374016",
+			"px":"","sz":"0.02","ordType":"market","side":"buy","accFillSz":"0","avgPx":` + avgPx + `,
+			"state":"live","fee":"","feeCcy":"BTC","cTime":"1597026383085"}]}`))
+	}))
+	defer srv.Close()
+	o := okex.NewOkex(testAccessKey, testSecretKey, testPassphrase).SetBaseURL(srv.URL)
+	ctx := context.Background()
+
+	// 空字符串表示没有值
+	order, err := o.GetOrder(ctx, quant.BTC_USDT, "312269865356374016")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !order.AvgPrice.IsZero() || !order.Price.IsZero() || !order.Fee.IsZero() || order.Amount.String() != "0.02" {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	avgPx = `"n/a"`
+	_, err = o.GetOrder(ctx, quant.BTC_USDT, "312269865356374016")
+	parseErr, ok := err.(*okex.ParseError)
+	if !ok || parseErr.Source != "order" || len(parseErr.Fields) != 1 || !strings.HasPrefix(parseErr.Fields[0], "avgPx:") {
+		t.Fatalf("expected avgPx parse error, got %v", err)
+	}
+}
@@ -1,6 +1,10 @@
 package util
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+)
 
 var (
 	BaseURL      string
@@ -23,14 +27,17 @@ func InitSystemParams() {
 	if SecretKey == "" {
 		panic("Get secretKey failed ")
 	}
+	// 代理可选，为空时直连
 	ProxyURL = viper.GetString("system.ProxyURL")
-	if ProxyURL == "" {
-		panic("Get ProxyURL failed ")
-	}
 	WebSocketURL = viper.GetString("system.WebsocketUrl")
 	if WebSocketURL == "" {
 		panic("Get WebSocketURL failed ")
 	}
+	c, err := NewHttpClient(LoadHttpClientConfig("binance"))
+	if err != nil {
+		panic(fmt.Errorf("Init http client failed: %s ", err))
+	}
+	client = c
 }
 
 const (
@@ -44,15 +51,22 @@ const (
 
 // binance url
 const (
-	PingURL        = "/api/v3/ping"
-	ServiceTimeURL = "/api/v3/time"
-	DepthURL       = "/api/v3/depth"
-	LatestTrades   = "/api/v3/trades"
-	HistoryTrades  = "/api/v3/historicalTrades"
-	LatestTradesA  = "/api/v3/aggTrades"
-)
-
-// 交易对
-const (
-	BTC_USDT = "BTCUSDT"
+	PingURL         = "/api/v3/ping"
+	ServiceTimeURL  = "/api/v3/time"
+	DepthURL        = "/api/v3/depth"
+	LatestTrades    = "/api/v3/trades"
+	HistoryTrades   = "/api/v3/historicalTrades"
+	LatestTradesA   = "/api/v3/aggTrades"
+	KlinesURL       = "/api/v3/klines"
+	OrderURL        = "/api/v3/order"
+	TestOrderURL    = "/api/v3/order/test"
+	OpenOrdersURL   = "/api/v3/openOrders"
+	AllOrdersURL    = "/api/v3/allOrders"
+	MyTradesURL     = "/api/v3/myTrades"
+	ExchangeInfoURL = "/api/v3/exchangeInfo"
+	AvgPriceURL     = "/api/v3/avgPrice"
+	Ticker24hrURL   = "/api/v3/ticker/24hr"
+	TickerPriceURL  = "/api/v3/ticker/price"
+	BookTickerURL   = "/api/v3/ticker/bookTicker"
+	UserDataURL     = "/api/v3/userDataStream"
 )
@@ -0,0 +1,8 @@
+package util
+
+import "net/http"
+
+// SetHttpClient 测试时替换默认的http client
+func SetHttpClient(c *http.Client) {
+	client = c
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// HttpClientConfig http client 配置，可按交易所分别设置
+type HttpClientConfig struct {
+	ProxyURL            string        // 代理地址，支持 http、https、socks5，为空时使用环境变量 HTTP(S)_PROXY
+	CAFiles             []string      // 额外信任的CA证书(PEM)，在系统证书的基础上追加
+	InsecureSkipVerify  bool          // 跳过证书校验，仅用于调试
+	Timeout             time.Duration // 整个请求的超时时间，包括读取响应体
+	DialTimeout         time.Duration // 建立连接的超时时间
+	IdleConnTimeout     time.Duration // 空闲连接保持时间
+	MaxIdleConns        int           // 最大空闲连接数
+	MaxIdleConnsPerHost int           // 每个host的最大空闲连接数，默认是2
+	MaxConnsPerHost     int           // 每个host的最大连接数，0为不限制
+}
+
+func DefaultHttpClientConfig() HttpClientConfig {
+	return HttpClientConfig{
+		Timeout:             10 * time.Second,
+		DialTimeout:         5 * time.Second,
+		IdleConnTimeout:     30 * time.Second,
+		MaxIdleConns:        30,
+		MaxIdleConnsPerHost: 30,
+	}
+}
+
+/*
+	LoadHttpClientConfig 从配置文件读取 http client 配置
+	[http] 为所有交易所的默认值，[http.<name>] 覆盖单个交易所，如 [http.binance]
+	未配置 http.ProxyURL 时使用 system.ProxyURL
+*/
+func LoadHttpClientConfig(name string) HttpClientConfig {
+	cfg := DefaultHttpClientConfig()
+	cfg.ProxyURL = viper.GetString("system.ProxyURL")
+	keys := []string{"http"}
+	if name != "" {
+		keys = append(keys, "http."+name)
+	}
+	for _, key := range keys {
+		if viper.IsSet(key + ".ProxyURL") {
+			cfg.ProxyURL = viper.GetString(key + ".ProxyURL")
+		}
+		if viper.IsSet(key + ".CAFiles") {
+			cfg.CAFiles = viper.GetStringSlice(key + ".CAFiles")
+		}
+		if viper.IsSet(key + ".InsecureSkipVerify") {
+			cfg.InsecureSkipVerify = viper.GetBool(key + ".InsecureSkipVerify")
+		}
+		if viper.IsSet(key + ".Timeout") {
+			cfg.Timeout = viper.GetDuration(key + ".Timeout")
+		}
+		if viper.IsSet(key + ".DialTimeout") {
+			cfg.DialTimeout = viper.GetDuration(key + ".DialTimeout")
+		}
+		if viper.IsSet(key + ".IdleConnTimeout") {
+			cfg.IdleConnTimeout = viper.GetDuration(key + ".IdleConnTimeout")
+		}
+		if viper.IsSet(key + ".MaxIdleConns") {
+			cfg.MaxIdleConns = viper.GetInt(key + ".MaxIdleConns")
+		}
+		if viper.IsSet(key + ".MaxIdleConnsPerHost") {
+			cfg.MaxIdleConnsPerHost = viper.GetInt(key + ".MaxIdleConnsPerHost")
+		}
+		if viper.IsSet(key + ".MaxConnsPerHost") {
+			cfg.MaxConnsPerHost = viper.GetInt(key + ".MaxConnsPerHost")
+		}
+	}
+	return cfg
+}
+
+// NewHttpClient 按配置创建 http client，代理地址或CA证书无效时返回错误
+func NewHttpClient(cfg HttpClientConfig) (*http.Client, error) {
+	proxy, err := proxyFunc(cfg.ProxyURL)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if len(cfg.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, file := range cfg.CAFiles {
+			pem, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in %s", file)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return &http.Client{
+		Timeout: cfg.Timeout,
+		Transport: &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   cfg.DialTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:     tlsConfig,
+			TLSHandshakeTimeout: cfg.DialTimeout,
+			MaxIdleConns:        cfg.MaxIdleConns,
+			MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+			MaxConnsPerHost:     cfg.MaxConnsPerHost,
+			IdleConnTimeout:     cfg.IdleConnTimeout,
+		},
+	}, nil
+}
+
+func proxyFunc(proxyURL string) (func(*http.Request) (*url.URL, error), error) {
+	if proxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	u, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q", proxyURL)
+	}
+	return http.ProxyURL(u), nil
+}
@@ -0,0 +1,66 @@
+package util_test
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"tinyquant/src/mod"
+	"tinyquant/src/util"
+)
+
+func TestNewHttpClientProxy(t *testing.T) {
+	for _, proxy := range []string{"http://127.0.0.1:7890", "socks5://127.0.0.1:1080"} {
+		cfg := util.DefaultHttpClientConfig()
+		cfg.ProxyURL = proxy
+		if _, err := util.NewHttpClient(cfg); err != nil {
+			t.Fatalf("%s: %v", proxy, err)
+		}
+	}
+	for _, proxy := range []string{"ftp://127.0.0.1:21", "127.0.0.1:7890"} {
+		cfg := util.DefaultHttpClientConfig()
+		cfg.ProxyURL = proxy
+		if _, err := util.NewHttpClient(cfg); err == nil {
+			t.Fatalf("%s: expected error", proxy)
+		}
+	}
+}
+
+func TestNewHttpClientVerifiesTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+	req := &mod.ReqParam{Method: "GET", URL: "/ping"}
+
+	c, err := util.NewHttpClient(util.DefaultHttpClientConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.HttpRequestJSONWithClient(context.Background(), c, srv.URL, req, nil); err == nil {
+		t.Fatal("expected certificate verification error")
+	}
+
+	dir, err := ioutil.TempDir("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := util.DefaultHttpClientConfig()
+	cfg.CAFiles = []string{caFile}
+	if c, err = util.NewHttpClient(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := util.HttpRequestJSONWithClient(context.Background(), c, srv.URL, req, nil); err != nil {
+		t.Fatal(err)
+	}
+}
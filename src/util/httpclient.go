@@ -1,14 +1,14 @@
 package util
 
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 	"tinyquant/src/logger"
 	"tinyquant/src/mod"
@@ -22,70 +22,178 @@ var (
 
 func init() {
 	if client == nil {
-		client = initHttpClient()
+		// 默认配置不含CA文件与代理地址，不会出错
+		client, _ = NewHttpClient(DefaultHttpClientConfig())
 	}
 }
 
-func initHttpClient() *http.Client {
-	client := &http.Client{
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   5 * time.Second,
-				KeepAlive: 5 * time.Second,
-			}).DialContext,
-			MaxIdleConns:        30,               //最大空闲连接数
-			MaxIdleConnsPerHost: 60,               //最大与服务器的连接数  默认是2
-			IdleConnTimeout:     30 * time.Second, //空闲连接保持时间
-			Proxy: func(_ *http.Request) (*url.URL, error) {
-				return url.Parse("http://127.0.0.1:7890")
-			},
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // disable verify
-		},
-	}
-	return client
+// ResponseError 接口返回的错误，HTTP状态码非2xx或响应体为 {"code":...,"msg":...} 错误信息
+type ResponseError struct {
+	StatusCode int
+	Code       int64
+	Msg        string
+	Path       string
+	Body       []byte
+	Header     http.Header
+	RetryAfter time.Duration // 429/418 响应的 Retry-After，未返回时为0
+}
+
+func (e *ResponseError) Error() string {
+	if e.Msg != "" {
+		return fmt.Sprintf("request %s failed: status=%d code=%d msg=%s", e.Path, e.StatusCode, e.Code, e.Msg)
+	}
+	return fmt.Sprintf("request %s failed: status=%d body=%s", e.Path, e.StatusCode, string(e.Body))
 }
 
 func HttpRequest(ctx context.Context, req *mod.ReqParam) (map[string]interface{}, error) {
+	var msg map[string]interface{}
+	if err := HttpRequestJSON(ctx, req, &msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// HttpRequestJSON 发送请求并将响应体解析到 out，out 可以是任意类型的指针(结构体、切片等)
+func HttpRequestJSON(ctx context.Context, req *mod.ReqParam, out interface{}) error {
+	_, err := HttpRequestJSONWithHeader(ctx, req, out)
+	return err
+}
+
+// HttpRequestJSONWithHeader 同 HttpRequestJSON，并返回响应头，如 binance 的 X-MBX-USED-WEIGHT-1M
+func HttpRequestJSONWithHeader(ctx context.Context, req *mod.ReqParam, out interface{}) (http.Header, error) {
+	return HttpRequestJSONWithClient(ctx, nil, "", req, out)
+}
+
+/*
+	HttpRequestJSONWithClient 使用指定的 client 与 baseURL 发送请求，并返回响应头
+	c 为nil时使用默认client，baseURL 为空时使用 BaseURL
+*/
+func HttpRequestJSONWithClient(ctx context.Context, c *http.Client, baseURL string, req *mod.ReqParam, out interface{}) (http.Header, error) {
+	body, header, err := httpDo(ctx, c, baseURL, req)
+	if err != nil {
+		return header, err
+	}
+	if out == nil {
+		return header, nil
+	}
+	err = json.Unmarshal(body, out)
+	if err != nil {
+		logger.Logger.Error("json unmarshal failed : ", zap.String("url", req.URL), zap.ByteString("body", body), zap.Error(err))
+		return header, err
+	}
+	return header, nil
+}
 
-	urlx := fmt.Sprintf("%s%s", BaseURL, req.URL)
+// HttpRequestRaw 发送请求并返回原始响应体，错误响应以 *ResponseError 返回
+func HttpRequestRaw(ctx context.Context, req *mod.ReqParam) ([]byte, error) {
+	body, _, err := httpDo(ctx, nil, "", req)
+	return body, err
+}
 
-	queryString := req.Query.Encode()
+func httpDo(ctx context.Context, c *http.Client, baseURL string, req *mod.ReqParam) ([]byte, http.Header, error) {
+	if c == nil {
+		c = client
+	}
+	if baseURL == "" {
+		baseURL = BaseURL
+	}
+	urlx := fmt.Sprintf("%s%s", baseURL, req.URL)
+
+	queryString := encodeQuery(req.Query)
 	if queryString != "" {
 		urlx = fmt.Sprintf("%s?%s", urlx, queryString)
 	}
-	fmt.Println("full urlx : ", urlx)
+	logger.Logger.Debug("http request ", zap.String("method", req.Method), zap.String("url", urlx))
 	r, err := http.NewRequest(req.Method, urlx, nil)
-	r = r.WithContext(ctx)
-	if r.Header.Get("User-Agent") == "" {
-		r.Header.Add("User-Agent", "Mozilla/5.0 (Windows NT 5.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/31.0.1650.63 Safari/537.36")
+	if err != nil {
+		logger.Logger.Error("http request failed ", zap.Error(err))
+		return nil, nil, err
 	}
+	r = r.WithContext(ctx)
 	if req.APIKEY != "" {
 		r.Header.Add("X-MBX-APIKEY", req.APIKEY)
 	}
 	r.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	r.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 5.1) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/31.0.1650.63 Safari/537.36")
-	if err != nil {
-		logger.Logger.Error("http request failed ", zap.Error(err))
-		return nil, err
-	}
-	res, err := client.Do(r)
+	res, err := c.Do(r)
 	if err != nil {
 		logger.Logger.Error("http Do failed ", zap.Error(err))
-		return nil, err
+		return nil, nil, err
 	}
 	defer res.Body.Close()
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		logger.Logger.Error("io read failed ", zap.Error(err))
-		return nil, err
+		return nil, res.Header, err
 	}
-	fmt.Println(string(body))
-	var msg map[string]interface{}
-	err = json.Unmarshal(body, &msg)
-	if err != nil {
-		logger.Logger.Error("json unmarshal failed : ", zap.Error(err))
-		return nil, err
+	if err := checkResponse(req.URL, res.StatusCode, body); err != nil {
+		respErr := err.(*ResponseError)
+		respErr.Header = res.Header
+		respErr.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+		logger.Logger.Error("http response error ", zap.Error(err))
+		return nil, res.Header, err
 	}
-	return msg, nil
+	return body, res.Header, nil
+}
 
+// parseRetryAfter 解析秒数或HTTP日期形式的 Retry-After
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && time.Until(t) > 0 {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// encodeQuery 编码请求参数，签名必须放在最后，与签名时的参数顺序保持一致
+func encodeQuery(query url.Values) string {
+	signature := query.Get(signatureKey)
+	if signature == "" {
+		return query.Encode()
+	}
+	params := url.Values{}
+	for k, v := range query {
+		if k != signatureKey {
+			params[k] = v
+		}
+	}
+	encoded := params.Encode()
+	if encoded != "" {
+		encoded += "&"
+	}
+	return encoded + signatureKey + "=" + url.QueryEscape(signature)
+}
+
+// checkResponse 识别HTTP错误状态码以及 {"code":-1121,"msg":"Invalid symbol."} 形式的错误信息
+func checkResponse(path string, statusCode int, body []byte) error {
+	envelope := struct {
+		Code json.RawMessage `json:"code"`
+		Msg  *string         `json:"msg"`
+	}{}
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		json.Unmarshal(trimmed, &envelope)
+	}
+	// 只识别数字形式的code，其他交易所的字符串code由各自的适配器处理
+	code, codeErr := strconv.ParseInt(string(envelope.Code), 10, 64)
+	isEnvelope := codeErr == nil && envelope.Msg != nil
+
+	if statusCode >= http.StatusBadRequest || (isEnvelope && code != 0) {
+		respErr := &ResponseError{
+			StatusCode: statusCode,
+			Path:       path,
+			Body:       body,
+		}
+		if isEnvelope {
+			respErr.Code = code
+			respErr.Msg = *envelope.Msg
+		}
+		return respErr
+	}
+	return nil
 }
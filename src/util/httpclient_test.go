@@ -1,10 +1,72 @@
 package util_test
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
+	"tinyquant/src/mod"
 	"tinyquant/src/util"
 )
 
 func TestHttpGet(t *testing.T) {
-	//util.HttpGet()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/trades":
+			w.Write([]byte(`[{"id":1,"price":"1.5"},{"id":2,"price":"1.6"}]`))
+		case "/invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+		case "/envelope":
+			w.Write([]byte(`{"code":-1003,"msg":"Too many requests."}`))
+		}
+	}))
+	defer srv.Close()
+	util.SetHttpClient(srv.Client())
+	util.BaseURL = srv.URL
+
+	var trades []struct {
+		ID    int64  `json:"id"`
+		Price string `json:"price"`
+	}
+	err := util.HttpRequestJSON(context.Background(), &mod.ReqParam{Method: "GET", URL: "/trades"}, &trades)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 2 || trades[1].ID != 2 || trades[1].Price != "1.6" {
+		t.Fatalf("unexpected trades %+v", trades)
+	}
+
+	for path, status := range map[string]int{"/invalid": http.StatusBadRequest, "/envelope": http.StatusOK} {
+		err = util.HttpRequestJSON(context.Background(), &mod.ReqParam{Method: "GET", URL: path}, &trades)
+		respErr, ok := err.(*util.ResponseError)
+		if !ok {
+			t.Fatalf("%s: expected *ResponseError, got %v", path, err)
+		}
+		if respErr.StatusCode != status || respErr.Code >= 0 || respErr.Msg == "" || respErr.Path != path {
+			t.Fatalf("%s: unexpected error %+v", path, respErr)
+		}
+	}
+}
+
+func TestHttpRequestRetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-MBX-USED-WEIGHT-1M", "1201")
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"code":-1003,"msg":"Too many requests."}`))
+	}))
+	defer srv.Close()
+	util.SetHttpClient(srv.Client())
+	util.BaseURL = srv.URL
+
+	header, err := util.HttpRequestJSONWithHeader(context.Background(), &mod.ReqParam{Method: "GET", URL: "/depth"}, nil)
+	respErr, ok := err.(*util.ResponseError)
+	if !ok {
+		t.Fatalf("expected *ResponseError, got %v", err)
+	}
+	if respErr.RetryAfter != 30*time.Second || header.Get("X-MBX-USED-WEIGHT-1M") != "1201" {
+		t.Fatalf("unexpected error %+v, header %v", respErr, header)
+	}
 }
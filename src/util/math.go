@@ -1,90 +1,223 @@
 package util
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
+
+	"github.com/shopspring/decimal"
 )
 
-func GetCurrentUnixNano() int64 {
-	return time.Now().UnixNano()
+var ErrNilValue = errors.New("nil value")
+
+// ConvertError 类型转换失败，Value 为原始值
+type ConvertError struct {
+	Value  interface{}
+	Target string
+	Err    error
 }
 
-func ToFloat64(v interface{}) float64 {
-	if v == nil {
-		return 0.0
+func (e *ConvertError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("cannot convert %T(%v) to %s: %v", e.Value, e.Value, e.Target, e.Err)
 	}
+	return fmt.Sprintf("cannot convert %T(%v) to %s", e.Value, e.Value, e.Target)
+}
 
-	switch v.(type) {
-	case float64:
-		return v.(float64)
-	case string:
-		vStr := v.(string)
-		vF, _ := strconv.ParseFloat(vStr, 64)
-		return vF
-	default:
-		panic("to float64 error.")
+// convertCause 取出 *ConvertError 的底层错误，用于以新的目标类型重新包装
+func convertCause(err error) error {
+	if convErr, ok := err.(*ConvertError); ok {
+		return convErr.Err
 	}
+	return err
 }
 
-func ToInt(v interface{}) int {
-	if v == nil {
-		return 0
-	}
+func GetCurrentUnixNano() int64 {
+	return time.Now().UnixNano()
+}
 
-	switch v.(type) {
+/*
+	ParseFloat64 转换为float64，无法转换时返回 *ConvertError
+	支持 string、json.Number、float64、int、int64、bool(true为1)
+*/
+func ParseFloat64(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case nil:
+		return 0, &ConvertError{v, "float64", ErrNilValue}
+	case float64:
+		return val, nil
 	case string:
-		vStr := v.(string)
-		vInt, _ := strconv.Atoi(vStr)
-		return vInt
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return 0, &ConvertError{v, "float64", err}
+		}
+		return f, nil
+	case json.Number:
+		f, err := val.Float64()
+		if err != nil {
+			return 0, &ConvertError{v, "float64", err}
+		}
+		return f, nil
 	case int:
-		return v.(int)
-	case float64:
-		vF := v.(float64)
-		return int(vF)
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
 	default:
-		panic("to int error.")
+		return 0, &ConvertError{Value: v, Target: "float64"}
 	}
 }
 
-func ToUint64(v interface{}) uint64 {
-	if v == nil {
-		return 0
-	}
-
-	switch v.(type) {
+/*
+	ParseInt64 转换为int64，无法转换时返回 *ConvertError
+	float64 必须为整数，避免静默截断
+*/
+func ParseInt64(v interface{}) (int64, error) {
+	switch val := v.(type) {
+	case nil:
+		return 0, &ConvertError{v, "int64", ErrNilValue}
+	case int64:
+		return val, nil
 	case int:
-		return uint64(v.(int))
+		return int64(val), nil
 	case float64:
-		return uint64((v.(float64)))
+		if val != math.Trunc(val) || val >= math.MaxInt64 || val < math.MinInt64 {
+			return 0, &ConvertError{Value: v, Target: "int64"}
+		}
+		return int64(val), nil
 	case string:
-		uV, _ := strconv.ParseUint(v.(string), 10, 64)
-		return uV
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, &ConvertError{v, "int64", err}
+		}
+		return i, nil
+	case json.Number:
+		i, err := val.Int64()
+		if err != nil {
+			return 0, &ConvertError{v, "int64", err}
+		}
+		return i, nil
+	case bool:
+		if val {
+			return 1, nil
+		}
+		return 0, nil
 	default:
-		panic("to uint64 error.")
+		return 0, &ConvertError{Value: v, Target: "int64"}
 	}
 }
 
-func ToInt64(v interface{}) int64 {
-	if v == nil {
-		return 0
+// ParseInt 转换为int，规则同 ParseInt64
+func ParseInt(v interface{}) (int, error) {
+	i, err := ParseInt64(v)
+	if err != nil {
+		return 0, &ConvertError{v, "int", convertCause(err)}
+	}
+	if int64(int(i)) != i {
+		return 0, &ConvertError{Value: v, Target: "int"}
 	}
+	return int(i), nil
+}
 
-	switch v.(type) {
-	case float64:
-		return int64(v.(float64))
+// ParseUint64 转换为uint64，负数返回错误
+func ParseUint64(v interface{}) (uint64, error) {
+	var s string
+	switch val := v.(type) {
+	case string:
+		s = val
+	case json.Number:
+		s = val.String()
 	default:
-		vv := fmt.Sprint(v)
-
-		if vv == "" {
-			return 0
+		i, err := ParseInt64(v)
+		if err != nil {
+			return 0, &ConvertError{v, "uint64", convertCause(err)}
+		}
+		if i < 0 {
+			return 0, &ConvertError{Value: v, Target: "uint64"}
 		}
+		return uint64(i), nil
+	}
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, &ConvertError{v, "uint64", err}
+	}
+	return u, nil
+}
 
-		vvv, err := strconv.ParseInt(vv, 0, 64)
+/*
+	ParseDecimal 转换为decimal，string 与 json.Number 按原样精确解析
+	float64 本身已有精度损失，按最短表示转换
+*/
+func ParseDecimal(v interface{}) (decimal.Decimal, error) {
+	switch val := v.(type) {
+	case nil:
+		return decimal.Zero, &ConvertError{v, "decimal", ErrNilValue}
+	case decimal.Decimal:
+		return val, nil
+	case string:
+		d, err := decimal.NewFromString(val)
 		if err != nil {
-			return 0
+			return decimal.Zero, &ConvertError{v, "decimal", err}
 		}
-
-		return vvv
+		return d, nil
+	case json.Number:
+		d, err := decimal.NewFromString(val.String())
+		if err != nil {
+			return decimal.Zero, &ConvertError{v, "decimal", err}
+		}
+		return d, nil
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return decimal.Zero, &ConvertError{Value: v, Target: "decimal"}
+		}
+		return decimal.NewFromFloat(val), nil
+	case int:
+		return decimal.NewFromInt(int64(val)), nil
+	case int64:
+		return decimal.NewFromInt(val), nil
+	case bool:
+		if val {
+			return decimal.New(1, 0), nil
+		}
+		return decimal.Zero, nil
+	default:
+		return decimal.Zero, &ConvertError{Value: v, Target: "decimal"}
 	}
 }
+
+// ToFloat64 无法转换时返回0，需要区分错误时使用 ParseFloat64
+func ToFloat64(v interface{}) float64 {
+	f, _ := ParseFloat64(v)
+	return f
+}
+
+// ToDecimal 无法转换时返回0，需要区分错误时使用 ParseDecimal
+func ToDecimal(v interface{}) decimal.Decimal {
+	d, _ := ParseDecimal(v)
+	return d
+}
+
+// ToInt 无法转换时返回0，需要区分错误时使用 ParseInt
+func ToInt(v interface{}) int {
+	i, _ := ParseInt(v)
+	return i
+}
+
+// ToUint64 无法转换时返回0，需要区分错误时使用 ParseUint64
+func ToUint64(v interface{}) uint64 {
+	u, _ := ParseUint64(v)
+	return u
+}
+
+// ToInt64 无法转换时返回0，需要区分错误时使用 ParseInt64
+func ToInt64(v interface{}) int64 {
+	i, _ := ParseInt64(v)
+	return i
+}
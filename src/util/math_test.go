@@ -0,0 +1,60 @@
+package util_test
+
+import (
+	"encoding/json"
+	"testing"
+	"tinyquant/src/util"
+)
+
+func TestParseDecimal(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		want string
+	}{
+		{"0.10264410", "0.1026441"},
+		{json.Number("52648.62"), "52648.62"},
+		{int64(1499405658658), "1499405658658"},
+		{1.5, "1.5"},
+		{true, "1"},
+	}
+	for _, c := range cases {
+		d, err := util.ParseDecimal(c.v)
+		if err != nil || d.String() != c.want {
+			t.Errorf("ParseDecimal(%#v) = %s, %v", c.v, d, err)
+		}
+	}
+	for _, v := range []interface{}{nil, "", "1.2.3", []string{"1"}} {
+		if _, err := util.ParseDecimal(v); err == nil {
+			t.Errorf("ParseDecimal(%#v) expected error", v)
+		}
+	}
+}
+
+func TestParseInt64(t *testing.T) {
+	if i, err := util.ParseInt64(json.Number("1499405658658")); err != nil || i != 1499405658658 {
+		t.Fatalf("json.Number parsed as %d, %v", i, err)
+	}
+	if i, err := util.ParseInt64(false); err != nil || i != 0 {
+		t.Fatalf("bool parsed as %d, %v", i, err)
+	}
+	for _, v := range []interface{}{nil, "12a", 1.5, json.Number("1.5"), map[string]interface{}{}} {
+		if _, err := util.ParseInt64(v); err == nil {
+			t.Errorf("ParseInt64(%#v) expected error", v)
+		}
+	}
+	if _, err := util.ParseUint64(int64(-1)); err == nil {
+		t.Error("ParseUint64(-1) expected error")
+	}
+	if _, err := util.ParseInt("9223372036854775808"); err == nil {
+		t.Error("ParseInt overflow expected error")
+	}
+}
+
+func TestToFloat64DoesNotPanic(t *testing.T) {
+	if f := util.ToFloat64([]int{1}); f != 0 {
+		t.Fatalf("unexpected %v", f)
+	}
+	if i := util.ToInt(struct{}{}); i != 0 {
+		t.Fatalf("unexpected %v", i)
+	}
+}
@@ -0,0 +1,78 @@
+package util
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"time"
+)
+
+/*
+	RetryPolicy 重试策略，失败后按指数退避等待，等待时间带随机抖动避免多个客户端同时重试
+	MaxAttempts 为总尝试次数，<=1 时不重试
+*/
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration // 第一次重试前的等待时间
+	MaxDelay    time.Duration // 等待时间上限
+}
+
+// NoRetry 不重试
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// Backoff 第attempt次失败后的等待时间，在 [d/2, d) 之间随机，d = BaseDelay * 2^(attempt-1)
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)))
+}
+
+// Sleep 等待d，ctx 结束时提前返回 ctx.Err()
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+/*
+	IsTemporary 判断是否为可重试的临时错误: 网络错误、连接中断与服务端5xx
+	ctx 取消或超时不重试
+*/
+func IsTemporary(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	if respErr, ok := err.(*ResponseError); ok {
+		return respErr.StatusCode >= 500
+	}
+	if urlErr, ok := err.(*url.Error); ok {
+		if urlErr.Err == context.Canceled || urlErr.Err == context.DeadlineExceeded {
+			return false
+		}
+		err = urlErr.Err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
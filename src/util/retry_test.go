@@ -0,0 +1,47 @@
+package util_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+	"tinyquant/src/util"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := util.RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	cases := map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 10: 300 * time.Millisecond}
+	for attempt, max := range cases {
+		for i := 0; i < 20; i++ {
+			if d := p.Backoff(attempt); d < max/2 || d >= max {
+				t.Fatalf("Backoff(%d) = %s, want [%s, %s)", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestIsTemporary(t *testing.T) {
+	temporary := []error{
+		&url.Error{Op: "Get", URL: "/", Err: io.EOF},
+		&util.ResponseError{StatusCode: 503},
+	}
+	for _, err := range temporary {
+		if !util.IsTemporary(err) {
+			t.Errorf("%v should be temporary", err)
+		}
+	}
+	permanent := []error{
+		nil,
+		context.Canceled,
+		&url.Error{Op: "Get", URL: "/", Err: context.DeadlineExceeded},
+		&util.ResponseError{StatusCode: 400, Code: -1121},
+		errors.New("invalid"),
+	}
+	for _, err := range permanent {
+		if util.IsTemporary(err) {
+			t.Errorf("%v should not be temporary", err)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+	"tinyquant/src/logger"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	wsWriteTimeout        = 10 * time.Second
+	wsHandshakeTimeout    = 10 * time.Second
+	defaultPingInterval   = 30 * time.Second
+	defaultReadTimeout    = 3 * time.Minute
+	defaultReconnectDelay = time.Second
+	maxReconnectDelay     = time.Minute
+)
+
+var ErrWsClosed = errors.New("websocket connection closed")
+
+/*
+	WsConn 带自动重连的websocket连接
+	连接断开后按指数退避重连，重连成功后重新发送所有订阅消息
+*/
+type WsConn struct {
+	endpoint     string
+	proxyURL     string
+	handle       func([]byte) error
+	onReconnect  func()
+	pingInterval time.Duration
+	pingMessage  []byte
+	readTimeout  time.Duration
+
+	mu      sync.Mutex // guards conn, subs and writes
+	conn    *websocket.Conn
+	subs    [][]byte
+	closeCh chan struct{}
+	closed  bool
+}
+
+func NewWsConn(endpoint, proxyURL string, handle func([]byte) error) *WsConn {
+	return &WsConn{
+		endpoint:     endpoint,
+		proxyURL:     proxyURL,
+		handle:       handle,
+		pingInterval: defaultPingInterval,
+		readTimeout:  defaultReadTimeout,
+		closeCh:      make(chan struct{}),
+	}
+}
+
+// SetPingInterval 设置客户端主动ping的间隔
+func (ws *WsConn) SetPingInterval(d time.Duration) *WsConn {
+	ws.pingInterval = d
+	return ws
+}
+
+// SetPingMessage 以文本消息代替ping控制帧作为心跳，如 okex 的 "ping"
+func (ws *WsConn) SetPingMessage(msg string) *WsConn {
+	ws.pingMessage = []byte(msg)
+	return ws
+}
+
+// SetReadTimeout 超过该时间未收到任何消息即认为连接已断开
+func (ws *WsConn) SetReadTimeout(d time.Duration) *WsConn {
+	ws.readTimeout = d
+	return ws
+}
+
+// SetReconnectHandler 重连成功并重发订阅消息后回调
+func (ws *WsConn) SetReconnectHandler(handler func()) *WsConn {
+	ws.onReconnect = handler
+	return ws
+}
+
+// NewWebsocket 建立连接并启动读循环与心跳
+func (ws *WsConn) NewWebsocket() error {
+	conn, err := ws.dial()
+	if err != nil {
+		logger.Logger.Error("[ws] connect failed ", zap.String("endpoint", ws.endpoint), zap.Error(err))
+		return err
+	}
+	ws.mu.Lock()
+	ws.conn = conn
+	ws.mu.Unlock()
+
+	go ws.readLoop(conn)
+	go ws.pingLoop()
+	return nil
+}
+
+func (ws *WsConn) dial() (*websocket.Conn, error) {
+	dialer := &websocket.Dialer{
+		HandshakeTimeout: wsHandshakeTimeout,
+	}
+	if ws.proxyURL != "" {
+		proxy, err := url.Parse(ws.proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		dialer.Proxy = http.ProxyURL(proxy)
+	}
+	conn, _, err := dialer.Dial(ws.endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(ws.readTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(ws.readTimeout))
+	})
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(ws.readTimeout))
+		ws.mu.Lock()
+		defer ws.mu.Unlock()
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(wsWriteTimeout))
+		if err == websocket.ErrCloseSent {
+			return nil
+		}
+		return err
+	})
+	return conn, nil
+}
+
+// Subscribe 发送订阅消息，并在重连后自动重新发送
+func (ws *WsConn) Subscribe(v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	ws.mu.Lock()
+	ws.subs = append(ws.subs, msg)
+	ws.mu.Unlock()
+	return ws.writeMessage(msg)
+}
+
+// SendMessage 发送一次性消息，重连后不会重发
+func (ws *WsConn) SendMessage(v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.writeMessage(msg)
+}
+
+func (ws *WsConn) writeMessage(msg []byte) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return ErrWsClosed
+	}
+	if ws.conn == nil {
+		return errors.New("websocket not connected")
+	}
+	ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return ws.conn.WriteMessage(websocket.TextMessage, msg)
+}
+
+func (ws *WsConn) readLoop(conn *websocket.Conn) {
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			if ws.isClosed() {
+				return
+			}
+			logger.Logger.Warn("[ws] read failed, reconnecting ", zap.String("endpoint", ws.endpoint), zap.Error(err))
+			conn.Close()
+			conn = ws.reconnect()
+			if conn == nil {
+				return
+			}
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(ws.readTimeout))
+		if err := ws.handle(msg); err != nil {
+			logger.Logger.Error("[ws] handle message failed ", zap.String("endpoint", ws.endpoint), zap.Error(err))
+		}
+	}
+}
+
+// reconnect 指数退避重连，直到成功或连接被关闭
+func (ws *WsConn) reconnect() *websocket.Conn {
+	delay := defaultReconnectDelay
+	for {
+		select {
+		case <-ws.closeCh:
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, err := ws.dial()
+		if err != nil {
+			logger.Logger.Error("[ws] reconnect failed ", zap.String("endpoint", ws.endpoint), zap.Duration("retry_in", delay), zap.Error(err))
+			delay *= 2
+			if delay > maxReconnectDelay {
+				delay = maxReconnectDelay
+			}
+			continue
+		}
+
+		ws.mu.Lock()
+		if ws.closed {
+			ws.mu.Unlock()
+			conn.Close()
+			return nil
+		}
+		ws.conn = conn
+		for _, sub := range ws.subs {
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, sub); err != nil {
+				logger.Logger.Error("[ws] resubscribe failed ", zap.String("endpoint", ws.endpoint), zap.Error(err))
+			}
+		}
+		ws.mu.Unlock()
+		logger.Logger.Info("[ws] reconnected ", zap.String("endpoint", ws.endpoint))
+		if ws.onReconnect != nil {
+			ws.onReconnect()
+		}
+		return conn
+	}
+}
+
+func (ws *WsConn) pingLoop() {
+	if ws.pingInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(ws.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ws.closeCh:
+			return
+		case <-ticker.C:
+			ws.mu.Lock()
+			if ws.conn != nil {
+				var err error
+				if ws.pingMessage != nil {
+					ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+					err = ws.conn.WriteMessage(websocket.TextMessage, ws.pingMessage)
+				} else {
+					err = ws.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout))
+				}
+				if err != nil {
+					logger.Logger.Warn("[ws] ping failed ", zap.String("endpoint", ws.endpoint), zap.Error(err))
+				}
+			}
+			ws.mu.Unlock()
+		}
+	}
+}
+
+func (ws *WsConn) isClosed() bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.closed
+}
+
+// Close 关闭连接并停止重连
+func (ws *WsConn) Close() error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+	close(ws.closeCh)
+	if ws.conn == nil {
+		return nil
+	}
+	ws.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(wsWriteTimeout))
+	return ws.conn.Close()
+}
@@ -0,0 +1,81 @@
+package util_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"tinyquant/src/logger"
+	"tinyquant/src/util"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+func init() {
+	if logger.Logger == nil {
+		logger.Logger = zap.NewNop()
+	}
+}
+
+func TestWsConnResubscribeAfterReconnect(t *testing.T) {
+	var conns int32
+	subs := make(chan string, 4)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		n := atomic.AddInt32(&conns, 1)
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			c.Close()
+			return
+		}
+		subs <- string(msg)
+		if n == 1 {
+			// drop the first connection to force a reconnect
+			c.Close()
+			return
+		}
+		c.WriteMessage(websocket.TextMessage, []byte(`{"ok":true}`))
+		c.ReadMessage()
+	}))
+	defer srv.Close()
+
+	received := make(chan []byte, 1)
+	endpoint := "ws" + strings.TrimPrefix(srv.URL, "http")
+	ws := util.NewWsConn(endpoint, "", func(msg []byte) error {
+		received <- msg
+		return nil
+	})
+	if err := ws.NewWebsocket(); err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if err := ws.Subscribe(map[string]string{"method": "SUBSCRIBE"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-subs:
+			if msg != `{"method":"SUBSCRIBE"}` {
+				t.Fatalf("unexpected subscription %s", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("subscription %d not received", i+1)
+		}
+	}
+	select {
+	case msg := <-received:
+		if string(msg) != `{"ok":true}` {
+			t.Fatalf("unexpected message %s", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message after reconnect")
+	}
+}